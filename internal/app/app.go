@@ -0,0 +1,165 @@
+// Package app runs the service's subsystems: it starts them in order, tracks readiness and
+// stops them in reverse order on shutdown.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Component is a subsystem with start and stop hooks; either may be nil. Start must not block:
+// long-running work belongs in a goroutine that Stop ends.
+type Component struct {
+	Name  string
+	Start func(ctx context.Context) error
+	Stop  func(ctx context.Context) error
+}
+
+// App owns the registered components and the process's readiness
+type App struct {
+	shutdownDelay   time.Duration // How long to report not-ready before stopping, so load balancers stop routing to us
+	shutdownTimeout time.Duration // Upper bound for stopping every component, including draining requests
+
+	components []Component
+	started    []Component
+	ready      atomic.Bool
+	failed     chan error
+}
+
+// New creates an app that waits shutdownDelay after turning not-ready and then allows
+// shutdownTimeout for components to stop
+func New(shutdownDelay, shutdownTimeout time.Duration) *App {
+	return &App{
+		shutdownDelay:   shutdownDelay,
+		shutdownTimeout: shutdownTimeout,
+		failed:          make(chan error, 1),
+	}
+}
+
+// Register adds a component; components start in registration order and stop in reverse
+func (a *App) Register(c Component) {
+	a.components = append(a.components, c)
+}
+
+// Serve registers an HTTP server. It listens when started and, when stopped, stops accepting
+// connections and waits for in-flight requests until the shutdown timeout.
+func (a *App) Serve(name string, srv *http.Server) {
+	a.Register(Component{
+		Name: name,
+		Start: func(ctx context.Context) error {
+			ln, err := net.Listen("tcp", srv.Addr)
+			if err != nil {
+				return err
+			}
+			go func() {
+				if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					a.Fail(fmt.Errorf("%s: %w", name, err))
+				}
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			if err := srv.Shutdown(ctx); err != nil {
+				srv.Close() // Drop whatever did not finish within the timeout
+				return err
+			}
+			return nil
+		},
+	})
+}
+
+// Ready reports whether the app should receive traffic
+func (a *App) Ready() bool {
+	return a.ready.Load()
+}
+
+// Fail reports an unrecoverable error from a running component and shuts the app down
+func (a *App) Fail(err error) {
+	select {
+	case a.failed <- err:
+	default:
+	}
+}
+
+// Start runs every component's start hook in order. If one fails, the ones already started are stopped.
+func (a *App) Start(ctx context.Context) error {
+	for _, c := range a.components {
+		if c.Start != nil {
+			if err := c.Start(ctx); err != nil {
+				stopCtx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+				defer cancel()
+				return errors.Join(fmt.Errorf("starting %s: %w", c.Name, err), a.stop(stopCtx))
+			}
+		}
+		a.started = append(a.started, c)
+	}
+	a.ready.Store(true)
+	return nil
+}
+
+// Run starts the app and blocks until ctx is done (typically on SIGTERM) or a component fails,
+// then turns not-ready, waits for the shutdown delay and stops every component
+func (a *App) Run(ctx context.Context) error {
+	if err := a.Start(ctx); err != nil {
+		return err
+	}
+
+	var failure error
+	select {
+	case <-ctx.Done():
+		log.Println("🛑 Shutting down")
+	case failure = <-a.failed:
+		log.Printf("❌ %v; shutting down", failure)
+	}
+
+	a.ready.Store(false)
+	if failure == nil && a.shutdownDelay > 0 {
+		time.Sleep(a.shutdownDelay)
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+	return errors.Join(failure, a.stop(stopCtx))
+}
+
+// stopGrace is how long a stop hook may run once the shutdown timeout has passed
+const stopGrace = time.Second
+
+// stop runs the stop hooks of started components in reverse order. A hook still running after
+// ctx and the grace period is abandoned so one stuck component cannot hold up the rest.
+func (a *App) stop(ctx context.Context) error {
+	a.ready.Store(false)
+
+	var errs []error
+	for i := len(a.started) - 1; i >= 0; i-- {
+		c := a.started[i]
+		if c.Stop == nil {
+			continue
+		}
+		done := make(chan error, 1)
+		go func() { done <- c.Stop(ctx) }()
+
+		var err error
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			// Hooks after a slow one still get a moment, e.g. to close connection pools
+			select {
+			case err = <-done:
+			case <-time.After(stopGrace):
+				err = ctx.Err()
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("stopping %s: %w", c.Name, err))
+		}
+	}
+	a.started = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,16 @@
+package audit
+
+import (
+	"log"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/models"
+)
+
+// Record stores an audit entry. Failures are logged rather than returned so that auditing
+// never blocks the action being audited.
+func Record(entry models.AuditEntry) {
+	if err := config.DB.Create(&entry).Error; err != nil {
+		log.Printf("⚠️ Failed to record audit entry %s for %q: %v", entry.Action, entry.Target, err)
+	}
+}
@@ -0,0 +1,81 @@
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"feature-flag-service/internal/audit"
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/passwords"
+
+	"gorm.io/gorm"
+)
+
+// DefaultOrganization names the organization created on an empty database when none is configured
+const DefaultOrganization = "default"
+
+var ErrIncompleteAdmin = errors.New("BOOTSTRAP_ADMIN_USERNAME and BOOTSTRAP_ADMIN_PASSWORD must be set together")
+
+// Run prepares an empty database: it creates the first organization and, when configured,
+// an owner account from BOOTSTRAP_ADMIN_USERNAME and BOOTSTRAP_ADMIN_PASSWORD. It does
+// nothing once any user exists, so the credentials can stay in the environment safely.
+func Run(settings config.BootstrapSettings) error {
+	username, password := settings.AdminUsername, settings.AdminPassword
+	if (username == "") != (password == "") {
+		return ErrIncompleteAdmin
+	}
+
+	var org models.Organization
+	if err := config.DB.Order("id").Limit(1).Find(&org).Error; err != nil {
+		return err
+	}
+	if org.ID == 0 {
+		org.Name = settings.Organization
+		if org.Name == "" {
+			org.Name = DefaultOrganization
+		}
+		if err := config.DB.Create(&org).Error; err != nil {
+			return err
+		}
+	}
+
+	var users int64
+	if err := config.DB.Unscoped().Model(&models.User{}).Count(&users).Error; err != nil {
+		return err
+	}
+	if users > 0 {
+		return nil
+	}
+	if username == "" {
+		log.Println("⚠️ No users exist; set BOOTSTRAP_ADMIN_USERNAME and BOOTSTRAP_ADMIN_PASSWORD to create the first owner")
+		return nil
+	}
+
+	hashedPassword, err := passwords.Hash(username, password)
+	if err != nil {
+		return fmt.Errorf("BOOTSTRAP_ADMIN_PASSWORD: %w", err)
+	}
+	owner := models.User{
+		Username:       username,
+		Password:       hashedPassword,
+		Role:           models.RoleOwner,
+		Email:          settings.AdminEmail,
+		OrganizationID: &org.ID,
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&owner).Error; err != nil {
+			return err
+		}
+		return passwords.Remember(tx, owner.ID, hashedPassword, time.Now())
+	})
+	if err != nil {
+		return err
+	}
+	audit.Record(models.AuditEntry{Action: models.AuditUserBootstrap, Target: owner.Username, Detail: "created from BOOTSTRAP_ADMIN_USERNAME"})
+
+	log.Printf("✅ Created bootstrap owner %q in organization %q", owner.Username, org.Name)
+	return nil
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrCacheMiss is returned when a key does not exist
+var ErrCacheMiss = errors.New("cache: key not found")
+
+// CacheStore holds short-lived shared state: revocation lists, login counters and pending logins.
+// Redis backs it in production; the in-memory cache lets a single instance run without Redis.
+type CacheStore interface {
+	Set(key string, value []byte, ttl time.Duration) error
+	GetDel(key string) ([]byte, error)
+	Exists(keys ...string) (bool, error)
+	TTL(key string) (time.Duration, error)             // Remaining lifetime, or 0 if the key is missing
+	Incr(key string, ttl time.Duration) (int64, error) // Increments a counter and restarts its lifetime
+	Del(keys ...string) error
+	Ping() error
+}
+
+// RedisCache implements CacheStore with Redis, sharing state across instances
+type RedisCache struct {
+	Client *redis.Client
+}
+
+func (r RedisCache) Set(key string, value []byte, ttl time.Duration) error {
+	return r.Client.Set(Ctx, key, value, ttl).Err()
+}
+
+func (r RedisCache) GetDel(key string) ([]byte, error) {
+	value, err := r.Client.GetDel(Ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrCacheMiss
+	}
+	return value, err
+}
+
+func (r RedisCache) Exists(keys ...string) (bool, error) {
+	n, err := r.Client.Exists(Ctx, keys...).Result()
+	return n > 0, err
+}
+
+func (r RedisCache) TTL(key string) (time.Duration, error) {
+	ttl, err := r.Client.PTTL(Ctx, key).Result()
+	if err != nil && err != redis.Nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (r RedisCache) Incr(key string, ttl time.Duration) (int64, error) {
+	pipe := r.Client.TxPipeline()
+	count := pipe.Incr(Ctx, key)
+	pipe.Expire(Ctx, key, ttl)
+	if _, err := pipe.Exec(Ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
+
+func (r RedisCache) Del(keys ...string) error {
+	return r.Client.Del(Ctx, keys...).Err()
+}
+
+func (r RedisCache) Ping() error {
+	return r.Client.Ping(Ctx).Err()
+}
+
+// MemoryCache implements CacheStore in process memory. State is lost on restart and not shared, so it
+// only suits a single instance, such as local development.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	writes  int
+	now     func() time.Time
+}
+
+// memorySweepInterval is how many writes pass between sweeps for expired keys that were never read again
+const memorySweepInterval = 1024
+
+type memoryEntry struct {
+	value     []byte
+	counter   int64
+	expiresAt time.Time
+}
+
+// NewMemoryCache creates an empty in-memory cache
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]memoryEntry), now: time.Now}
+}
+
+func (m *MemoryCache) Set(key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = memoryEntry{value: value, expiresAt: m.now().Add(ttl)}
+	m.wrote()
+	return nil
+}
+
+func (m *MemoryCache) GetDel(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.live(key)
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	delete(m.entries, key)
+	return entry.value, nil
+}
+
+func (m *MemoryCache) Exists(keys ...string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		if _, ok := m.live(key); ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryCache) TTL(key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.live(key)
+	if !ok {
+		return 0, nil
+	}
+	return entry.expiresAt.Sub(m.now()), nil
+}
+
+func (m *MemoryCache) Incr(key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, _ := m.live(key)
+	entry.counter++
+	entry.expiresAt = m.now().Add(ttl)
+	m.entries[key] = entry
+	m.wrote()
+	return entry.counter, nil
+}
+
+func (m *MemoryCache) Del(keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+func (m *MemoryCache) Ping() error {
+	return nil
+}
+
+// live returns an unexpired entry, dropping it if it has expired; callers hold the lock
+func (m *MemoryCache) live(key string) (memoryEntry, bool) {
+	entry, ok := m.entries[key]
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if !m.now().Before(entry.expiresAt) {
+		delete(m.entries, key)
+		return memoryEntry{}, false
+	}
+	return entry, true
+}
+
+// wrote sweeps expired entries every memorySweepInterval writes; callers hold the lock
+func (m *MemoryCache) wrote() {
+	m.writes++
+	if m.writes%memorySweepInterval != 0 {
+		return
+	}
+	now := m.now()
+	for key, entry := range m.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(m.entries, key)
+		}
+	}
+}
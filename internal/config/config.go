@@ -2,6 +2,7 @@ package config
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -13,29 +14,46 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"gorm.io/gorm/logger"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
-	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/migrations"
 )
 
 // Global variables for DB and Redis
 var (
-	DB   *gorm.DB
-	RDB  *redis.Client
-	Ctx  = context.Background()
-	Mock sqlmock.Sqlmock // Mock variable for testing
+	DB    *gorm.DB
+	RDB   *redis.Client
+	Cache CacheStore // Redis, or process memory when running without Redis
+	Ctx   = context.Background()
+	Mock  sqlmock.Sqlmock // Mock variable for testing
 )
 
-// RunMigrations applies database migration
-func RunMigrations() {
-	err := DB.AutoMigrate(&models.FeatureFlag{}, &models.User{})
-	if err != nil {
-		log.Fatalf("❌ Failed to run migrations: %v", err)
+// Returned when the database or Redis could not be reached before the connect timeout
+var (
+	ErrUnavailable         = errors.New("unavailable")
+	ErrDatabaseUnavailable = fmt.Errorf("database %w", ErrUnavailable)
+	ErrRedisUnavailable    = fmt.Errorf("redis %w", ErrUnavailable)
+)
+
+// RunMigrations applies pending versioned migrations, unless disabled, and refuses to start against a newer schema
+func RunMigrations(apply bool) error {
+	if err := migrations.Startup(DB, apply); err != nil {
+		return fmt.Errorf("database schema check failed: %w", err)
 	}
-	fmt.Println("✅ Database migrations applied successfully")
+	fmt.Println("✅ Database schema is up to date")
+	return nil
 }
 
-// ConnectDB initializes PostgreSQL connection
-func ConnectDB() {
+// Storage backends selectable with STORE
+const (
+	StorePostgres = "postgres"
+	StoreSQLite   = "sqlite"
+)
+
+// ConnectDB initializes the database connection: PostgreSQL, or SQLite with STORE=sqlite.
+// PostgreSQL is retried with backoff for the connect timeout; if it is still unreachable the
+// pool is kept, since it reconnects on its own, and ErrDatabaseUnavailable is returned.
+func ConnectDB(settings DatabaseSettings) error {
 	if os.Getenv("TEST_MODE") == "true" {
 		fmt.Println("🛠️ Running in TEST mode with a mock database")
 
@@ -58,23 +76,43 @@ func ConnectDB() {
 		}
 
 		fmt.Println("✅ Mock database connected")
-		return
+		return nil
 	}
 
-	// Production/PostgreSQL setup
-	dsn := os.Getenv("DATABASE_URL")
-	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("❌ Could not connect to PostgreSQL: %v", err)
-	}
+	switch settings.Store {
+	case StorePostgres:
+		// Production/PostgreSQL setup
+		var err error
+		DB, err = gorm.Open(postgres.Open(settings.URL), &gorm.Config{DisableAutomaticPing: true})
+		if err != nil {
+			log.Fatalf("❌ Invalid PostgreSQL configuration: %v", err)
+		}
+		err = Retry("PostgreSQL", settings.ConnectTimeout, func() error { return PingDB(Ctx) })
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrDatabaseUnavailable, err)
+		}
 
-	fmt.Println("✅ Connected to PostgreSQL")
+		fmt.Println("✅ Connected to PostgreSQL")
+	case StoreSQLite:
+		// Local development: a single file, no database server
+		path := settings.SQLitePath
+		var err error
+		DB, err = gorm.Open(sqlite.Open(path+"?_busy_timeout=5000&_journal_mode=WAL"), &gorm.Config{})
+		if err != nil {
+			log.Fatalf("❌ Could not open SQLite database %s: %v", path, err)
+		}
+
+		fmt.Printf("✅ Using SQLite database %s\n", path)
+	default:
+		log.Fatalf("❌ Unknown STORE %q; use %q or %q", settings.Store, StorePostgres, StoreSQLite)
+	}
+	return nil
 }
 
-// ConnectRedis initializes Redis connection
-func ConnectRedis() {
-	redisURL := os.Getenv("REDIS_URL")
+// ConnectRedis initializes Redis connection, retrying with backoff for the connect timeout.
+// If Redis is still unreachable the client is kept, since it reconnects on its own, and ErrRedisUnavailable is returned.
+func ConnectRedis(settings RedisSettings) error {
+	redisURL := settings.URL
 
 	// Parse REDIS_URL if it contains authentication
 	if strings.HasPrefix(redisURL, "redis://") {
@@ -96,25 +134,55 @@ func ConnectRedis() {
 		// Fallback if REDIS_URL is incorrectly formatted
 		RDB = redis.NewClient(&redis.Options{
 			Addr:     redisURL,
-			Password: os.Getenv("REDIS_PASSWORD"), // Fallback password
+			Password: settings.Password, // Fallback password
 			DB:       0,
 		})
 	}
 
 	// Test Redis connection
-	_, err := RDB.Ping(Ctx).Result()
-	if err != nil {
-		log.Fatalf("❌ Could not connect to Redis: %v", err)
+	Cache = InstrumentedCache{CacheStore: RedisCache{Client: RDB}, Redis: true}
+	if err := Retry("Redis", settings.ConnectTimeout, Cache.Ping); err != nil {
+		return fmt.Errorf("%w: %v", ErrRedisUnavailable, err)
 	}
 
 	fmt.Println("✅ Connected to Redis")
+	return nil
+}
+
+// PingDB checks that the database accepts connections
+func PingDB(ctx context.Context) error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
 }
 
-// Init initializes all connections
-func Init() {
-	ConnectDB()
-	ConnectRedis()
-	if os.Getenv("TEST_MODE") != "true" {
-		RunMigrations()
+// Close releases the Redis client and the database pool
+func Close() error {
+	var errs []error
+	if RDB != nil {
+		errs = append(errs, RDB.Close())
+	}
+	if DB != nil {
+		if sqlDB, err := DB.DB(); err == nil {
+			errs = append(errs, sqlDB.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Init initializes all connections. It returns errors wrapping ErrUnavailable, with every connection created,
+// when the database or Redis could not be reached in time.
+func Init(settings *Settings) error {
+	dbErr := ConnectDB(settings.Database)
+	var cacheErr error
+	if settings.Database.Store == StoreSQLite && settings.Redis.URL == "" {
+		// Without Redis, revocations, login counters and pending logins live in this process only
+		fmt.Println("⚠️ REDIS_URL is not set; keeping cache state in memory")
+		Cache = InstrumentedCache{CacheStore: NewMemoryCache()}
+	} else {
+		cacheErr = ConnectRedis(settings.Redis)
 	}
+	return errors.Join(dbErr, cacheErr)
 }
\ No newline at end of file
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"feature-flag-service/internal/metrics"
+)
+
+// InstrumentedCache wraps a CacheStore to record lookup hits and misses and, when Redis backs it,
+// failed commands
+type InstrumentedCache struct {
+	CacheStore
+	Redis bool
+}
+
+func (i InstrumentedCache) Set(key string, value []byte, ttl time.Duration) error {
+	return i.failed("set", i.CacheStore.Set(key, value, ttl))
+}
+
+func (i InstrumentedCache) GetDel(key string) ([]byte, error) {
+	value, err := i.CacheStore.GetDel(key)
+	switch {
+	case err == nil:
+		metrics.CacheLookups.Inc(metrics.CacheHit)
+	case errors.Is(err, ErrCacheMiss):
+		metrics.CacheLookups.Inc(metrics.CacheMiss)
+	}
+	return value, i.failed("getdel", err)
+}
+
+func (i InstrumentedCache) Exists(keys ...string) (bool, error) {
+	found, err := i.CacheStore.Exists(keys...)
+	if err == nil {
+		result := metrics.CacheMiss
+		if found {
+			result = metrics.CacheHit
+		}
+		metrics.CacheLookups.Inc(result)
+	}
+	return found, i.failed("exists", err)
+}
+
+func (i InstrumentedCache) TTL(key string) (time.Duration, error) {
+	ttl, err := i.CacheStore.TTL(key)
+	return ttl, i.failed("ttl", err)
+}
+
+func (i InstrumentedCache) Incr(key string, ttl time.Duration) (int64, error) {
+	count, err := i.CacheStore.Incr(key, ttl)
+	return count, i.failed("incr", err)
+}
+
+func (i InstrumentedCache) Del(keys ...string) error {
+	return i.failed("del", i.CacheStore.Del(keys...))
+}
+
+func (i InstrumentedCache) Ping() error {
+	return i.failed("ping", i.CacheStore.Ping())
+}
+
+// failed counts Redis errors other than misses and passes err through
+func (i InstrumentedCache) failed(operation string, err error) error {
+	if err != nil && i.Redis && !errors.Is(err, ErrCacheMiss) {
+		metrics.RedisErrors.Inc(operation)
+	}
+	return err
+}
+
+// RegisterPoolMetrics exposes the database connection pool statistics, read at scrape time
+func RegisterPoolMetrics() {
+	stat := func(read func(sql.DBStats) float64) func() float64 {
+		return func() float64 {
+			sqlDB, err := DB.DB()
+			if err != nil {
+				return 0
+			}
+			return read(sqlDB.Stats())
+		}
+	}
+	metrics.NewGaugeFunc("db_pool_open_connections", "Open database connections, in use or idle.",
+		stat(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }))
+	metrics.NewGaugeFunc("db_pool_in_use_connections", "Database connections currently in use.",
+		stat(func(s sql.DBStats) float64 { return float64(s.InUse) }))
+	metrics.NewGaugeFunc("db_pool_idle_connections", "Idle database connections.",
+		stat(func(s sql.DBStats) float64 { return float64(s.Idle) }))
+	metrics.NewGaugeFunc("db_pool_max_open_connections", "Limit on open database connections; 0 is unlimited.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }))
+	metrics.NewCounterFunc("db_pool_wait_total", "Times a request waited for a free database connection.",
+		stat(func(s sql.DBStats) float64 { return float64(s.WaitCount) }))
+	metrics.NewCounterFunc("db_pool_wait_seconds_total", "Total time spent waiting for a free database connection.",
+		stat(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }))
+}
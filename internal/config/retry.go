@@ -0,0 +1,31 @@
+package config
+
+import (
+	"log"
+	"time"
+)
+
+// Backoff bounds for Retry
+const (
+	retryInitialBackoff = 500 * time.Millisecond
+	retryMaxBackoff     = 10 * time.Second
+)
+
+// Retry calls fn until it succeeds or timeout has passed, doubling the wait between attempts
+// up to retryMaxBackoff, and returns the last error
+func Retry(what string, timeout time.Duration, fn func() error) error {
+	deadline := time.Now().Add(timeout)
+	backoff := retryInitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return err
+		}
+		log.Printf("⏳ %s not reachable (attempt %d), retrying in %s: %v", what, attempt, backoff, err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, retryMaxBackoff)
+	}
+}
@@ -0,0 +1,380 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"feature-flag-service/internal/models"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Environments selectable with APP_ENV
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// Settings is the application configuration. Each value comes from, in increasing precedence:
+// the default tag, the YAML file named by CONFIG_FILE, the .env file, and the process
+// environment. Empty environment values count as unset.
+type Settings struct {
+	Env       string            `yaml:"env" env:"APP_ENV" default:"development"`
+	Server    ServerSettings    `yaml:"server"`
+	Database  DatabaseSettings  `yaml:"database"`
+	Redis     RedisSettings     `yaml:"redis"`
+	JWT       JWTSettings       `yaml:"jwt"`
+	OIDC      OIDCSettings      `yaml:"oidc"`
+	Auth      AuthSettings      `yaml:"auth"`
+	Passwords PasswordSettings  `yaml:"passwords"`
+	Notifier  NotifierSettings  `yaml:"notifier"`
+	Bootstrap BootstrapSettings `yaml:"bootstrap"`
+	Snapshot  SnapshotSettings  `yaml:"snapshot"`
+	Metrics   MetricsSettings   `yaml:"metrics"`
+}
+
+// ServerSettings configure the HTTP server and background jobs
+type ServerSettings struct {
+	Port               int           `yaml:"port" env:"PORT" default:"8080"`
+	TrashRetentionDays int           `yaml:"trash_retention_days" env:"TRASH_RETENTION_DAYS" default:"30"`
+	ShutdownDelay      time.Duration `yaml:"shutdown_delay" env:"SHUTDOWN_DELAY" default:"5s"`      // Time spent reporting not-ready before draining
+	ShutdownTimeout    time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"30s"` // Limit for draining requests and stopping subsystems
+}
+
+// DatabaseSettings select and locate the database
+type DatabaseSettings struct {
+	Store          string        `yaml:"store" env:"STORE" default:"postgres"`
+	URL            string        `yaml:"url" env:"DATABASE_URL" secret:"url"`
+	SQLitePath     string        `yaml:"sqlite_path" env:"SQLITE_PATH" default:"feature_flags.db"`
+	MigrateOnStart bool          `yaml:"migrate_on_start" env:"MIGRATE_ON_START" default:"true"`
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT" default:"60s"` // How long startup retries PostgreSQL
+}
+
+// RedisSettings locate Redis; it is optional with the SQLite store
+type RedisSettings struct {
+	URL            string        `yaml:"url" env:"REDIS_URL" secret:"url"`
+	Password       string        `yaml:"password" env:"REDIS_PASSWORD" secret:"true"`
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"REDIS_CONNECT_TIMEOUT" default:"30s"` // How long startup retries Redis
+}
+
+// JWTSettings configure token signing
+type JWTSettings struct {
+	KeysDir   string `yaml:"keys_dir" env:"JWT_KEYS_DIR"`
+	ActiveKID string `yaml:"active_kid" env:"JWT_ACTIVE_KID"`
+	Issuer    string `yaml:"issuer" env:"JWT_ISSUER" default:"feature-flag-service"`
+	Audience  string `yaml:"audience" env:"JWT_AUDIENCE" default:"feature-flag-service-api"`
+}
+
+// OIDCSettings configure single sign-on; it is disabled without an issuer
+type OIDCSettings struct {
+	Issuer       string `yaml:"issuer" env:"OIDC_ISSUER"`
+	ClientID     string `yaml:"client_id" env:"OIDC_CLIENT_ID"`
+	ClientSecret string `yaml:"client_secret" env:"OIDC_CLIENT_SECRET" secret:"true"`
+	RedirectURL  string `yaml:"redirect_url" env:"OIDC_REDIRECT_URL"`
+	Scopes       string `yaml:"scopes" env:"OIDC_SCOPES" default:"openid profile email"`
+	GroupsClaim  string `yaml:"groups_claim" env:"OIDC_GROUPS_CLAIM" default:"groups"`
+	DefaultRole  string `yaml:"default_role" env:"OIDC_DEFAULT_ROLE" default:"viewer"`
+	RoleMapping  string `yaml:"role_mapping" env:"OIDC_ROLE_MAPPING"` // e.g. "flag-admins=admin,flag-editors=editor"
+}
+
+// AuthSettings configure registration and second factors
+type AuthSettings struct {
+	AllowOpenRegistration bool   `yaml:"allow_open_registration" env:"ALLOW_OPEN_REGISTRATION" default:"false"`
+	MFAIssuer             string `yaml:"mfa_issuer" env:"MFA_ISSUER" default:"Feature Flag Service"`
+	MFAEncryptionKey      string `yaml:"mfa_encryption_key" env:"MFA_ENCRYPTION_KEY" secret:"true"` // Base64 of 32 bytes; encrypts TOTP secrets at rest
+}
+
+// PasswordSettings configure the password policy and resets
+type PasswordSettings struct {
+	MinLength    int    `yaml:"min_length" env:"PASSWORD_MIN_LENGTH" default:"12"`
+	History      int    `yaml:"history" env:"PASSWORD_HISTORY" default:"5"`
+	BreachedList string `yaml:"breached_list" env:"PASSWORD_BREACHED_LIST"`
+	ResetURL     string `yaml:"reset_url" env:"PASSWORD_RESET_URL"`
+}
+
+// NotifierSettings choose how messages such as password resets are delivered
+type NotifierSettings struct {
+	Kind string `yaml:"kind" env:"NOTIFIER" default:"log"`
+	File string `yaml:"file" env:"NOTIFIER_FILE" default:"notifications.jsonl"`
+}
+
+// BootstrapSettings describe the organization and owner created on an empty database
+type BootstrapSettings struct {
+	Organization  string `yaml:"organization" env:"BOOTSTRAP_ORGANIZATION" default:"default"`
+	AdminUsername string `yaml:"admin_username" env:"BOOTSTRAP_ADMIN_USERNAME"`
+	AdminPassword string `yaml:"admin_password" env:"BOOTSTRAP_ADMIN_PASSWORD" secret:"true"`
+	AdminEmail    string `yaml:"admin_email" env:"BOOTSTRAP_ADMIN_EMAIL"`
+}
+
+// SnapshotSettings configure the flag snapshot served while the database is unavailable
+type SnapshotSettings struct {
+	File     string        `yaml:"file" env:"SNAPSHOT_FILE"` // Persists the snapshot across restarts; memory only if empty
+	Interval time.Duration `yaml:"interval" env:"SNAPSHOT_INTERVAL" default:"30s"`
+}
+
+// MetricsSettings configure the Prometheus endpoint at /metrics
+type MetricsSettings struct {
+	Token    string `yaml:"token" env:"METRICS_TOKEN" secret:"true"`         // Bearer token required to scrape; open if empty, which production refuses
+	MaxFlags int    `yaml:"max_flags" env:"METRICS_MAX_FLAGS" default:"100"` // Distinct flags labelled in evaluation metrics
+}
+
+// redacted replaces secrets in printed settings
+const redacted = "********"
+
+// Load reads the settings from defaults, the CONFIG_FILE YAML file, .env and the environment,
+// and validates them. Every problem found is reported in the returned error.
+func Load() (*Settings, error) {
+	dotenv, err := godotenv.Read()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf(".env: %w", err)
+	}
+	lookup := func(name string) string {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+		return dotenv[name]
+	}
+	return load(lookup("CONFIG_FILE"), lookup)
+}
+
+func load(path string, lookup func(string) string) (*Settings, error) {
+	s := &Settings{}
+	var problems []error
+	fields(reflect.ValueOf(s).Elem(), func(f reflect.StructField, v reflect.Value) {
+		if def, ok := f.Tag.Lookup("default"); ok {
+			// Defaults are constants in this file, so a bad one is a programming error
+			if err := setField(v, def); err != nil {
+				panic(fmt.Sprintf("config: bad default for %s: %v", f.Tag.Get("env"), err))
+			}
+		}
+	})
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("CONFIG_FILE: %w", err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true) // Catch misspelled keys rather than silently ignoring them
+		if err := decoder.Decode(s); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	fields(reflect.ValueOf(s).Elem(), func(f reflect.StructField, v reflect.Value) {
+		name := f.Tag.Get("env")
+		if raw := lookup(name); raw != "" {
+			if err := setField(v, raw); err != nil {
+				problems = append(problems, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	})
+
+	problems = append(problems, s.validate()...)
+	if len(problems) > 0 {
+		return nil, errors.Join(problems...)
+	}
+	return s, nil
+}
+
+// validate reports every invalid or inconsistent setting, named by its environment variable
+func (s *Settings) validate() []error {
+	var problems []error
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if s.Env != EnvDevelopment && s.Env != EnvProduction {
+		problem("APP_ENV: must be %q or %q", EnvDevelopment, EnvProduction)
+	}
+	if s.Server.Port < 1 || s.Server.Port > 65535 {
+		problem("PORT: must be between 1 and 65535")
+	}
+	if s.Server.TrashRetentionDays < 1 {
+		problem("TRASH_RETENTION_DAYS: must be at least 1")
+	}
+	if s.Server.ShutdownDelay < 0 {
+		problem("SHUTDOWN_DELAY: must not be negative")
+	}
+	if s.Server.ShutdownTimeout <= 0 {
+		problem("SHUTDOWN_TIMEOUT: must be positive")
+	}
+
+	switch s.Database.Store {
+	case StorePostgres:
+		if s.Database.URL == "" {
+			problem("DATABASE_URL: required with STORE=postgres")
+		}
+		if s.Redis.URL == "" {
+			problem("REDIS_URL: required with STORE=postgres")
+		}
+	case StoreSQLite:
+		if s.Database.SQLitePath == "" {
+			problem("SQLITE_PATH: required with STORE=sqlite")
+		}
+		if s.Env == EnvProduction {
+			problem("STORE: sqlite is for local development only")
+		}
+	default:
+		problem("STORE: must be %q or %q", StorePostgres, StoreSQLite)
+	}
+	if s.Database.ConnectTimeout <= 0 {
+		problem("DB_CONNECT_TIMEOUT: must be positive")
+	}
+	if s.Redis.ConnectTimeout <= 0 {
+		problem("REDIS_CONNECT_TIMEOUT: must be positive")
+	}
+	if s.Snapshot.Interval <= 0 {
+		problem("SNAPSHOT_INTERVAL: must be positive")
+	}
+	if s.Metrics.MaxFlags < 0 {
+		problem("METRICS_MAX_FLAGS: must not be negative")
+	}
+	if s.Metrics.Token == "" && s.Env == EnvProduction {
+		problem("METRICS_TOKEN: required in production; without it /metrics is open to anyone")
+	}
+
+	if s.JWT.KeysDir == "" {
+		if s.JWT.ActiveKID != "" {
+			problem("JWT_ACTIVE_KID: requires JWT_KEYS_DIR")
+		}
+		if s.Env == EnvProduction {
+			problem("JWT_KEYS_DIR: required in production; an ephemeral signing key does not survive restarts")
+		}
+	} else if info, err := os.Stat(s.JWT.KeysDir); err != nil || !info.IsDir() {
+		problem("JWT_KEYS_DIR: %s is not a directory", s.JWT.KeysDir)
+	}
+	if s.JWT.Issuer == "" || s.JWT.Audience == "" {
+		problem("JWT_ISSUER and JWT_AUDIENCE: must not be empty")
+	}
+
+	if s.OIDC.Issuer != "" {
+		if s.OIDC.ClientID == "" || s.OIDC.RedirectURL == "" {
+			problem("OIDC_CLIENT_ID and OIDC_REDIRECT_URL: required with OIDC_ISSUER")
+		}
+		for _, pair := range strings.Split(s.OIDC.RoleMapping, ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+			if _, role, ok := strings.Cut(pair, "="); !ok {
+				problem("OIDC_ROLE_MAPPING: %q is not group=role", pair)
+			} else if models.RoleRank(strings.TrimSpace(role)) == 0 {
+				problem("OIDC_ROLE_MAPPING: %q maps to unknown role %q (use viewer, editor, admin or owner)", pair, strings.TrimSpace(role))
+			}
+		}
+		if s.OIDC.DefaultRole != "" && models.RoleRank(s.OIDC.DefaultRole) == 0 {
+			problem("OIDC_DEFAULT_ROLE: unknown role %q (use viewer, editor, admin or owner)", s.OIDC.DefaultRole)
+		}
+	}
+
+	if s.Auth.MFAEncryptionKey == "" {
+		if s.Env == EnvProduction {
+			problem("MFA_ENCRYPTION_KEY: required in production; an ephemeral key does not survive restarts")
+		}
+	} else if key, err := base64.StdEncoding.DecodeString(s.Auth.MFAEncryptionKey); err != nil || len(key) != 32 {
+		problem("MFA_ENCRYPTION_KEY: must be 32 bytes, base64-encoded (e.g. openssl rand -base64 32)")
+	}
+
+	if s.Passwords.MinLength < 1 {
+		problem("PASSWORD_MIN_LENGTH: must be at least 1")
+	}
+	if s.Passwords.History < 0 {
+		problem("PASSWORD_HISTORY: must not be negative")
+	}
+	if s.Passwords.BreachedList != "" {
+		if _, err := os.Stat(s.Passwords.BreachedList); err != nil {
+			problem("PASSWORD_BREACHED_LIST: %v", err)
+		}
+	}
+
+	switch s.Notifier.Kind {
+	case "log":
+	case "file":
+		if s.Notifier.File == "" {
+			problem("NOTIFIER_FILE: required with NOTIFIER=file")
+		}
+	default:
+		problem("NOTIFIER: must be \"log\" or \"file\"")
+	}
+
+	if (s.Bootstrap.AdminUsername == "") != (s.Bootstrap.AdminPassword == "") {
+		problem("BOOTSTRAP_ADMIN_USERNAME and BOOTSTRAP_ADMIN_PASSWORD: must be set together")
+	}
+	if s.Bootstrap.Organization == "" {
+		problem("BOOTSTRAP_ORGANIZATION: must not be empty")
+	}
+	return problems
+}
+
+// Redacted returns the settings as YAML with secrets masked and passwords removed from URLs
+func (s *Settings) Redacted() ([]byte, error) {
+	masked := *s
+	fields(reflect.ValueOf(&masked).Elem(), func(f reflect.StructField, v reflect.Value) {
+		if v.Kind() != reflect.String || v.String() == "" {
+			return
+		}
+		switch f.Tag.Get("secret") {
+		case "true":
+			v.SetString(redacted)
+		case "url":
+			if u, err := url.Parse(v.String()); err == nil {
+				v.SetString(u.Redacted())
+			}
+		}
+	})
+	return yaml.Marshal(&masked)
+}
+
+// fields calls fn for every leaf field, descending into nested settings structs
+func fields(v reflect.Value, fn func(reflect.StructField, reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Type.Kind() == reflect.Struct {
+			fields(v.Field(i), fn)
+			continue
+		}
+		fn(t.Field(i), v.Field(i))
+	}
+}
+
+func setField(v reflect.Value, raw string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int64:
+		if v.Type() != reflect.TypeOf(time.Duration(0)) {
+			return fmt.Errorf("unsupported setting type %s", v.Type())
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s", raw)
+		}
+		v.SetInt(int64(d))
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Kind())
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Package degraded tracks unavailable dependencies. While one is down the service runs in
+// degraded mode; while the database is down, evaluations are served from the flag snapshot and
+// API writes are refused.
+package degraded
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dependencies watched for degraded mode
+const (
+	Database = "database"
+	Redis    = "redis"
+)
+
+// PingTimeout bounds each dependency check made by the watcher
+const PingTimeout = 2 * time.Second
+
+// Outage describes a dependency that is currently unavailable
+type Outage struct {
+	Dependency string    `json:"dependency"`
+	Error      string    `json:"error"`
+	Since      time.Time `json:"since"`
+}
+
+var (
+	mu      sync.RWMutex
+	outages = map[string]Outage{}
+)
+
+// MarkDown records that a dependency is unavailable, keeping the time the outage started
+func MarkDown(dependency string, err error, now time.Time) {
+	mu.Lock()
+	defer mu.Unlock()
+	outage, ok := outages[dependency]
+	if !ok {
+		log.Printf("⚠️ %s unavailable, entering degraded mode: %v", dependency, err)
+		outage.Since = now
+	}
+	outage.Dependency, outage.Error = dependency, err.Error()
+	outages[dependency] = outage
+}
+
+// MarkUp records that a dependency is available again and reports whether it had been down
+func MarkUp(dependency string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	outage, ok := outages[dependency]
+	if ok {
+		log.Printf("✅ %s recovered after %s", dependency, time.Since(outage.Since).Round(time.Second))
+		delete(outages, dependency)
+	}
+	return ok
+}
+
+// Active reports whether any dependency is down
+func Active() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return len(outages) > 0
+}
+
+// Down reports whether a specific dependency is down
+func Down(dependency string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	_, ok := outages[dependency]
+	return ok
+}
+
+// Outages lists the current outages by dependency name
+func Outages() []Outage {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]Outage, 0, len(outages))
+	for _, outage := range outages {
+		list = append(list, outage)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Dependency < list[j].Dependency })
+	return list
+}
+
+// Summary describes the outages for error messages, e.g. "database unavailable"
+func Summary() string {
+	var names []string
+	for _, outage := range Outages() {
+		names = append(names, outage.Dependency)
+	}
+	return fmt.Sprintf("%s unavailable", strings.Join(names, " and "))
+}
+
+// Watch returns a scheduler job that pings each dependency and updates the outages.
+// onRecover, if set, runs with the name of each dependency that comes back after an outage.
+func Watch(pings map[string]func(ctx context.Context) error, onRecover func(dependency string)) func(now time.Time) error {
+	return func(now time.Time) error {
+		for name, ping := range pings {
+			ctx, cancel := context.WithTimeout(context.Background(), PingTimeout)
+			err := ping(ctx)
+			cancel()
+			if err != nil {
+				MarkDown(name, err, now)
+			} else if MarkUp(name) && onRecover != nil {
+				onRecover(name)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,138 @@
+package dependencies
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"feature-flag-service/internal/models"
+)
+
+var (
+	ErrInvalidPrerequisite = errors.New("invalid prerequisite")
+	ErrCycle               = errors.New("prerequisites would introduce a dependency cycle")
+)
+
+// Edge is a prerequisite relationship: From requires To to serve Variation
+type Edge struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Variation string `json:"variation"`
+}
+
+// Graph is the prerequisite graph of a set of flags
+type Graph struct {
+	Nodes []string `json:"nodes"`
+	Edges []Edge   `json:"edges"`
+}
+
+// Build creates the dependency graph for the given flags
+func Build(flags []models.FeatureFlag) Graph {
+	graph := Graph{Nodes: []string{}, Edges: []Edge{}}
+	for _, flag := range flags {
+		graph.Nodes = append(graph.Nodes, flag.Name)
+		for _, p := range flag.Prerequisites {
+			graph.Edges = append(graph.Edges, Edge{From: flag.Name, To: p.Flag, Variation: p.Variation})
+		}
+	}
+	sort.Strings(graph.Nodes)
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		return graph.Edges[i].To < graph.Edges[j].To
+	})
+	return graph
+}
+
+// DOT renders the graph in Graphviz format, with edges pointing at prerequisites
+func (g Graph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph flags {\n")
+	b.WriteString("  rankdir=LR;\n")
+	for _, node := range g.Nodes {
+		fmt.Fprintf(&b, "  %q;\n", node)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", e.From, e.To, e.Variation)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Validate checks a flag's prerequisites against the other flags and rejects cycles.
+// The candidate replaces any existing flag with the same ID in the set.
+func Validate(candidate *models.FeatureFlag, existing []models.FeatureFlag) error {
+	byName := map[string]*models.FeatureFlag{}
+	for i := range existing {
+		if existing[i].ID != candidate.ID || candidate.ID == 0 {
+			byName[existing[i].Name] = &existing[i]
+		}
+	}
+	byName[candidate.Name] = candidate
+
+	seen := map[string]bool{}
+	for _, p := range candidate.Prerequisites {
+		if p.Flag == "" {
+			return fmt.Errorf("%w: flag is required", ErrInvalidPrerequisite)
+		}
+		if p.Variation != models.VariationControl && p.Variation != models.VariationTreatment {
+			return fmt.Errorf("%w: variation for %q must be %q or %q", ErrInvalidPrerequisite, p.Flag,
+				models.VariationControl, models.VariationTreatment)
+		}
+		if seen[p.Flag] {
+			return fmt.Errorf("%w: %q is listed more than once", ErrInvalidPrerequisite, p.Flag)
+		}
+		seen[p.Flag] = true
+		if _, ok := byName[p.Flag]; !ok {
+			return fmt.Errorf("%w: flag %q does not exist", ErrInvalidPrerequisite, p.Flag)
+		}
+	}
+
+	if path := findCycle(candidate.Name, byName); path != nil {
+		return fmt.Errorf("%w: %s", ErrCycle, strings.Join(path, " -> "))
+	}
+	return nil
+}
+
+// Dependents lists the names of flags that declare the given flag as a prerequisite
+func Dependents(name string, flags []models.FeatureFlag) []string {
+	var names []string
+	for _, flag := range flags {
+		for _, p := range flag.Prerequisites {
+			if p.Flag == name {
+				names = append(names, flag.Name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// findCycle walks prerequisites depth-first from start and returns the path of a cycle back to start
+func findCycle(start string, byName map[string]*models.FeatureFlag) []string {
+	visited := map[string]bool{}
+	var walk func(name string, path []string) []string
+	walk = func(name string, path []string) []string {
+		flag, ok := byName[name]
+		if !ok {
+			return nil
+		}
+		for _, p := range flag.Prerequisites {
+			if p.Flag == start {
+				return append(path, p.Flag)
+			}
+			if visited[p.Flag] {
+				continue
+			}
+			visited[p.Flag] = true
+			if cycle := walk(p.Flag, append(path, p.Flag)); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+	return walk(start, []string{start})
+}
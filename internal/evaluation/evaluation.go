@@ -0,0 +1,123 @@
+package evaluation
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"feature-flag-service/internal/models"
+)
+
+// Evaluation reasons
+const (
+	ReasonOff             = "OFF"                 // Flag is disabled
+	ReasonArchived        = "ARCHIVED"            // Flag is archived and always serves control
+	ReasonPrerequisite    = "PREREQUISITE_FAILED" // A prerequisite flag did not serve the required variation
+	ReasonOutsideWindow   = "OUTSIDE_WINDOW"      // Now is before active_from or after active_until
+	ReasonOutsideSchedule = "OUTSIDE_SCHEDULE"    // Now does not match the recurring schedule
+	ReasonRolloutExcluded = "ROLLOUT_EXCLUDED"    // User is not in the rollout percentage
+	ReasonRollout         = "ROLLOUT"             // User is in the rollout percentage
+	ReasonOn              = "ON"                  // Flag is on for everyone
+)
+
+// maxPrerequisiteDepth guards against cycles that slipped past validation
+const maxPrerequisiteDepth = 16
+
+// Context describes who a flag is being evaluated for and when
+type Context struct {
+	Key    string                                // Stable user or entity key used for percentage bucketing
+	Now    time.Time                             // Evaluation time; schedules are checked against this
+	Lookup func(name string) *models.FeatureFlag // Resolves prerequisite flags by name; nil if missing
+}
+
+// Result is the outcome of evaluating a flag
+type Result struct {
+	Flag         string `json:"flag"`
+	Enabled      bool   `json:"enabled"`
+	Variation    string `json:"variation"`
+	Reason       string `json:"reason"`
+	Prerequisite string `json:"prerequisite,omitempty"` // Failed prerequisite when Reason is PREREQUISITE_FAILED
+}
+
+// ValidateConditions checks a flag's activation window and schedule
+func ValidateConditions(flag *models.FeatureFlag) error {
+	if flag.ActiveFrom != nil && flag.ActiveUntil != nil && !flag.ActiveUntil.After(*flag.ActiveFrom) {
+		return fmt.Errorf("%w: active_until must be after active_from", ErrInvalidSchedule)
+	}
+	if flag.Schedule != "" {
+		if _, err := ParseSchedule(flag.Schedule, flag.TimeZone); err != nil {
+			return err
+		}
+	} else if _, err := LoadTimeZone(flag.TimeZone); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Evaluate decides whether a flag is on for the given context
+func Evaluate(flag *models.FeatureFlag, ctx Context) Result {
+	if ctx.Now.IsZero() {
+		ctx.Now = time.Now()
+	}
+	return evaluate(flag, ctx, 0)
+}
+
+func evaluate(flag *models.FeatureFlag, ctx Context, depth int) Result {
+	if flag.State == models.FlagStateArchived {
+		return off(flag, ReasonArchived)
+	}
+	if !flag.IsEnabled {
+		return off(flag, ReasonOff)
+	}
+
+	// Prerequisites are checked before the flag's own rules
+	for _, p := range flag.Prerequisites {
+		var parent *models.FeatureFlag
+		if ctx.Lookup != nil && depth < maxPrerequisiteDepth {
+			parent = ctx.Lookup(p.Flag)
+		}
+		if parent == nil || evaluate(parent, ctx, depth+1).Variation != p.Variation {
+			result := off(flag, ReasonPrerequisite)
+			result.Prerequisite = p.Flag
+			return result
+		}
+	}
+
+	if flag.ActiveFrom != nil && ctx.Now.Before(*flag.ActiveFrom) {
+		return off(flag, ReasonOutsideWindow)
+	}
+	if flag.ActiveUntil != nil && !ctx.Now.Before(*flag.ActiveUntil) {
+		return off(flag, ReasonOutsideWindow)
+	}
+
+	if flag.Schedule != "" {
+		schedule, err := ParseSchedule(flag.Schedule, flag.TimeZone)
+		if err != nil || !schedule.Active(ctx.Now) {
+			return off(flag, ReasonOutsideSchedule)
+		}
+	}
+
+	if flag.RolloutPercentage != nil {
+		if bucket(flag.Name, ctx.Key) >= *flag.RolloutPercentage {
+			return off(flag, ReasonRolloutExcluded)
+		}
+		return on(flag, ReasonRollout)
+	}
+
+	return on(flag, ReasonOn)
+}
+
+// bucket deterministically maps a user to 0-99 for a given flag
+func bucket(flagName, key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(flagName + "/" + key))
+	return int(h.Sum32() % 100)
+}
+
+func on(flag *models.FeatureFlag, reason string) Result {
+	return Result{Flag: flag.Name, Enabled: true, Variation: models.VariationTreatment, Reason: reason}
+}
+
+func off(flag *models.FeatureFlag, reason string) Result {
+	return Result{Flag: flag.Name, Enabled: false, Variation: models.VariationControl, Reason: reason}
+}
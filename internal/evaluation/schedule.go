@@ -0,0 +1,135 @@
+package evaluation
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Embed IANA zones so schedules work in minimal containers
+)
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// Schedule is a cron-like recurring activation pattern. Unlike cron, which fires at the
+// matching minutes, a schedule is a predicate: the flag is active during every minute that
+// matches all five fields (minute hour day-of-month month day-of-week).
+// For example "* 9-16 * * 1-5" is active on weekdays from 09:00 to 16:59.
+type Schedule struct {
+	minute, hour, dom, month, dow fieldSet
+	location                      *time.Location
+}
+
+type fieldSet map[int]bool
+
+type fieldSpec struct {
+	name     string
+	min, max int
+}
+
+var fieldSpecs = [5]fieldSpec{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// ParseSchedule parses a five-field expression evaluated in the given IANA time zone (UTC if empty)
+func ParseSchedule(expr, timeZone string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 fields (minute hour day-of-month month day-of-week), got %d", ErrInvalidSchedule, len(fields))
+	}
+
+	location, err := LoadTimeZone(timeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make([]fieldSet, 5)
+	for i, field := range fields {
+		if sets[i], err = parseField(field, fieldSpecs[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Schedule{
+		minute:   sets[0],
+		hour:     sets[1],
+		dom:      sets[2],
+		month:    sets[3],
+		dow:      sets[4],
+		location: location,
+	}, nil
+}
+
+// LoadTimeZone resolves an IANA time zone name, defaulting to UTC
+func LoadTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalidSchedule, name)
+	}
+	return location, nil
+}
+
+// Active reports whether the given instant falls inside the schedule
+func (s *Schedule) Active(at time.Time) bool {
+	t := at.In(s.location)
+	return s.minute[t.Minute()] &&
+		s.hour[t.Hour()] &&
+		s.dom[t.Day()] &&
+		s.month[int(t.Month())] &&
+		s.dow[int(t.Weekday())]
+}
+
+// parseField expands "*", "a", "a-b", "*/n", "a-b/n" and comma separated lists of those
+func parseField(field string, spec fieldSpec) (fieldSet, error) {
+	set := fieldSet{}
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("%w: bad step in %s field %q", ErrInvalidSchedule, spec.name, field)
+			}
+			rangePart, step = part[:i], n
+		}
+
+		lo, hi := spec.min, spec.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("%w: bad value in %s field %q", ErrInvalidSchedule, spec.name, field)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("%w: bad value in %s field %q", ErrInvalidSchedule, spec.name, field)
+				}
+			}
+		}
+
+		// Allow 7 as an alias for Sunday, as cron does
+		if spec.name == "day of week" && hi == 7 {
+			set[0] = true
+			if lo == 7 {
+				lo, hi = 0, 0
+			} else {
+				hi = 6
+			}
+		}
+
+		if lo < spec.min || hi > spec.max || lo > hi {
+			return nil, fmt.Errorf("%w: %s field %q is out of range %d-%d", ErrInvalidSchedule, spec.name, field, spec.min, spec.max)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
@@ -0,0 +1,112 @@
+package guardrails
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"feature-flag-service/internal/models"
+)
+
+// DefaultWindow is used when a guardrail does not set its own window
+const DefaultWindow = 5 * time.Minute
+
+var ErrInvalidGuardrail = errors.New("invalid guardrail")
+
+// Event is a health observation for one variation of a flag
+type Event struct {
+	Variation string
+	Metric    string
+	Value     float64
+	At        time.Time
+}
+
+// Breach describes a guardrail the treatment variation has exceeded
+type Breach struct {
+	Guardrail models.Guardrail
+	Observed  float64
+	Samples   int
+	At        time.Time
+}
+
+// Reason renders the breach as a human readable history entry
+func (b *Breach) Reason() string {
+	return fmt.Sprintf("%s %s of %s was %.2f over %d sample(s), above threshold %.2f",
+		models.VariationTreatment, b.Guardrail.Aggregation, b.Guardrail.Metric,
+		b.Observed, b.Samples, b.Guardrail.Threshold)
+}
+
+// Validate checks a guardrail definition
+func Validate(g models.Guardrail) error {
+	if g.Metric == "" {
+		return fmt.Errorf("%w: metric is required", ErrInvalidGuardrail)
+	}
+	switch g.Aggregation {
+	case models.AggregationMean, models.AggregationSum, models.AggregationMax:
+	default:
+		return fmt.Errorf("%w: aggregation must be one of mean, sum or max", ErrInvalidGuardrail)
+	}
+	if g.Window != "" {
+		if d, err := time.ParseDuration(g.Window); err != nil || d <= 0 {
+			return fmt.Errorf("%w: window must be a positive duration such as \"5m\"", ErrInvalidGuardrail)
+		}
+	}
+	if g.MinSamples < 0 {
+		return fmt.Errorf("%w: min_samples cannot be negative", ErrInvalidGuardrail)
+	}
+	return nil
+}
+
+// Window returns the lookback period for a guardrail
+func Window(g models.Guardrail) time.Duration {
+	if d, err := time.ParseDuration(g.Window); err == nil && d > 0 {
+		return d
+	}
+	return DefaultWindow
+}
+
+// Check evaluates every guardrail against the treatment events inside its window ending at now,
+// returning the first breach found or nil if all guardrails hold
+func Check(rules []models.Guardrail, events []Event, now time.Time) *Breach {
+	for _, rule := range rules {
+		since := now.Add(-Window(rule))
+
+		var values []float64
+		for _, e := range events {
+			if e.Variation != models.VariationTreatment || e.Metric != rule.Metric {
+				continue
+			}
+			if e.At.After(since) && !e.At.After(now) {
+				values = append(values, e.Value)
+			}
+		}
+
+		if len(values) == 0 || len(values) < rule.MinSamples {
+			continue
+		}
+
+		observed := aggregate(rule.Aggregation, values)
+		if observed > rule.Threshold {
+			return &Breach{Guardrail: rule, Observed: observed, Samples: len(values), At: now}
+		}
+	}
+	return nil
+}
+
+func aggregate(aggregation string, values []float64) float64 {
+	var result float64
+	for i, v := range values {
+		switch aggregation {
+		case models.AggregationMax:
+			if i == 0 || v > result {
+				result = v
+			}
+		default:
+			result += v
+		}
+	}
+	if aggregation == models.AggregationMean {
+		result /= float64(len(values))
+	}
+	return result
+}
@@ -0,0 +1,109 @@
+package guardrails
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/rollouts"
+)
+
+// SignalRetention is how long ingested health signals are kept
+const SignalRetention = 24 * time.Hour
+
+var ErrInvalidSignal = errors.New("invalid health signal")
+
+// Ingest stores health signals for a flag and checks its guardrails, rolling the flag
+// back if the treatment variation is in breach. The breach, if any, is returned.
+func Ingest(flagID uint, signals []models.HealthSignal, now time.Time) (*Breach, error) {
+	for i := range signals {
+		s := &signals[i]
+		if s.Variation != models.VariationControl && s.Variation != models.VariationTreatment {
+			return nil, fmt.Errorf("%w: variation must be %q or %q", ErrInvalidSignal,
+				models.VariationControl, models.VariationTreatment)
+		}
+		if s.Metric == "" {
+			return nil, fmt.Errorf("%w: metric is required", ErrInvalidSignal)
+		}
+		s.ID = 0
+		s.FeatureFlagID = flagID
+		if s.ObservedAt.IsZero() {
+			s.ObservedAt = now
+		}
+	}
+
+	if len(signals) > 0 {
+		if err := config.DB.Create(&signals).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return Enforce(flagID, now)
+}
+
+// Enforce checks a flag's guardrails while it has an active rollout and aborts the
+// rollout, recording the reason in the flag's history, when one is breached
+func Enforce(flagID uint, now time.Time) (*Breach, error) {
+	if _, err := rollouts.ActivePlan(config.DB, flagID); err != nil {
+		if errors.Is(err, rollouts.ErrNoActivePlan) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var rules []models.Guardrail
+	if err := config.DB.Where("feature_flag_id = ?", flagID).Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	lookback := DefaultWindow
+	for _, rule := range rules {
+		if w := Window(rule); w > lookback {
+			lookback = w
+		}
+	}
+
+	var signals []models.HealthSignal
+	if err := config.DB.Where("feature_flag_id = ? AND variation = ? AND observed_at > ?",
+		flagID, models.VariationTreatment, now.Add(-lookback)).Find(&signals).Error; err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, len(signals))
+	for i, s := range signals {
+		events[i] = Event{Variation: s.Variation, Metric: s.Metric, Value: s.Value, At: s.ObservedAt}
+	}
+
+	breach := Check(rules, events, now)
+	if breach == nil {
+		return nil, nil
+	}
+
+	if _, err := rollouts.Abort(flagID); err != nil {
+		return nil, err
+	}
+
+	entry := models.FlagHistory{
+		FeatureFlagID: flagID,
+		Action:        models.HistoryGuardrailRollback,
+		Reason:        breach.Reason(),
+		Actor:         "guardrails",
+	}
+	if err := config.DB.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+
+	log.Printf("🚨 Rolled back feature flag %d: %s", flagID, entry.Reason)
+	return breach, nil
+}
+
+// PruneSignals deletes health signals older than the retention period
+func PruneSignals(now time.Time) error {
+	return config.DB.Where("observed_at < ?", now.Add(-SignalRetention)).Delete(&models.HealthSignal{}).Error
+}
@@ -0,0 +1,23 @@
+package guardrails
+
+import (
+	"sort"
+
+	"feature-flag-service/internal/models"
+)
+
+// Replay feeds a synthetic event stream through the detector in time order, checking the
+// guardrails after each event exactly as ingestion does, and returns the first breach.
+// It lets detection thresholds be tuned locally without a database or a live rollout.
+func Replay(rules []models.Guardrail, events []Event) *Breach {
+	stream := make([]Event, len(events))
+	copy(stream, events)
+	sort.SliceStable(stream, func(i, j int) bool { return stream[i].At.Before(stream[j].At) })
+
+	for i := range stream {
+		if breach := Check(rules, stream[:i+1], stream[i].At); breach != nil {
+			return breach
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/policy"
+	"feature-flag-service/internal/tokens"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessTokenRequest represents the expected body for creating an access token
+type AccessTokenRequest struct {
+	Name      string     `json:"name" binding:"required" example:"github-actions"`
+	Scopes    []string   `json:"scopes" example:"flag:read,flag:toggle"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// AccessTokenResponse includes the plaintext token, which is only ever shown once
+type AccessTokenResponse struct {
+	models.AccessToken
+	Token string `json:"token"`
+}
+
+// GetAccessTokens lists the caller's personal access tokens
+// @Summary List personal access tokens
+// @Description Lists the caller's personal access tokens, including revoked ones; secrets are never returned
+// @Tags Access Tokens
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.AccessToken
+// @Failure 403 {object} map[string]string
+// @Router /api/tokens [get]
+func GetAccessTokens(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var accessTokens []models.AccessToken
+	if err := config.DB.Where("user_id = ?", user.ID).Order("created_at").Find(&accessTokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve access tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, accessTokens)
+}
+
+// CreateAccessToken issues a personal access token for the caller
+// @Summary Create a personal access token
+// @Description Issues a token that acts with the caller's roles, optionally limited to action scopes and an expiry; the token is shown only once
+// @Tags Access Tokens
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param token body AccessTokenRequest true "Token name, scopes and expiry"
+// @Success 201 {object} AccessTokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/tokens [post]
+func CreateAccessToken(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	createAccessToken(c, &models.AccessToken{UserID: &user.ID})
+}
+
+// RevokeAccessToken revokes one of the caller's personal access tokens
+// @Summary Revoke a personal access token
+// @Description Revokes a token immediately; it stays listed with its revoked_at time
+// @Tags Access Tokens
+// @Security BearerAuth
+// @Param id path int true "Access token ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/tokens/{id} [delete]
+func RevokeAccessToken(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var token models.AccessToken
+	if err := config.DB.Where("user_id = ?", user.ID).First(&token, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": tokens.ErrTokenNotFound.Error()})
+		return
+	}
+
+	revokeAccessToken(c, &token)
+}
+
+// createAccessToken binds the request into a token whose owner is already set and responds with the secret
+func createAccessToken(c *gin.Context, token *models.AccessToken) {
+	var input AccessTokenRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := policy.ValidateScopes(input.Scopes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token.Name = input.Name
+	token.Scopes = input.Scopes
+	token.ExpiresAt = input.ExpiresAt
+
+	plaintext, err := tokens.Create(token, time.Now())
+	if errors.Is(err, tokens.ErrInvalidExpiry) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create access token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, AccessTokenResponse{AccessToken: *token, Token: plaintext})
+}
+
+func revokeAccessToken(c *gin.Context, token *models.AccessToken) {
+	if err := tokens.Revoke(token, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke access token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Access token revoked successfully"})
+}
+
+// currentUser loads the user making the request; service accounts have no user record
+func currentUser(c *gin.Context) (*models.User, bool) {
+	claims, ok := middleware.CurrentClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	query := config.DB.Where("username = ?", claims.Username)
+	if claims.TokenID != 0 {
+		// Resolve token callers through the token, since service account names are not usernames
+		query = config.DB.Where("id = (?)", config.DB.Model(&models.AccessToken{}).Select("user_id").Where("id = ?", claims.TokenID))
+	}
+
+	var user models.User
+	if err := query.First(&user).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Personal access tokens are only available to users"})
+		return nil, false
+	}
+	return &user, true
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+
+	"feature-flag-service/internal/audit"
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/loginguard"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditPageSize caps how many audit entries one request returns
+const auditPageSize = 200
+
+// GetAuditLog lists recent security events
+// @Summary List audit entries
+// @Description Lists recent security events such as failed and locked logins, newest first. Only owners see events that involve no member of their organization.
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param action query string false "Filter by action, e.g. login_failed"
+// @Param target query string false "Filter by target username"
+// @Success 200 {array} models.AuditEntry
+// @Failure 500 {object} map[string]string
+// @Router /api/audit [get]
+func GetAuditLog(c *gin.Context) {
+	query := config.DB.Order("created_at DESC").Limit(auditPageSize)
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if target := c.Query("target"); target != "" {
+		query = query.Where("target = ?", target)
+	}
+	if org := middleware.OrganizationFilter(c); org != nil {
+		members := store.OrganizationQuery(config.DB.Unscoped().Model(&models.User{}).Select("username"), org)
+		query = query.Where("actor IN (?) OR target IN (?)", members, members)
+	}
+
+	var entries []models.AuditEntry
+	if err := query.Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// UnlockUser clears a user's failed login attempts and lockout
+// @Summary Unlock a user account
+// @Description Clears failed login attempts and any lockout for the user; lockouts of the caller's IP are not affected
+// @Tags Users
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/users/{id}/unlock [post]
+func UnlockUser(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil || !visible(c, user.OrganizationID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := loginguard.Unlock(user.Username); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+
+	claims, _ := middleware.CurrentClaims(c)
+	audit.Record(models.AuditEntry{Action: models.AuditAccountUnlock, Actor: claims.Username, Target: user.Username, IPAddress: c.ClientIP()})
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
@@ -1,14 +1,40 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
+	"feature-flag-service/internal/audit"
 	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/loginguard"
+	"feature-flag-service/internal/mfa"
+	"feature-flag-service/internal/middleware"
 	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/passwords"
+	"feature-flag-service/internal/sessions"
 	"feature-flag-service/internal/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// AuthHandler serves registration, sign-in and second-factor enrollment, signing tokens and
+// checking codes with the settings it was created with
+type AuthHandler struct {
+	Tokens           *utils.TokenIssuer
+	MFA              *mfa.Authenticator
+	OpenRegistration bool // Anyone may sign up through /register without an invite
+}
+
+// NewAuthHandler creates an AuthHandler; openRegistration comes from ALLOW_OPEN_REGISTRATION
+func NewAuthHandler(tokens *utils.TokenIssuer, authenticator *mfa.Authenticator, openRegistration bool) *AuthHandler {
+	return &AuthHandler{Tokens: tokens, MFA: authenticator, OpenRegistration: openRegistration}
+}
+
 // RegisterRequest represents the expected body for user registration
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required"`
@@ -17,20 +43,23 @@ type RegisterRequest struct {
 
 // Register handles user registration
 // @Summary Register a new user
-// @Description Creates a new user account
+// @Description Creates a viewer account when open registration is enabled (ALLOW_OPEN_REGISTRATION=true); otherwise users join through invites
 // @Tags Authentication
 // @Accept json
 // @Produce json
 // @Param user body RegisterRequest true "User registration details"
 // @Success 201 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 409 {object} map[string]string
 // @Router /register [post]
-func Register(c *gin.Context) {
-	var input struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+func (h *AuthHandler) Register(c *gin.Context) {
+	if !h.OpenRegistration {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Registration is by invitation only"})
+		return
 	}
+
+	var input RegisterRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -42,20 +71,32 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	// Hash password
-	hashedPassword, err := utils.HashPassword(input.Password)
+	// Check the password policy and hash the password
+	hashedPassword, err := passwords.Hash(input.Username, input.Password)
+	if passwords.IsRejected(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
 		return
 	}
 
+	// Self-registered accounts are always viewers; the first owner comes from the bootstrap configuration
 	user := models.User{
 		Username: input.Username,
 		Password: hashedPassword,
+		Role:     models.RoleViewer,
 	}
 
 	// Save user
-	if err := config.DB.Create(&user).Error; err != nil {
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return passwords.Remember(tx, user.ID, hashedPassword, time.Now())
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
 		return
 	}
@@ -71,16 +112,17 @@ type LoginRequest struct {
 
 // Login handles user authentication
 // @Summary User login
-// @Description Authenticates a user and returns a JWT token
+// @Description Authenticates a user and starts a session, returning a short-lived JWT and a refresh token. Users with two-factor authentication get an MFA challenge token instead; complete it at /login/mfa.
 // @Tags Authentication
 // @Accept json
 // @Produce json
 // @Param credentials body LoginRequest true "User credentials"
-// @Success 200 {object} map[string]string
+// @Success 200 {object} TokenResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Router /login [post]
-func Login(c *gin.Context) {
+func (h *AuthHandler) Login(c *gin.Context) {
 	var input struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
@@ -90,25 +132,221 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	if !loginAllowed(c, input.Username) {
+		return
+	}
+
 	var user models.User
 	if err := config.DB.Where("username = ?", input.Username).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		// Spend the same time as a real password check so response times do not reveal whether the user exists
+		utils.CheckPassword(dummyPasswordHash, input.Password)
+		loginFailed(c, input.Username, models.AuditLoginFailed, "unknown username")
 		return
 	}
 
 	// Compare password hashes
 	if err := utils.CheckPassword(user.Password, input.Password); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		loginFailed(c, input.Username, models.AuditLoginFailed, "wrong password")
+		return
+	}
+
+	if user.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": errAccountDisabled.Error()})
+		return
+	}
+
+	// Users with a second factor, or whose role requires one, get a challenge instead of a session.
+	// Their failed attempts are only forgotten once LoginMFA accepts the second factor.
+	required, err := mfa.Required(&user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check MFA requirements"})
+		return
+	}
+	if user.MFAEnabled || required {
+		challenge, err := mfa.IssueChallenge(&user, !user.MFAEnabled)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		c.JSON(http.StatusOK, MFAChallengeResponse{MFARequired: true, MFAToken: challenge, EnrollmentRequired: !user.MFAEnabled})
+		return
+	}
+
+	loginSucceeded(user.Username)
+	h.startSession(c, &user, nil)
+}
+
+// RefreshRequest represents the expected body for refreshing a session
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenResponse is returned when a session is started or refreshed
+type TokenResponse struct {
+	Token         string   `json:"token"`
+	RefreshToken  string   `json:"refresh_token"`
+	ExpiresIn     int      `json:"expires_in" example:"900"` // Access token lifetime in seconds
+	RecoveryCodes []string `json:"recovery_codes,omitempty"` // Shown once, when MFA is set up during login
+}
+
+// Refresh exchanges a refresh token for a new access token
+// @Summary Refresh an access token
+// @Description Exchanges a refresh token for a new access token and a new refresh token; each refresh token works once, and reusing one revokes the session
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param refresh body RefreshRequest true "Refresh token"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var input RefreshRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, refresh, err := sessions.Refresh(input.RefreshToken, time.Now())
+	if errors.Is(err, sessions.ErrInvalidRefreshToken) || errors.Is(err, sessions.ErrRefreshTokenReused) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.Where("disabled = ?", false).First(&user, session.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": sessions.ErrInvalidRefreshToken.Error()})
+		return
+	}
+
+	h.issueTokens(c, &user, session, refresh, nil)
+}
+
+// Logout ends the current session
+// @Summary Log out
+// @Description Revokes the current session and access token; the refresh token stops working immediately
+// @Tags Authentication
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /api/logout [post]
+func Logout(c *gin.Context) {
+	claims, ok := middleware.CurrentClaims(c)
+	if !ok || claims.SessionID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only session tokens can be logged out; revoke access tokens instead"})
+		return
+	}
+
+	now := time.Now()
+	var session models.Session
+	if err := config.DB.First(&session, claims.SessionID).Error; err == nil {
+		if err := sessions.Revoke(&session, now); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+			return
+		}
+	}
+	if err := sessions.RevokeAccessToken(claims, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+var errAccountDisabled = errors.New("account is disabled")
+
+// dummyPasswordHash is compared against when a username does not exist
+var dummyPasswordHash, _ = utils.HashPassword("not-a-real-password")
+
+// loginAllowed rejects attempts for a locked username or IP with 429. The response is the same
+// whether or not the username exists.
+func loginAllowed(c *gin.Context, username string) bool {
+	status, err := loginguard.Check(username, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Login is temporarily unavailable"})
+		return false
+	}
+	if status.Locked {
+		audit.Record(models.AuditEntry{Action: models.AuditLoginLocked, Target: username, IPAddress: c.ClientIP(),
+			Detail: "attempt while locked out"})
+		respondLocked(c, status)
+		return false
+	}
+	return true
+}
+
+// loginFailed counts a failed attempt, records it, and responds without saying which check failed
+func loginFailed(c *gin.Context, username, action, detail string) {
+	audit.Record(models.AuditEntry{Action: action, Target: username, IPAddress: c.ClientIP(), Detail: detail})
+
+	status, err := loginguard.Fail(username, c.ClientIP())
+	if err != nil {
+		log.Printf("⚠️ Failed to count login attempt for %s: %v", username, err)
+	}
+	if status.Locked {
+		audit.Record(models.AuditEntry{Action: models.AuditLoginLocked, Target: username, IPAddress: c.ClientIP(),
+			Detail: fmt.Sprintf("locked for %s", status.RetryAfter.Round(time.Second))})
+	}
+
+	message := "Invalid username or password"
+	if action == models.AuditMFAFailed {
+		message = mfa.ErrInvalidCode.Error()
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": message})
+}
+
+// loginSucceeded forgets a username's failed attempts once every login step has passed
+func loginSucceeded(username string) {
+	if err := loginguard.Succeed(username); err != nil {
+		log.Printf("⚠️ Failed to reset login attempts for %s: %v", username, err)
+	}
+}
+
+func respondLocked(c *gin.Context, status loginguard.Status) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(status.RetryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts; try again later"})
+}
+
+// startSession opens a session for a fully authenticated user and responds with its tokens
+func (h *AuthHandler) startSession(c *gin.Context, user *models.User, recoveryCodes []string) {
+	now := time.Now()
+	session, refresh, err := sessions.Start(user.ID, c.Request.UserAgent(), c.ClientIP(), now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		return
+	}
+
+	user.LastLoginAt = &now
+	if err := config.DB.Model(user).UpdateColumn("last_login_at", now).Error; err != nil {
+		log.Printf("⚠️ Failed to record last login of %s: %v", user.Username, err)
+	}
+
+	h.issueTokens(c, user, session, refresh, recoveryCodes)
+}
+
+// issueTokens signs an access token carrying the user's current roles for a session
+func (h *AuthHandler) issueTokens(c *gin.Context, user *models.User, session *models.Session, refresh string, recoveryCodes []string) {
+	var bindings []models.RoleBinding
+	if err := config.DB.Where("user_id = ?", user.ID).Find(&bindings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user roles"})
 		return
 	}
 
 	// Generate JWT
-	token, err := utils.GenerateJWT(user.Username)
+	token, err := h.Tokens.GenerateJWT(user.Username, user.Role, user.OrganizationID, utils.ScopesFromBindings(bindings), session.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, TokenResponse{
+		Token:         token,
+		RefreshToken:  refresh,
+		ExpiresIn:     int(utils.AccessTokenTTL.Seconds()),
+		RecoveryCodes: recoveryCodes,
+	})
 }
-
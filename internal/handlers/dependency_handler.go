@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/dependencies"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDependencyGraph returns the prerequisite graph of the flags the caller may read
+// @Summary Get the flag dependency graph
+// @Description Returns flag prerequisites as JSON nodes and edges, or as Graphviz DOT with format=dot; edges may point at prerequisites outside the selected project or environment
+// @Tags Feature Flags
+// @Produce json
+// @Produce plain
+// @Security BearerAuth
+// @Param format query string false "Output format: json (default) or dot"
+// @Param project query string false "Project"
+// @Param environment query string false "Environment"
+// @Success 200 {object} dependencies.Graph
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/dependency-graph [get]
+func GetDependencyGraph(c *gin.Context) {
+	query := config.DB
+	requested, _ := middleware.RequestResource(c, "")
+	if requested.Project != "" {
+		query = query.Where("project = ?", requested.Project)
+	}
+	if requested.Environment != "" {
+		query = query.Where("environment = ?", requested.Environment)
+	}
+
+	var flags []models.FeatureFlag
+	if err := query.Find(&flags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve feature flags"})
+		return
+	}
+
+	graph := dependencies.Build(readableFlags(c, flags))
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, graph)
+	case "dot":
+		c.Data(http.StatusOK, "text/vnd.graphviz; charset=utf-8", []byte(graph.DOT()))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or dot"})
+	}
+}
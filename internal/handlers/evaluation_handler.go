@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/degraded"
+	"feature-flag-service/internal/evaluation"
+	"feature-flag-service/internal/lifecycle"
+	"feature-flag-service/internal/metrics"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/store"
+	"feature-flag-service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// EvaluateFeatureFlag evaluates a flag for a user at request time
+// @Summary Evaluate a feature flag
+// @Description Evaluates a flag's prerequisites, window, schedule and rollout for a user and returns the result with its reason
+// @Tags Evaluation
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Feature flag ID"
+// @Param key query string false "User key used for percentage rollouts"
+// @Success 200 {object} evaluation.Result
+// @Failure 404 {object} map[string]string
+// @Router /api/flags/{id}/evaluate [get]
+func EvaluateFeatureFlag(c *gin.Context) {
+	featureFlag, lookup := findFlagForEvaluation(c)
+	if featureFlag == nil || !visible(c, featureFlag.OrganizationID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Feature flag not found"})
+		return
+	}
+
+	now := time.Now()
+	result := evaluation.Evaluate(featureFlag, evaluation.Context{
+		Key:    c.Query("key"),
+		Now:    now,
+		Lookup: lookup,
+	})
+	metrics.RecordEvaluation(result.Flag, result.Variation, result.Reason)
+
+	if err := lifecycle.RecordEvaluation(featureFlag, result.Variation, now); err != nil {
+		log.Printf("⚠️ Failed to record evaluation of flag %d: %v", featureFlag.ID, err)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetStaleFlags reports flags that are candidates for cleanup
+// @Summary Report stale feature flags
+// @Description Lists flags that have served a single variation for N days or have not been evaluated at all
+// @Tags Feature Flags
+// @Produce json
+// @Security BearerAuth
+// @Param days query int false "Days without variation changes (default 30)"
+// @Success 200 {array} lifecycle.Candidate
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/reports/stale-flags [get]
+func GetStaleFlags(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a positive integer"})
+		return
+	}
+
+	var featureFlags []models.FeatureFlag
+	if err := config.DB.Find(&featureFlags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve feature flags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, lifecycle.StaleCandidates(readableFlags(c, featureFlags), days, time.Now()))
+}
+
+// findFlagForEvaluation loads the flag named by the id parameter with a lookup for its
+// prerequisites, both from the last snapshot while the database is unavailable
+func findFlagForEvaluation(c *gin.Context) (*models.FeatureFlag, func(string) *models.FeatureFlag) {
+	if !degraded.Down(degraded.Database) {
+		var featureFlag models.FeatureFlag
+		err := config.DB.First(&featureFlag, c.Param("id")).Error
+		if err == nil {
+			return &featureFlag, flagsByName(featureFlag.OrganizationID)
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, nil
+	}
+	snap := servedSnapshot(c)
+	if snap == nil {
+		return nil, nil
+	}
+	featureFlag := snap.Find(uint(id))
+	if featureFlag == nil {
+		return nil, nil
+	}
+	return featureFlag, snap.Lookup(featureFlag.OrganizationID)
+}
+
+// flagsByName resolves prerequisite flags during evaluation among the flags of the evaluated
+// flag's organization, nil for none, since names are only unique within an organization
+func flagsByName(organization *uint) func(name string) *models.FeatureFlag {
+	org := utils.OrganizationOf(organization)
+	return func(name string) *models.FeatureFlag {
+		var featureFlag models.FeatureFlag
+		if err := store.OrganizationQuery(config.DB, &org).Where("name = ?", name).First(&featureFlag).Error; err != nil {
+			return nil
+		}
+		return &featureFlag
+	}
+}
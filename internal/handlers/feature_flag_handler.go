@@ -1,18 +1,70 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
-	"feature-flag-service/internal/config"
+	"strconv"
+	"time"
+	"feature-flag-service/internal/dependencies"
+	"feature-flag-service/internal/evaluation"
+	"feature-flag-service/internal/lifecycle"
+	"feature-flag-service/internal/middleware"
 	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/policy"
+	"feature-flag-service/internal/store"
+	"feature-flag-service/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-// FeatureFlagRequest represents the expected body for creating a feature flag
+// FlagHandler serves the feature flag CRUD endpoints from an injected store
+type FlagHandler struct {
+	Flags store.FlagStore
+}
+
+// NewFlagHandler creates a FlagHandler backed by the given store
+func NewFlagHandler(flags store.FlagStore) *FlagHandler {
+	return &FlagHandler{Flags: flags}
+}
+
+// FeatureFlagRequest represents the expected body for creating or updating a feature flag.
+// Usage timestamps and IDs are maintained by the service and cannot be set by clients.
 type FeatureFlagRequest struct {
-	Name        string `json:"name" binding:"required"`
-	Description string `json:"description"`
-	IsEnabled   bool   `json:"is_enabled"`
+	Name              string     `json:"name" binding:"required"`
+	Project           string     `json:"project" example:"checkout"`       // Defaults to the request's X-Project
+	Environment       string     `json:"environment" example:"production"` // Defaults to the request's X-Environment
+	Description       string     `json:"description"`
+	IsEnabled         bool       `json:"is_enabled"`
+	State             string     `json:"state" example:"active"`
+	Tags              []string   `json:"tags" example:"mobile"`
+	ClientVisible     bool       `json:"client_visible"`
+	RolloutPercentage *int       `json:"rollout_percentage"`
+	ActiveFrom        *time.Time `json:"active_from"`
+	ActiveUntil       *time.Time `json:"active_until"`
+	Schedule          string     `json:"schedule" example:"* 9-16 * * 1-5"`
+	TimeZone          string     `json:"time_zone" example:"Europe/Berlin"`
+
+	Prerequisites []models.Prerequisite `json:"prerequisites"`
+}
+
+// flagRequest returns the editable fields of a flag, so an update only changes the fields it sends
+func flagRequest(flag *models.FeatureFlag) FeatureFlagRequest {
+	return FeatureFlagRequest{
+		Name: flag.Name, Project: flag.Project, Environment: flag.Environment, Description: flag.Description, IsEnabled: flag.IsEnabled, State: flag.State,
+		Tags: flag.Tags, ClientVisible: flag.ClientVisible, RolloutPercentage: flag.RolloutPercentage,
+		ActiveFrom: flag.ActiveFrom, ActiveUntil: flag.ActiveUntil, Schedule: flag.Schedule,
+		TimeZone: flag.TimeZone, Prerequisites: flag.Prerequisites,
+	}
+}
+
+// apply copies the editable fields onto a flag
+func (r FeatureFlagRequest) apply(flag *models.FeatureFlag) {
+	flag.Name, flag.Description, flag.IsEnabled, flag.State = r.Name, r.Description, r.IsEnabled, r.State
+	flag.Project, flag.Environment = r.Project, r.Environment
+	flag.Tags, flag.ClientVisible, flag.RolloutPercentage = r.Tags, r.ClientVisible, r.RolloutPercentage
+	flag.ActiveFrom, flag.ActiveUntil, flag.Schedule, flag.TimeZone = r.ActiveFrom, r.ActiveUntil, r.Schedule, r.TimeZone
+	flag.Prerequisites = r.Prerequisites
 }
 
 // CreateFeatureFlag handles creating a new feature flag
@@ -25,17 +77,55 @@ type FeatureFlagRequest struct {
 // @Param featureFlag body FeatureFlagRequest true "Feature flag details"
 // @Success 201 {object} models.FeatureFlag
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/flags [post]
-func CreateFeatureFlag(c *gin.Context) {
-	var featureFlag models.FeatureFlag
+func (h *FlagHandler) CreateFeatureFlag(c *gin.Context) {
+	var input FeatureFlagRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	featureFlag := models.FeatureFlag{OrganizationID: middleware.CallerOrganization(c)}
+	input.apply(&featureFlag)
+
+	requested, _ := middleware.RequestResource(c, "")
+	if featureFlag.Project == "" {
+		featureFlag.Project = requested.Project
+	}
+	if featureFlag.Environment == "" {
+		featureFlag.Environment = requested.Environment
+	}
+	resource, err := middleware.FlagResource(requested, &featureFlag)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !middleware.AllowedOn(c, policy.FlagCreate, resource) {
+		return
+	}
+
+	if featureFlag.State == "" {
+		featureFlag.State = models.FlagStateActive
+	}
+	if !lifecycle.ValidState(featureFlag.State) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lifecycle state"})
+		return
+	}
 
-	if err := c.ShouldBindJSON(&featureFlag); err != nil {
+	if err := evaluation.ValidateConditions(&featureFlag); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := config.DB.Create(&featureFlag).Error; err != nil {
+	if !h.validatePrerequisites(c, &featureFlag) {
+		return
+	}
+
+	if err := h.Flags.CreateFlag(&featureFlag); errors.Is(err, store.ErrNameTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create feature flag"})
 		return
 	}
@@ -45,27 +135,33 @@ func CreateFeatureFlag(c *gin.Context) {
 
 // GetFeatureFlags retrieves all feature flags
 // @Summary Get all feature flags
-// @Description Retrieves all feature flags from the system
+// @Description Retrieves the feature flags the caller may read, optionally filtered by lifecycle state, project and environment
 // @Tags Feature Flags
 // @Produce json
 // @Security BearerAuth
+// @Param state query string false "Lifecycle state (draft, active, launched, deprecated, archived)"
+// @Param project query string false "Project"
+// @Param environment query string false "Environment"
 // @Success 200 {array} models.FeatureFlag
 // @Failure 500 {object} map[string]string
 // @Router /api/flags [get]
-func GetFeatureFlags(c *gin.Context) {
-	var featureFlags []models.FeatureFlag
-
-	if err := config.DB.Find(&featureFlags).Error; err != nil {
+func (h *FlagHandler) GetFeatureFlags(c *gin.Context) {
+	requested, _ := middleware.RequestResource(c, "")
+	featureFlags, err := h.Flags.ListFlags(store.FlagFilter{
+		State: c.Query("state"), Project: requested.Project, Environment: requested.Environment,
+		Organization: middleware.OrganizationFilter(c),
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve feature flags"})
 		return
 	}
 
-	c.JSON(http.StatusOK, featureFlags)
+	c.JSON(http.StatusOK, readableFlags(c, featureFlags))
 }
 
 // GetFeatureFlag retrieves a specific feature flag by ID
 // @Summary Get a feature flag by ID
-// @Description Retrieves details of a specific feature flag
+// @Description Retrieves details of a specific feature flag, including its active rollout plan
 // @Tags Feature Flags
 // @Produce json
 // @Security BearerAuth
@@ -73,15 +169,16 @@ func GetFeatureFlags(c *gin.Context) {
 // @Success 200 {object} models.FeatureFlag
 // @Failure 404 {object} map[string]string
 // @Router /api/flags/{id} [get]
-func GetFeatureFlag(c *gin.Context) {
-	var featureFlag models.FeatureFlag
-
-	id := c.Param("id")
-	if err := config.DB.First(&featureFlag, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Feature flag not found"})
+func (h *FlagHandler) GetFeatureFlag(c *gin.Context) {
+	featureFlag, ok := h.findFlag(c)
+	if !ok {
 		return
 	}
 
+	if plan, err := h.Flags.ActiveRollout(featureFlag.ID); err == nil {
+		featureFlag.ActiveRollout = plan
+	}
+
 	c.JSON(http.StatusOK, featureFlag)
 }
 
@@ -93,45 +190,259 @@ func GetFeatureFlag(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Feature flag ID"
-// @Param featureFlag body models.FeatureFlag true "Updated feature flag details"
+// @Param featureFlag body FeatureFlagRequest true "Updated feature flag details; omitted fields keep their values"
 // @Success 200 {object} models.FeatureFlag
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /api/flags/{id} [put]
-func UpdateFeatureFlag(c *gin.Context) {
-	var featureFlag models.FeatureFlag
-	id := c.Param("id")
+func (h *FlagHandler) UpdateFeatureFlag(c *gin.Context) {
+	featureFlag, ok := h.findFlag(c)
+	if !ok {
+		return
+	}
+	previousName, previousState := featureFlag.Name, featureFlag.State
+	previousEnabled, previousRules := featureFlag.IsEnabled, ruleFingerprint(featureFlag)
 
-	if err := config.DB.First(&featureFlag, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Feature flag not found"})
+	input := flagRequest(featureFlag)
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.apply(featureFlag)
+
+	// Toggling and rule changes are separate permissions from editing a flag's details,
+	// and the flag must remain editable under its new name and tags
+	if featureFlag.IsEnabled != previousEnabled && !middleware.Allowed(c, policy.FlagToggle) {
+		return
+	}
+	if ruleFingerprint(featureFlag) != previousRules && !middleware.Allowed(c, policy.FlagUpdateRules) {
+		return
+	}
+	if !middleware.AllowedOn(c, policy.FlagUpdate, flagResource(featureFlag)) {
+		return
+	}
+
+	if err := evaluation.ValidateConditions(featureFlag); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !lifecycle.ValidState(featureFlag.State) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lifecycle state"})
+		return
+	}
+
+	// Prerequisites reference flags by name, so a flag others depend on cannot be renamed or archived
+	renamed := featureFlag.Name != previousName
+	archived := featureFlag.State == models.FlagStateArchived && previousState != models.FlagStateArchived
+	if (renamed || archived) && h.blockedByDependents(c, featureFlag.OrganizationID, previousName) {
+		return
+	}
+
+	if !h.validatePrerequisites(c, featureFlag) {
+		return
+	}
+
+	if err := h.Flags.SaveFlag(featureFlag); errors.Is(err, store.ErrNameTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update feature flag"})
+		return
+	}
+	c.JSON(http.StatusOK, featureFlag)
+}
+
+// ToggleRequest represents the expected body for turning a flag on or off
+type ToggleRequest struct {
+	IsEnabled *bool `json:"is_enabled" binding:"required"`
+}
+
+// ToggleFeatureFlag turns a flag on or off without changing anything else
+// @Summary Toggle a feature flag
+// @Description Turns a flag on or off; requires only the flag:toggle permission
+// @Tags Feature Flags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Feature flag ID"
+// @Param toggle body ToggleRequest true "Desired state"
+// @Success 200 {object} models.FeatureFlag
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/flags/{id}/toggle [patch]
+func (h *FlagHandler) ToggleFeatureFlag(c *gin.Context) {
+	featureFlag, ok := h.findFlag(c)
+	if !ok {
 		return
 	}
 
-	if err := c.ShouldBindJSON(&featureFlag); err != nil {
+	var input ToggleRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	config.DB.Save(&featureFlag)
+	if err := h.Flags.SetFlagEnabled(featureFlag, *input.IsEnabled); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to toggle feature flag"})
+		return
+	}
+
 	c.JSON(http.StatusOK, featureFlag)
 }
 
 // DeleteFeatureFlag deletes a feature flag
 // @Summary Delete a feature flag
-// @Description Deletes a feature flag from the system
+// @Description Deletes a feature flag from the system; flags that are prerequisites of other flags cannot be deleted
 // @Tags Feature Flags
 // @Security BearerAuth
 // @Param id path int true "Feature flag ID"
 // @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/flags/{id} [delete]
-func DeleteFeatureFlag(c *gin.Context) {
-	id := c.Param("id")
+func (h *FlagHandler) DeleteFeatureFlag(c *gin.Context) {
+	featureFlag, ok := h.findFlag(c)
+	if !ok {
+		return
+	}
 
-	if err := config.DB.Delete(&models.FeatureFlag{}, id).Error; err != nil {
+	if h.blockedByDependents(c, featureFlag.OrganizationID, featureFlag.Name) {
+		return
+	}
+
+	if err := h.Flags.DeleteFlag(featureFlag.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete feature flag"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Feature flag deleted successfully"})
 }
+
+// findFlag loads the :id flag, responding with 400 or 404 on failure
+func (h *FlagHandler) findFlag(c *gin.Context) (*models.FeatureFlag, bool) {
+	id, ok := parseFlagID(c)
+	if !ok {
+		return nil, false
+	}
+
+	featureFlag, err := h.Flags.GetFlag(id)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !visible(c, featureFlag.OrganizationID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Feature flag not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve feature flag"})
+		return nil, false
+	}
+	return featureFlag, true
+}
+
+// parseFlagID reads the :id path parameter, responding with 400 if it is not a valid ID
+func parseFlagID(c *gin.Context) (uint, bool) {
+	return parseID(c, "feature flag")
+}
+
+// parseID reads the :id path parameter of any resource, responding with 400 if it is not a valid ID
+func parseID(c *gin.Context, resource string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + resource + " ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// validatePrerequisites rejects unknown prerequisites and dependency cycles, responding on failure.
+// Only flags of the same organization can be prerequisites.
+func (h *FlagHandler) validatePrerequisites(c *gin.Context, featureFlag *models.FeatureFlag) bool {
+	if len(featureFlag.Prerequisites) == 0 {
+		return true
+	}
+
+	flags, err := h.Flags.ListFlags(store.FlagFilter{Organization: sameOrganization(featureFlag.OrganizationID)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve feature flags"})
+		return false
+	}
+
+	err = dependencies.Validate(featureFlag, flags)
+	switch {
+	case errors.Is(err, dependencies.ErrCycle):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return false
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// blockedByDependents responds with 409 if other flags of the organization declare the named flag
+// as a prerequisite
+func (h *FlagHandler) blockedByDependents(c *gin.Context, org *uint, name string) bool {
+	flags, err := h.Flags.ListFlags(store.FlagFilter{Organization: sameOrganization(org)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve feature flags"})
+		return true
+	}
+
+	if dependents := dependencies.Dependents(name, flags); len(dependents) > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      "Feature flag is a prerequisite of other flags",
+			"dependents": dependents,
+		})
+		return true
+	}
+	return false
+}
+
+// ruleFingerprint summarises the targeting fields guarded by the flag:updateRules permission
+func ruleFingerprint(featureFlag *models.FeatureFlag) string {
+	rules, _ := json.Marshal([]interface{}{
+		featureFlag.RolloutPercentage,
+		featureFlag.ActiveFrom,
+		featureFlag.ActiveUntil,
+		featureFlag.Schedule,
+		featureFlag.TimeZone,
+		featureFlag.Prerequisites,
+	})
+	return string(rules)
+}
+
+// visible reports whether a record of the organization exists as far as the caller is concerned
+func visible(c *gin.Context, org *uint) bool {
+	claims, ok := middleware.CurrentClaims(c)
+	return ok && claims.InOrganization(utils.OrganizationOf(org))
+}
+
+// sameOrganization filters for records of the organization a record belongs to
+func sameOrganization(org *uint) *uint {
+	id := utils.OrganizationOf(org)
+	return &id
+}
+
+// flagResource describes a flag as a policy resource in its own project and environment
+func flagResource(featureFlag *models.FeatureFlag) policy.Resource {
+	resource, _ := middleware.FlagResource(policy.Resource{}, featureFlag)
+	return resource
+}
+
+// readableFlags drops flags the caller is not allowed to read
+func readableFlags(c *gin.Context, featureFlags []models.FeatureFlag) []models.FeatureFlag {
+	claims, ok := middleware.CurrentClaims(c)
+	if !ok {
+		return []models.FeatureFlag{}
+	}
+
+	decide := middleware.Decider(claims)
+	readable := []models.FeatureFlag{}
+	for i := range featureFlags {
+		if decide(policy.FlagRead, flagResource(&featureFlags[i])).Allowed {
+			readable = append(readable, featureFlags[i])
+		}
+	}
+	return readable
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/guardrails"
+	"feature-flag-service/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GuardrailRequest represents a single guardrail threshold for a flag
+type GuardrailRequest struct {
+	Metric      string  `json:"metric" binding:"required" example:"error_count"`
+	Aggregation string  `json:"aggregation" binding:"required" example:"sum"`
+	Threshold   float64 `json:"threshold" example:"10"`
+	Window      string  `json:"window" example:"5m"`
+	MinSamples  int     `json:"min_samples" example:"20"`
+}
+
+// GuardrailsRequest represents the expected body for replacing a flag's guardrails
+type GuardrailsRequest struct {
+	Guardrails []GuardrailRequest `json:"guardrails"`
+}
+
+// SignalRequest represents one health observation reported by a client
+type SignalRequest struct {
+	Variation  string    `json:"variation" binding:"required" example:"treatment"`
+	Metric     string    `json:"metric" binding:"required" example:"latency_ms"`
+	Value      float64   `json:"value" example:"125"`
+	ObservedAt time.Time `json:"observed_at"`
+}
+
+// SignalsRequest represents the expected body for health signal ingestion
+type SignalsRequest struct {
+	Signals []SignalRequest `json:"signals" binding:"required,dive"`
+}
+
+// GetGuardrails lists a flag's guardrails
+// @Summary Get guardrails for a feature flag
+// @Description Lists the health thresholds enforced while the flag is rolling out
+// @Tags Guardrails
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Feature flag ID"
+// @Success 200 {array} models.Guardrail
+// @Failure 500 {object} map[string]string
+// @Router /api/flags/{id}/guardrails [get]
+func GetGuardrails(c *gin.Context) {
+	flagID, ok := parseFlagID(c)
+	if !ok {
+		return
+	}
+
+	var rules []models.Guardrail
+	if err := config.DB.Where("feature_flag_id = ?", flagID).Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve guardrails"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// SetGuardrails replaces a flag's guardrails
+// @Summary Set guardrails for a feature flag
+// @Description Replaces the health thresholds that trigger an automatic rollback during a rollout
+// @Tags Guardrails
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Feature flag ID"
+// @Param guardrails body GuardrailsRequest true "Guardrail thresholds"
+// @Success 200 {array} models.Guardrail
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/flags/{id}/guardrails [put]
+func SetGuardrails(c *gin.Context) {
+	var featureFlag models.FeatureFlag
+	if err := config.DB.First(&featureFlag, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Feature flag not found"})
+		return
+	}
+
+	var input GuardrailsRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rules := make([]models.Guardrail, len(input.Guardrails))
+	for i, g := range input.Guardrails {
+		rules[i] = models.Guardrail{
+			FeatureFlagID: featureFlag.ID,
+			Metric:        g.Metric,
+			Aggregation:   g.Aggregation,
+			Threshold:     g.Threshold,
+			Window:        g.Window,
+			MinSamples:    g.MinSamples,
+		}
+		if rules[i].Window == "" {
+			rules[i].Window = guardrails.DefaultWindow.String()
+		}
+		if err := guardrails.Validate(rules[i]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("feature_flag_id = ?", featureFlag.ID).Delete(&models.Guardrail{}).Error; err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			return nil
+		}
+		return tx.Create(&rules).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save guardrails"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// IngestSignals accepts health signals for a flag
+// @Summary Ingest health signals
+// @Description Records error counts, latencies or custom metrics tagged by variation; a breached guardrail rolls the flag back
+// @Tags Guardrails
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Feature flag ID"
+// @Param signals body SignalsRequest true "Health signals"
+// @Success 202 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/flags/{id}/signals [post]
+func IngestSignals(c *gin.Context) {
+	var featureFlag models.FeatureFlag
+	if err := config.DB.First(&featureFlag, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Feature flag not found"})
+		return
+	}
+
+	var input SignalsRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	signals := make([]models.HealthSignal, len(input.Signals))
+	for i, s := range input.Signals {
+		signals[i] = models.HealthSignal{
+			Variation:  s.Variation,
+			Metric:     s.Metric,
+			Value:      s.Value,
+			ObservedAt: s.ObservedAt,
+		}
+	}
+
+	breach, err := guardrails.Ingest(featureFlag.ID, signals, time.Now())
+	if errors.Is(err, guardrails.ErrInvalidSignal) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ingest signals"})
+		return
+	}
+
+	response := gin.H{"accepted": len(signals), "rolled_back": breach != nil}
+	if breach != nil {
+		response["reason"] = breach.Reason()
+	}
+	c.JSON(http.StatusAccepted, response)
+}
+
+// GetFlagHistory lists the recorded history of a flag
+// @Summary Get feature flag history
+// @Description Lists notable changes to a flag, such as automatic guardrail rollbacks, newest first
+// @Tags Feature Flags
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Feature flag ID"
+// @Success 200 {array} models.FlagHistory
+// @Failure 500 {object} map[string]string
+// @Router /api/flags/{id}/history [get]
+func GetFlagHistory(c *gin.Context) {
+	flagID, ok := parseFlagID(c)
+	if !ok {
+		return
+	}
+
+	var entries []models.FlagHistory
+	if err := config.DB.Where("feature_flag_id = ?", flagID).Order("created_at DESC").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve flag history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+
+	"feature-flag-service/internal/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler serves the liveness and readiness probes and the detailed health report
+type HealthHandler struct {
+	Checks  *health.Checker
+	Running func() bool // False while starting up or shutting down
+}
+
+// NewHealthHandler creates a HealthHandler; running reports whether the app is serving normally
+func NewHealthHandler(checks *health.Checker, running func() bool) *HealthHandler {
+	return &HealthHandler{Checks: checks, Running: running}
+}
+
+// Livez reports that the process is up
+// @Summary Liveness probe
+// @Description Succeeds while the process can serve requests; it does not check dependencies, so a database outage does not get the process restarted
+// @Tags Health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /livez [get]
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Readyz reports whether the service should receive traffic
+// @Summary Readiness probe
+// @Description Fails while shutting down or when a critical dependency (database, Redis, schema) is failing. Details are in the authenticated report at /api/health.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /readyz [get]
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	if !h.Running() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting down"})
+		return
+	}
+	report := h.Checks.Run(c.Request.Context())
+	if !report.Ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": report.Status})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": report.Status})
+}
+
+// GetHealthReport returns every dependency check with its latency and last error
+// @Summary Detailed health report
+// @Description Runs every dependency check and reports its status, latency and most recent error. Restricted to admins.
+// @Tags Health
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report
+// @Router /api/health [get]
+func (h *HealthHandler) GetHealthReport(c *gin.Context) {
+	report := h.Checks.Run(c.Request.Context())
+	if !h.Running() {
+		report.Ready = false
+	}
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
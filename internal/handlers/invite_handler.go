@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"feature-flag-service/internal/audit"
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/invites"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/passwords"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrganizationRequest represents the expected body for creating an organization
+type OrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=100" example:"acme"`
+}
+
+// InviteRequest represents the expected body for creating an invite
+type InviteRequest struct {
+	OrganizationID uint   `json:"organization_id" binding:"required" example:"1"`
+	Role           string `json:"role" binding:"required" example:"editor"`
+	Email          string `json:"email" binding:"omitempty,email,max=254"`
+	ExpiresInHours int    `json:"expires_in_hours" example:"168"` // Defaults to 7 days, at most 30
+}
+
+// InviteResponse includes the plaintext invite token, which is only ever shown once
+type InviteResponse struct {
+	models.Invite
+	Token string `json:"token"`
+}
+
+// AcceptInviteRequest represents the expected body for accepting an invite
+type AcceptInviteRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// GetOrganizations lists organizations
+// @Summary List organizations
+// @Description Owners see every organization, other users only their own
+// @Tags Invites
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Organization
+// @Router /api/organizations [get]
+func GetOrganizations(c *gin.Context) {
+	query := config.DB
+	if org := middleware.OrganizationFilter(c); org != nil {
+		query = query.Where("id = ?", *org)
+	}
+	var organizations []models.Organization
+	if err := query.Order("name").Find(&organizations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organizations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, organizations)
+}
+
+// CreateOrganization creates an organization that users can be invited to
+// @Summary Create an organization
+// @Description Only owners can create organizations
+// @Tags Invites
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param organization body OrganizationRequest true "Organization"
+// @Success 201 {object} models.Organization
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/organizations [post]
+func CreateOrganization(c *gin.Context) {
+	var input OrganizationRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !requireOwner(c, "Only owners can create organizations") {
+		return
+	}
+
+	organization := models.Organization{Name: strings.TrimSpace(input.Name)}
+	var taken int64
+	if err := config.DB.Model(&models.Organization{}).Where("name = ?", organization.Name).Count(&taken).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+		return
+	}
+	if taken > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization already exists"})
+		return
+	}
+	if err := config.DB.Create(&organization).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, organization)
+}
+
+// GetInvites lists pending invites
+// @Summary List pending invites
+// @Description Lists invites to the caller's organization that have not been accepted or revoked; tokens are never returned
+// @Tags Invites
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Invite
+// @Router /api/invites [get]
+func GetInvites(c *gin.Context) {
+	query := config.DB
+	if org := middleware.OrganizationFilter(c); org != nil {
+		query = query.Where("organization_id = ?", *org)
+	}
+	var pending []models.Invite
+	if err := query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", time.Now()).
+		Order("created_at").Find(&pending).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve invites"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pending)
+}
+
+// CreateInvite issues a single-use invite to join an organization with a role
+// @Summary Create an invite
+// @Description Issues a single-use, expiring invite token for an organization and role; the token is shown only once
+// @Tags Invites
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param invite body InviteRequest true "Organization, role and expiry"
+// @Success 201 {object} InviteResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/invites [post]
+func CreateInvite(c *gin.Context) {
+	var input InviteRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Invites to another organization are refused as if it did not exist
+	if !visible(c, &input.OrganizationID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invites.ErrUnknownOrganization.Error()})
+		return
+	}
+	if !canGrant(c, input.Role, &input.OrganizationID) {
+		return
+	}
+
+	ttl := invites.DefaultTTL
+	if input.ExpiresInHours != 0 {
+		ttl = time.Duration(input.ExpiresInHours) * time.Hour
+	}
+
+	invite := models.Invite{
+		OrganizationID: input.OrganizationID,
+		Role:           input.Role,
+		Email:          strings.ToLower(strings.TrimSpace(input.Email)),
+	}
+	if claims, ok := middleware.CurrentClaims(c); ok {
+		invite.CreatedBy = claims.Username
+	}
+
+	token, err := invites.Create(&invite, ttl, time.Now())
+	if errors.Is(err, invites.ErrInvalidTTL) || errors.Is(err, invites.ErrUnknownOrganization) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+	audit.Record(models.AuditEntry{Action: models.AuditInviteCreated, Actor: invite.CreatedBy, Target: invite.Email, IPAddress: c.ClientIP(), Detail: invite.Role})
+
+	c.JSON(http.StatusCreated, InviteResponse{Invite: invite, Token: token})
+}
+
+// RevokeInvite revokes a pending invite
+// @Summary Revoke an invite
+// @Tags Invites
+// @Security BearerAuth
+// @Param id path int true "Invite ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/invites/{id} [delete]
+func RevokeInvite(c *gin.Context) {
+	id, ok := parseID(c, "invite")
+	if !ok {
+		return
+	}
+
+	invite, err := invites.Revoke(id, time.Now())
+	if errors.Is(err, invites.ErrInviteNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite"})
+		return
+	}
+	entry := models.AuditEntry{Action: models.AuditInviteRevoked, Target: invite.Email, IPAddress: c.ClientIP(), Detail: invite.Role}
+	if claims, ok := middleware.CurrentClaims(c); ok {
+		entry.Actor = claims.Username
+	}
+	audit.Record(entry)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite revoked successfully"})
+}
+
+// AcceptInvite creates an account from an invite
+// @Summary Accept an invite
+// @Description Redeems a single-use invite token, creating a user in the invited organization with the invited role
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param invite body AcceptInviteRequest true "Invite token and new account details"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /invites/accept [post]
+func AcceptInvite(c *gin.Context) {
+	var input AcceptInviteRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := invites.Accept(input.Token, input.Username, input.Password, time.Now())
+	switch {
+	case errors.Is(err, invites.ErrInvalidInvite), passwords.IsRejected(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, invites.ErrUsernameTaken), errors.Is(err, invites.ErrEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invite"})
+		return
+	}
+	audit.Record(models.AuditEntry{Action: models.AuditInviteAccepted, Actor: user.Username, Target: user.Username, IPAddress: c.ClientIP(), Detail: user.Role})
+
+	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully"})
+}
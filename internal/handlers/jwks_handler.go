@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"feature-flag-service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS publishes the public keys that verify access tokens
+// @Summary JSON Web Key Set
+// @Description Public keys, identified by kid, that gateways can use to verify access tokens
+// @Tags Authentication
+// @Produce json
+// @Success 200 {object} utils.JWKS
+// @Failure 500 {object} map[string]string
+// @Router /.well-known/jwks.json [get]
+func GetJWKS(c *gin.Context) {
+	keys, err := utils.Keys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Signing keys are not configured"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, keys.JWKS())
+}
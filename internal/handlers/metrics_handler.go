@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"feature-flag-service/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsContentType is the Prometheus text exposition format
+const MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// MetricsHandler serves the Prometheus scrape endpoint
+type MetricsHandler struct {
+	Token string // Bearer token scrapers must send; open to anyone if empty
+}
+
+// NewMetricsHandler creates a MetricsHandler protected by token (METRICS_TOKEN)
+func NewMetricsHandler(token string) *MetricsHandler {
+	return &MetricsHandler{Token: token}
+}
+
+// GetMetrics writes every metric in the Prometheus text format
+// @Summary Prometheus metrics
+// @Description Request, evaluation, cache, database pool, Redis and scheduler metrics in the Prometheus text format. Requires "Bearer <METRICS_TOKEN>" when METRICS_TOKEN is set.
+// @Tags Health
+// @Produce plain
+// @Success 200 {string} string
+// @Failure 401 {object} map[string]string
+// @Router /metrics [get]
+func (h *MetricsHandler) GetMetrics(c *gin.Context) {
+	if h.Token != "" {
+		token, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+	}
+
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", MetricsContentType)
+	metrics.Write(c.Writer)
+}
@@ -0,0 +1,453 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/mfa"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// MFAChallengeResponse is returned by Login when a second factor is needed
+type MFAChallengeResponse struct {
+	MFARequired        bool   `json:"mfa_required"`
+	MFAToken           string `json:"mfa_token"`
+	EnrollmentRequired bool   `json:"enrollment_required"` // The user's role requires MFA but it is not set up yet
+}
+
+// MFALoginRequest represents the expected body for the second login step
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required" example:"123456"` // TOTP code or recovery code
+}
+
+// MFATokenRequest represents the expected body for enrolling during login
+type MFATokenRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// MFACodeRequest represents the expected body for actions confirmed with a code
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+// MFAEnrollmentResponse holds a new TOTP secret for an authenticator app
+type MFAEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// MFARequirementsRequest represents the expected body for setting which roles require MFA
+type MFARequirementsRequest struct {
+	Roles []string `json:"roles" example:"admin,owner"`
+}
+
+// OrganizationMFARequest represents the expected body for requiring MFA in an organization
+type OrganizationMFARequest struct {
+	Required bool `json:"required" example:"true"`
+}
+
+// LoginMFA completes a login with a second factor
+// @Summary Complete login with a second factor
+// @Description Exchanges an MFA challenge token and a TOTP or recovery code for a session. If enrollment was required, the first valid code activates MFA and recovery codes are returned once.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param mfa body MFALoginRequest true "Challenge token and code"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /login/mfa [post]
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	var input MFALoginRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, claims, ok := challengedUser(c, input.MFAToken)
+	if !ok || !loginAllowed(c, user.Username) {
+		return
+	}
+
+	var recoveryCodes []string
+	var err error
+	if claims.Enroll && !user.MFAEnabled {
+		recoveryCodes, err = h.MFA.Activate(user, input.Code, time.Now())
+	} else {
+		err = h.MFA.Verify(user, input.Code, time.Now())
+	}
+	if errors.Is(err, mfa.ErrInvalidCode) {
+		loginFailed(c, user.Username, models.AuditMFAFailed, "wrong code")
+		return
+	}
+	if !respondMFAError(c, err) {
+		return
+	}
+	loginSucceeded(user.Username)
+	h.startSession(c, user, recoveryCodes)
+}
+
+// EnrollMFAAtLogin sets up TOTP for a user whose role requires it before they can log in
+// @Summary Set up MFA during login
+// @Description Returns a TOTP secret for a user who must enroll; confirm it with a code at /login/mfa
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param mfa body MFATokenRequest true "Challenge token"
+// @Success 200 {object} MFAEnrollmentResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /login/mfa/enroll [post]
+func (h *AuthHandler) EnrollMFAAtLogin(c *gin.Context) {
+	var input MFATokenRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, claims, ok := challengedUser(c, input.MFAToken)
+	if !ok {
+		return
+	}
+	if !claims.Enroll {
+		c.JSON(http.StatusBadRequest, gin.H{"error": mfa.ErrAlreadyEnrolled.Error()})
+		return
+	}
+
+	h.beginEnrollment(c, user)
+}
+
+// EnrollMFA starts setting up TOTP for the caller
+// @Summary Set up two-factor authentication
+// @Description Returns a new TOTP secret and otpauth URI; MFA is enabled once a code is confirmed at /api/mfa/activate
+// @Tags MFA
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} MFAEnrollmentResponse
+// @Failure 409 {object} map[string]string
+// @Router /api/mfa/enroll [post]
+func (h *AuthHandler) EnrollMFA(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	h.beginEnrollment(c, user)
+}
+
+// ActivateMFA confirms the caller's TOTP secret
+// @Summary Activate two-factor authentication
+// @Description Confirms the authenticator app with a code and returns recovery codes, which are only shown once
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param code body MFACodeRequest true "TOTP code"
+// @Success 200 {object} map[string][]string
+// @Failure 400 {object} map[string]string
+// @Router /api/mfa/activate [post]
+func (h *AuthHandler) ActivateMFA(c *gin.Context) {
+	user, input, ok := userWithCode(c)
+	if !ok {
+		return
+	}
+
+	codes, err := h.MFA.Activate(user, input.Code, time.Now())
+	if !respondMFAError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes
+// @Summary Regenerate recovery codes
+// @Description Replaces all recovery codes after confirming a current code; old codes stop working
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param code body MFACodeRequest true "TOTP or recovery code"
+// @Success 200 {object} map[string][]string
+// @Failure 400 {object} map[string]string
+// @Router /api/mfa/recovery-codes [post]
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	user, input, ok := userWithCode(c)
+	if !ok {
+		return
+	}
+
+	if !respondMFAError(c, h.MFA.Verify(user, input.Code, time.Now())) {
+		return
+	}
+	codes, err := mfa.RegenerateRecoveryCodes(user)
+	if !respondMFAError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableMFA turns off the caller's second factor
+// @Summary Disable two-factor authentication
+// @Description Removes the caller's second factor after confirming a code; not allowed when the caller's role or organization requires MFA
+// @Tags MFA
+// @Accept json
+// @Security BearerAuth
+// @Param code body MFACodeRequest true "TOTP or recovery code"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/mfa [delete]
+func (h *AuthHandler) DisableMFA(c *gin.Context) {
+	user, input, ok := userWithCode(c)
+	if !ok {
+		return
+	}
+
+	required, err := mfa.Required(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check MFA requirements"})
+		return
+	}
+	if required {
+		c.JSON(http.StatusForbidden, gin.H{"error": mfa.ErrRequired.Error()})
+		return
+	}
+
+	if !respondMFAError(c, h.MFA.Verify(user, input.Code, time.Now())) {
+		return
+	}
+	if err := mfa.Disable(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable MFA"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// ResetUserMFA removes another user's second factor, e.g. after a lost phone
+// @Summary Reset a user's MFA
+// @Description Removes a user's second factor and recovery codes; if their role requires MFA they must enroll again at next login
+// @Tags Users
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/users/{id}/mfa [delete]
+func ResetUserMFA(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !canGrant(c, user.Role, user.OrganizationID) {
+		return
+	}
+
+	if err := mfa.Disable(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset MFA"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
+
+// GetMFARequirements lists the roles that require MFA
+// @Summary List MFA requirements
+// @Description Lists the roles whose holders must use two-factor authentication
+// @Tags MFA
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.MFARequirement
+// @Failure 500 {object} map[string]string
+// @Router /api/mfa/requirements [get]
+func GetMFARequirements(c *gin.Context) {
+	var requirements []models.MFARequirement
+	if err := config.DB.Order("role").Find(&requirements).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve MFA requirements"})
+		return
+	}
+
+	c.JSON(http.StatusOK, requirements)
+}
+
+// SetMFARequirements replaces the roles that require MFA
+// @Summary Enforce MFA for roles
+// @Description Replaces the roles whose holders must use two-factor authentication, globally or through a project/environment binding. Only owners can change them, as they apply to every organization.
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param requirements body MFARequirementsRequest true "Roles"
+// @Success 200 {array} models.MFARequirement
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/mfa/requirements [put]
+func SetMFARequirements(c *gin.Context) {
+	var input MFARequirementsRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Requirements by role apply to every organization
+	if !requireOwner(c, "Only owners can change MFA requirements for every organization") {
+		return
+	}
+
+	requirements := make([]models.MFARequirement, 0, len(input.Roles))
+	seen := map[string]bool{}
+	for _, role := range input.Roles {
+		if seen[role] {
+			continue
+		}
+		seen[role] = true
+		if !canGrant(c, role, middleware.NameOrganization(c)) {
+			return
+		}
+		requirements = append(requirements, models.MFARequirement{Role: role})
+	}
+
+	// Dropping a requirement is also limited to roles the caller could grant
+	var existing []models.MFARequirement
+	if err := config.DB.Find(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve MFA requirements"})
+		return
+	}
+	for _, r := range existing {
+		if !seen[r.Role] && !canGrant(c, r.Role, middleware.NameOrganization(c)) {
+			return
+		}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.MFARequirement{}).Error; err != nil {
+			return err
+		}
+		if len(requirements) == 0 {
+			return nil
+		}
+		return tx.Create(&requirements).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save MFA requirements"})
+		return
+	}
+
+	c.JSON(http.StatusOK, requirements)
+}
+
+// SetOrganizationMFA requires, or stops requiring, MFA for every member of an organization
+// @Summary Enforce MFA for an organization
+// @Description Requires two-factor authentication of every user in the organization, in addition to any role requirements. The caller must be able to grant every role held by its members.
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Organization ID"
+// @Param requirement body OrganizationMFARequest true "Whether MFA is required"
+// @Success 200 {object} models.Organization
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/organizations/{id}/mfa [put]
+func SetOrganizationMFA(c *gin.Context) {
+	var input OrganizationMFARequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var organization models.Organization
+	if err := config.DB.First(&organization, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	// Like role requirements, only members the caller could manage are affected
+	var roles []string
+	if err := config.DB.Model(&models.User{}).Where("organization_id = ?", organization.ID).
+		Distinct().Pluck("role", &roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organization members"})
+		return
+	}
+	for _, role := range roles {
+		if !canGrant(c, role, &organization.ID) {
+			return
+		}
+	}
+
+	if err := config.DB.Model(&organization).UpdateColumn("require_mfa", input.Required).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save MFA requirement"})
+		return
+	}
+	organization.RequireMFA = input.Required
+
+	c.JSON(http.StatusOK, organization)
+}
+
+func (h *AuthHandler) beginEnrollment(c *gin.Context, user *models.User) {
+	secret, uri, err := h.MFA.BeginEnrollment(user)
+	if errors.Is(err, mfa.ErrAlreadyEnrolled) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start MFA enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MFAEnrollmentResponse{Secret: secret, OTPAuthURI: uri})
+}
+
+// challengedUser loads the user named by a valid MFA challenge token
+func challengedUser(c *gin.Context, token string) (*models.User, *mfa.ChallengeClaims, bool) {
+	claims, err := mfa.ParseChallenge(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+
+	var user models.User
+	if err := config.DB.Where("username = ? AND disabled = ?", claims.Username, false).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": mfa.ErrInvalidToken.Error()})
+		return nil, nil, false
+	}
+	return &user, claims, true
+}
+
+func userWithCode(c *gin.Context) (*models.User, MFACodeRequest, bool) {
+	var input MFACodeRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, input, false
+	}
+
+	user, ok := currentUser(c)
+	return user, input, ok
+}
+
+// respondMFAError maps MFA errors to responses and reports whether the caller may continue
+func respondMFAError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, mfa.ErrInvalidCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, mfa.ErrNotEnrolled), errors.Is(err, mfa.ErrAlreadyEnrolled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/oidc"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// oidcLoginTTL is how long a user has to complete the login at the identity provider
+const oidcLoginTTL = 10 * time.Minute
+
+// oidcStateCookie ties a login request to the browser that started it, so a callback URL
+// carrying someone else's state and code cannot log this browser in
+const oidcStateCookie = "oidc_state"
+
+var (
+	errSSOUsernameTaken  = errors.New("a local account already uses this username")
+	errSSOAccountDeleted = errors.New("the account linked to this identity has been deleted")
+)
+
+// OIDCLogin starts single sign-on
+// @Summary Start single sign-on
+// @Description Redirects to the identity provider using the authorization code flow with PKCE
+// @Tags Authentication
+// @Success 302
+// @Failure 503 {object} map[string]string
+// @Router /auth/oidc/login [get]
+func OIDCLogin(c *gin.Context) {
+	provider, err := oidc.Default(c.Request.Context())
+	if err != nil {
+		log.Printf("⚠️ OIDC login unavailable: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Single sign-on is not available"})
+		return
+	}
+
+	req, err := oidc.NewAuthRequest()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+	pending, _ := json.Marshal(req)
+	if err := config.Cache.Set(oidcStateKey(req.State), pending, oidcLoginTTL); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+
+	setStateCookie(c, provider.Config.RedirectURL, req.State, int(oidcLoginTTL.Seconds()))
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(req))
+}
+
+// OIDCCallback completes single sign-on
+// @Summary Complete single sign-on
+// @Description Exchanges the authorization code, provisions the user on first login, maps IdP groups to a role and starts a session
+// @Tags Authentication
+// @Produce json
+// @Param code query string true "Authorization code"
+// @Param state query string true "State from the login request"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /auth/oidc/callback [get]
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	if reason := c.Query("error"); reason != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Single sign-on failed: " + reason})
+		return
+	}
+
+	provider, err := oidc.Default(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Single sign-on is not available"})
+		return
+	}
+
+	// The state must come back to the browser that started the login, and can be redeemed once
+	state := c.Query("state")
+	cookie, _ := c.Cookie(oidcStateCookie)
+	setStateCookie(c, provider.Config.RedirectURL, "", -1)
+	if state == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login request was not started by this browser"})
+		return
+	}
+
+	pending, err := config.Cache.GetDel(oidcStateKey(state))
+	var req oidc.AuthRequest
+	if err != nil || json.Unmarshal(pending, &req) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown or expired login request"})
+		return
+	}
+
+	identity, err := provider.Exchange(c.Request.Context(), c.Query("code"), &req)
+	if err != nil {
+		log.Printf("⚠️ OIDC login failed: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Single sign-on failed"})
+		return
+	}
+
+	user, err := provisionSSOUser(provider.Config, identity)
+	if errors.Is(err, errSSOUsernameTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, errSSOAccountDeleted) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to provision user"})
+		return
+	}
+
+	if user.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": errAccountDisabled.Error()})
+		return
+	}
+
+	h.startSession(c, user, nil)
+}
+
+// provisionSSOUser finds the user linked to an IdP identity, creating it on first login. When a
+// role mapping is configured the IdP's groups are authoritative and the role is synced on every login.
+// A deleted account stays deleted rather than being re-created by the IdP.
+func provisionSSOUser(cfg oidc.Config, identity *oidc.Identity) (*models.User, error) {
+	externalID := cfg.Issuer + "|" + identity.Subject
+	role := cfg.RoleFor(identity.Groups)
+
+	var user models.User
+	err := config.DB.Unscoped().Where("external_id = ?", externalID).First(&user).Error
+	if err == nil && user.DeletedAt.Valid {
+		return nil, errSSOAccountDeleted
+	}
+	if err == nil {
+		if len(cfg.RoleMapping) > 0 && user.Role != role {
+			log.Printf("🔑 Syncing role of %s from identity provider groups: %s -> %s", user.Username, user.Role, role)
+			user.Role = role
+			if err := config.DB.Model(&user).Update("role", role).Error; err != nil {
+				return nil, err
+			}
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Never link to an existing local account by name; that would let the IdP take it over
+	var count int64
+	if err := config.DB.Model(&models.User{}).Where("username = ?", identity.Username()).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errSSOUsernameTaken
+	}
+
+	user = models.User{
+		Username:   identity.Username(),
+		Role:       role,
+		ExternalID: &externalID,
+	}
+	if err := config.DB.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	log.Printf("👤 Provisioned %s from single sign-on with role %s", user.Username, role)
+	return &user, nil
+}
+
+func oidcStateKey(state string) string {
+	return "oidc:state:" + state
+}
+
+// setStateCookie stores the login state for the callback, or clears it with maxAge -1. The cookie
+// is scoped to the callback path and only sent over HTTPS when the callback uses it.
+func setStateCookie(c *gin.Context, redirectURL, state string, maxAge int) {
+	path, secure := "/", false
+	if u, err := url.Parse(redirectURL); err == nil {
+		secure = u.Scheme == "https"
+		if u.Path != "" {
+			path = u.Path
+		}
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, maxAge, path, "", secure, true)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"feature-flag-service/internal/audit"
+	"feature-flag-service/internal/loginguard"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/passwords"
+	"feature-flag-service/internal/sessions"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PasswordHandler sends password reset links pointing at the web app's reset page
+type PasswordHandler struct {
+	ResetURL string // Page that collects the new password; the bare token is sent if empty
+}
+
+// NewPasswordHandler creates a PasswordHandler linking to resetURL (PASSWORD_RESET_URL)
+func NewPasswordHandler(resetURL string) *PasswordHandler {
+	return &PasswordHandler{ResetURL: resetURL}
+}
+
+// ForgotPasswordRequest represents the expected body for requesting a password reset
+type ForgotPasswordRequest struct {
+	Login string `json:"login" binding:"required" example:"alice@example.com"` // Username or email
+}
+
+// ResetPasswordRequest represents the expected body for resetting a password
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ForgotPassword sends a password reset link
+// @Summary Request a password reset
+// @Description Sends a single-use reset token to the account's email. The response is the same whether or not the account exists. Requests are limited per username or email and per client IP.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "Username or email"
+// @Success 202 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /password/forgot [post]
+func (h *PasswordHandler) ForgotPassword(c *gin.Context) {
+	var input ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status, err := loginguard.AllowReset(input.Login, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Password reset is temporarily unavailable"})
+		return
+	}
+	if status.Locked {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(status.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many password reset requests; try again later"})
+		return
+	}
+
+	user, err := passwords.RequestReset(input.Login, h.ResetURL, time.Now())
+	if err != nil {
+		// Still answer as usual so failures cannot be used to probe for accounts
+		log.Printf("⚠️ Failed to send password reset: %v", err)
+	}
+	if user != nil {
+		audit.Record(models.AuditEntry{Action: models.AuditPasswordResetRequested, Target: user.Username, IPAddress: c.ClientIP()})
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists and has an email address, a reset link has been sent"})
+}
+
+// ResetPassword sets a new password with a reset token
+// @Summary Reset a password
+// @Description Redeems a reset token, sets the new password, ends every session and clears any login lockout
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /password/reset [post]
+func ResetPassword(c *gin.Context) {
+	var input ResetPasswordRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	user, err := passwords.Reset(input.Token, input.NewPassword, now)
+	if errors.Is(err, passwords.ErrInvalidResetToken) || passwords.IsRejected(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	if err := sessions.RevokeAll(user.ID, 0, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Password reset, but existing sessions could not be ended"})
+		return
+	}
+	if err := loginguard.Unlock(user.Username); err != nil {
+		log.Printf("⚠️ Failed to clear login lockout for %s: %v", user.Username, err)
+	}
+	audit.Record(models.AuditEntry{Action: models.AuditPasswordReset, Actor: user.Username, Target: user.Username, IPAddress: c.ClientIP()})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"feature-flag-service/internal/audit"
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/passwords"
+	"feature-flag-service/internal/sessions"
+	"feature-flag-service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProfileRequest updates the caller's own profile
+type ProfileRequest struct {
+	Email       string `json:"email" binding:"omitempty,email,max=254"`
+	DisplayName string `json:"display_name" binding:"max=100"`
+}
+
+// PasswordChangeRequest changes the caller's own password
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// GetProfile returns the caller's own account
+// @Summary Get my profile
+// @Description Returns the authenticated user's account
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.User
+// @Failure 403 {object} map[string]string
+// @Router /api/me [get]
+func GetProfile(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateProfile updates the caller's email and display name
+// @Summary Update my profile
+// @Description Updates the authenticated user's email and display name
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param profile body ProfileRequest true "Profile"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/me [put]
+func UpdateProfile(c *gin.Context) {
+	var input ProfileRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(input.Email))
+	if email != "" {
+		var taken int64
+		if err := config.DB.Model(&models.User{}).Where("email = ? AND id <> ?", email, user.ID).Count(&taken).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+		if taken > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+			return
+		}
+	}
+
+	user.Email = email
+	user.DisplayName = strings.TrimSpace(input.DisplayName)
+	if err := config.DB.Model(user).Select("email", "display_name").Updates(user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangePassword changes the caller's password and signs out their other sessions
+// @Summary Change my password
+// @Description Changes the authenticated user's password after checking the current one and the password policy; every other session is ended
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param password body PasswordChangeRequest true "Current and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/me/password [put]
+func ChangePassword(c *gin.Context) {
+	var input PasswordChangeRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if claims, ok := middleware.CurrentClaims(c); ok && claims.TokenID != 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access tokens cannot be used to change passwords"})
+		return
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if user.ExternalID != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Single sign-on accounts have no local password"})
+		return
+	}
+
+	if err := utils.CheckPassword(user.Password, input.CurrentPassword); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	now := time.Now()
+	err := passwords.Change(user, input.NewPassword, now)
+	if passwords.IsRejected(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	// Anyone holding the old password may already have a session, so keep only the one making this change
+	claims, _ := middleware.CurrentClaims(c)
+	if err := sessions.RevokeAll(user.ID, claims.SessionID, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Password changed, but other sessions could not be ended"})
+		return
+	}
+	audit.Record(models.AuditEntry{Action: models.AuditPasswordChanged, Actor: user.Username, Target: user.Username, IPAddress: c.ClientIP()})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/policy"
+	"feature-flag-service/internal/store"
+	"feature-flag-service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CustomRoleRequest represents the expected body for creating or updating a custom role
+type CustomRoleRequest struct {
+	Name        string                   `json:"name" binding:"required" example:"mobile-toggler"`
+	Description string                   `json:"description"`
+	Statements  []models.PolicyStatement `json:"statements" binding:"required"`
+}
+
+// ExplainResponse describes what a user may do on a resource
+type ExplainResponse struct {
+	Username  string            `json:"username"`
+	Grants    []policy.Grant    `json:"grants"`
+	Resource  policy.Resource   `json:"resource"`
+	Decisions []policy.Decision `json:"decisions"`
+}
+
+// GetCustomRoles lists custom roles
+// @Summary List custom roles
+// @Description Lists the custom roles of the caller's organization (every role for owners) and their policy statements
+// @Tags Roles
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.CustomRole
+// @Failure 500 {object} map[string]string
+// @Router /api/roles [get]
+func GetCustomRoles(c *gin.Context) {
+	var roles []models.CustomRole
+	if err := store.OrganizationQuery(config.DB, middleware.OrganizationFilter(c)).Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// CreateCustomRole defines a new custom role
+// @Summary Create a custom role
+// @Description Defines a role from allow/deny statements over actions and resource patterns
+// @Tags Roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param role body CustomRoleRequest true "Custom role"
+// @Success 201 {object} models.CustomRole
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/roles [post]
+func CreateCustomRole(c *gin.Context) {
+	var input CustomRoleRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !validateCustomRole(c, input) || !mayDefineRole(c, input) {
+		return
+	}
+
+	role := models.CustomRole{
+		Name: input.Name, Description: input.Description, Statements: input.Statements,
+		OrganizationID: middleware.CallerOrganization(c),
+	}
+	if err := config.DB.Create(&role).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role name already taken"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateCustomRole replaces a custom role's statements
+// @Summary Update a custom role
+// @Description Replaces a custom role's description and statements; the name cannot change, and callers cannot edit a role they hold
+// @Tags Roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Role ID"
+// @Param role body CustomRoleRequest true "Custom role"
+// @Success 200 {object} models.CustomRole
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/roles/{id} [put]
+func UpdateCustomRole(c *gin.Context) {
+	var role models.CustomRole
+	if err := config.DB.First(&role, c.Param("id")).Error; err != nil || !visible(c, role.OrganizationID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	var input CustomRoleRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Name != role.Name {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role names cannot be changed"})
+		return
+	}
+
+	if !validateCustomRole(c, input) || !mayDefineRole(c, input) {
+		return
+	}
+
+	role.Description = input.Description
+	role.Statements = input.Statements
+	if err := config.DB.Save(&role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteCustomRole removes a custom role that is no longer granted
+// @Summary Delete a custom role
+// @Description Deletes a custom role; roles still granted to users cannot be deleted
+// @Tags Roles
+// @Security BearerAuth
+// @Param id path int true "Role ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/roles/{id} [delete]
+func DeleteCustomRole(c *gin.Context) {
+	var role models.CustomRole
+	if err := config.DB.First(&role, c.Param("id")).Error; err != nil || !visible(c, role.OrganizationID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	// Only members of the role's organization can hold it; a role of the same name elsewhere is another role
+	organization := utils.OrganizationOf(role.OrganizationID)
+	var users, bindings int64
+	if err := store.OrganizationQuery(config.DB.Model(&models.User{}), &organization).Where("role = ?", role.Name).Count(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check role assignments"})
+		return
+	}
+	members := store.OrganizationQuery(config.DB.Model(&models.User{}), &organization).Select("id")
+	if err := config.DB.Model(&models.RoleBinding{}).Where("role = ? AND user_id IN (?)", role.Name, members).Count(&bindings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check role assignments"})
+		return
+	}
+	if users+bindings > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role is still granted to users"})
+		return
+	}
+
+	if err := config.DB.Delete(&role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// ExplainPermissions shows what a user may do on a resource
+// @Summary Explain permissions
+// @Description Evaluates every action for a user on a resource, showing which role and statement allowed or denied each; inspecting another user requires user:read
+// @Tags Roles
+// @Produce json
+// @Security BearerAuth
+// @Param user_id query int false "User to explain (defaults to the caller)"
+// @Param flag_id query int false "Flag to use as the resource"
+// @Param project query string false "Project"
+// @Param environment query string false "Environment"
+// @Success 200 {object} ExplainResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/auth/explain [get]
+func ExplainPermissions(c *gin.Context) {
+	claims, ok := middleware.CurrentClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	resource, _ := middleware.RequestResource(c, "")
+	if flagID := c.Query("flag_id"); flagID != "" {
+		var flag models.FeatureFlag
+		if err := config.DB.First(&flag, flagID).Error; err != nil || !visible(c, flag.OrganizationID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Feature flag not found"})
+			return
+		}
+		var err error
+		if resource, err = middleware.FlagResource(resource, &flag); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	response := ExplainResponse{Username: claims.Username, Grants: claims.Grants(), Resource: resource}
+	organization := claims.Organization
+
+	if userID := c.Query("user_id"); userID != "" {
+		if !middleware.AllowedOn(c, policy.UserRead, resource) {
+			return
+		}
+		id, err := strconv.ParseUint(userID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		grants, user, err := userGrants(uint(id))
+		if err != nil || !visible(c, user.OrganizationID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		response.Username, response.Grants = user.Username, grants
+		organization = utils.OrganizationOf(user.OrganizationID)
+	}
+
+	response.Decisions = middleware.Policies(response.Grants, organization).Explain(response.Grants, resource)
+	if c.Query("user_id") == "" {
+		for i := range response.Decisions {
+			response.Decisions[i] = policy.Restrict(response.Decisions[i], claims.Actions)
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// userGrants loads the roles currently assigned to a user from the database
+func userGrants(userID uint) ([]policy.Grant, *models.User, error) {
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var bindings []models.RoleBinding
+	if err := config.DB.Where("user_id = ?", user.ID).Find(&bindings).Error; err != nil {
+		return nil, nil, err
+	}
+
+	grants := append([]policy.Grant{{Role: user.Role}}, utils.ScopesFromBindings(bindings)...)
+	return grants, &user, nil
+}
+
+func validateCustomRole(c *gin.Context, input CustomRoleRequest) bool {
+	if policy.IsBuiltin(input.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Custom roles cannot reuse a built-in role name"})
+		return false
+	}
+
+	if err := policy.Validate(input.Statements); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// mayDefineRole stops role managers widening their own access: they cannot edit a role they
+// hold, and a role may only allow actions the caller is allowed on every resource
+func mayDefineRole(c *gin.Context, input CustomRoleRequest) bool {
+	claims, ok := middleware.CurrentClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return false
+	}
+
+	held, err := holdsRole(claims, input.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check role assignments"})
+		return false
+	}
+	if held {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Roles granted to you cannot be edited"})
+		return false
+	}
+
+	decide := middleware.Decider(claims)
+	for _, action := range policy.GrantedActions(input.Statements) {
+		if !decide(action, policy.Resource{}).Allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Cannot grant %s, which you are not allowed everywhere", action)})
+			return false
+		}
+	}
+	return true
+}
+
+// holdsRole reports whether the caller holds a role, either in their token or in the database
+func holdsRole(claims *utils.Claims, name string) (bool, error) {
+	for _, grant := range claims.Grants() {
+		if grant.Role == name {
+			return true, nil
+		}
+	}
+
+	var users, bindings int64
+	if err := config.DB.Model(&models.User{}).Where("username = ? AND role = ?", claims.Username, name).Count(&users).Error; err != nil {
+		return false, err
+	}
+	err := config.DB.Model(&models.RoleBinding{}).
+		Joins("JOIN users ON users.id = role_bindings.user_id").
+		Where("users.username = ? AND role_bindings.role = ?", claims.Username, name).
+		Count(&bindings).Error
+	return users+bindings > 0, err
+}
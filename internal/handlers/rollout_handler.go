@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/rollouts"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RolloutPlanRequest represents the expected body for starting a rollout plan
+type RolloutPlanRequest struct {
+	Steps []models.RolloutStep `json:"steps" binding:"required"`
+}
+
+// StartRollout attaches a rollout plan to a feature flag
+// @Summary Start a progressive rollout
+// @Description Attaches a rollout plan to a flag and applies its first step; later steps are advanced by the scheduler
+// @Tags Rollouts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Feature flag ID"
+// @Param plan body RolloutPlanRequest true "Rollout steps"
+// @Success 201 {object} models.RolloutPlan
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/flags/{id}/rollout [post]
+func StartRollout(c *gin.Context) {
+	flagID, ok := parseFlagID(c)
+	if !ok {
+		return
+	}
+
+	var featureFlag models.FeatureFlag
+	if err := config.DB.First(&featureFlag, flagID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Feature flag not found"})
+		return
+	}
+
+	var input RolloutPlanRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, err := rollouts.Start(&featureFlag, input.Steps, time.Now())
+	if err != nil {
+		respondRolloutError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, plan)
+}
+
+// PauseRollout pauses a flag's active rollout plan
+// @Summary Pause a rollout
+// @Description Freezes the active rollout plan on its current step
+// @Tags Rollouts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Feature flag ID"
+// @Success 200 {object} models.RolloutPlan
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/flags/{id}/rollout/pause [post]
+func PauseRollout(c *gin.Context) {
+	flagID, ok := parseFlagID(c)
+	if !ok {
+		return
+	}
+
+	plan, err := rollouts.Pause(flagID, time.Now())
+	if err != nil {
+		respondRolloutError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// ResumeRollout resumes a paused rollout plan
+// @Summary Resume a rollout
+// @Description Continues a paused rollout plan with the time remaining on its current step
+// @Tags Rollouts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Feature flag ID"
+// @Success 200 {object} models.RolloutPlan
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/flags/{id}/rollout/resume [post]
+func ResumeRollout(c *gin.Context) {
+	flagID, ok := parseFlagID(c)
+	if !ok {
+		return
+	}
+
+	plan, err := rollouts.Resume(flagID, time.Now())
+	if err != nil {
+		respondRolloutError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// AbortRollout aborts a rollout plan and restores the flag
+// @Summary Abort a rollout
+// @Description Stops the active rollout plan and returns the flag to the state it had when the plan started
+// @Tags Rollouts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Feature flag ID"
+// @Success 200 {object} models.RolloutPlan
+// @Failure 404 {object} map[string]string
+// @Router /api/flags/{id}/rollout/abort [post]
+func AbortRollout(c *gin.Context) {
+	flagID, ok := parseFlagID(c)
+	if !ok {
+		return
+	}
+
+	plan, err := rollouts.Abort(flagID)
+	if err != nil {
+		respondRolloutError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+func respondRolloutError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, rollouts.ErrNoActivePlan):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, rollouts.ErrPlanActive), errors.Is(err, rollouts.ErrInvalidState):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, rollouts.ErrInvalidSteps):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rollout plan"})
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/degraded"
+	"feature-flag-service/internal/evaluation"
+	"feature-flag-service/internal/lifecycle"
+	"feature-flag-service/internal/metrics"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/snapshot"
+	"feature-flag-service/internal/store"
+	"feature-flag-service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SDKFlagsResponse is the flag configuration payload served to server-side SDKs
+type SDKFlagsResponse struct {
+	Environment string               `json:"environment"`
+	Flags       []models.FeatureFlag `json:"flags"`
+}
+
+// SDKEvaluationResponse holds the evaluated client-visible flags for one user
+type SDKEvaluationResponse struct {
+	Environment string                       `json:"environment"`
+	Flags       map[string]evaluation.Result `json:"flags"`
+}
+
+// GetSDKFlags returns all flag configurations for server-side SDKs
+// @Summary Get flag configurations (server SDK)
+// @Description Returns every non-archived flag configuration in the key's environment, plus flags not bound to an environment, for local evaluation; requires a server SDK key
+// @Tags SDK
+// @Produce json
+// @Param Authorization header string true "Server SDK key"
+// @Success 200 {object} SDKFlagsResponse
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /sdk/flags [get]
+func GetSDKFlags(c *gin.Context) {
+	key, _ := middleware.CurrentSDKKey(c)
+
+	featureFlags, ok := sdkFlags(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, SDKFlagsResponse{Environment: key.Environment, Flags: featureFlags})
+}
+
+// EvaluateSDKFlags evaluates flags for a user on behalf of an SDK
+// @Summary Evaluate flags (client SDK)
+// @Description Evaluates the key's environment's flags for a user; client keys only see flags marked client_visible
+// @Tags SDK
+// @Produce json
+// @Param Authorization header string true "Client or server SDK key"
+// @Param key query string false "User key used for percentage rollouts"
+// @Success 200 {object} SDKEvaluationResponse
+// @Failure 401 {object} map[string]string
+// @Router /sdk/evaluate [get]
+func EvaluateSDKFlags(c *gin.Context) {
+	key, _ := middleware.CurrentSDKKey(c)
+
+	featureFlags, ok := sdkFlags(c)
+	if !ok {
+		return
+	}
+
+	byName := make(map[string]*models.FeatureFlag, len(featureFlags))
+	for i := range featureFlags {
+		byName[featureFlags[i].Name] = &featureFlags[i]
+	}
+
+	now := time.Now()
+	ctx := evaluation.Context{
+		Key:    c.Query("key"),
+		Now:    now,
+		Lookup: func(name string) *models.FeatureFlag { return byName[name] },
+	}
+
+	results := make(map[string]evaluation.Result)
+	var usages []lifecycle.Usage
+	for i := range featureFlags {
+		flag := &featureFlags[i]
+		if key.Kind == models.SDKKeyClient && !flag.ClientVisible {
+			continue
+		}
+		result := evaluation.Evaluate(flag, ctx)
+		results[flag.Name] = result
+		metrics.RecordEvaluation(result.Flag, result.Variation, result.Reason)
+		usages = append(usages, lifecycle.Usage{Flag: flag, Variation: result.Variation})
+	}
+
+	if err := lifecycle.RecordEvaluations(usages, now); err != nil {
+		log.Printf("⚠️ Failed to record evaluations for %s: %v", key.Environment, err)
+	}
+
+	c.JSON(http.StatusOK, SDKEvaluationResponse{Environment: key.Environment, Flags: results})
+}
+
+// sdkFlags loads the flags served to an SDK key: those of the key's organization in its
+// environment plus flags not bound to any environment. Archived flags are left out of SDK
+// payloads. While the database is unavailable the flags come from the last snapshot.
+func sdkFlags(c *gin.Context) ([]models.FeatureFlag, bool) {
+	key, _ := middleware.CurrentSDKKey(c)
+	org := utils.OrganizationOf(key.OrganizationID)
+
+	if !degraded.Down(degraded.Database) {
+		var featureFlags []models.FeatureFlag
+		err := store.OrganizationQuery(config.DB, &org).
+			Where("state <> ? AND environment IN ?", models.FlagStateArchived, []string{"", key.Environment}).
+			Find(&featureFlags).Error
+		if err == nil {
+			return featureFlags, true
+		}
+	}
+
+	snap := servedSnapshot(c)
+	if snap == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve feature flags"})
+		return nil, false
+	}
+	featureFlags := []models.FeatureFlag{}
+	for _, flag := range snap.Flags {
+		if flag.State != models.FlagStateArchived && (flag.Environment == "" || flag.Environment == key.Environment) &&
+			utils.OrganizationOf(flag.OrganizationID) == org {
+			featureFlags = append(featureFlags, flag)
+		}
+	}
+	return featureFlags, true
+}
+
+// SnapshotHeader marks responses served from the flag snapshot with the time it was taken
+const SnapshotHeader = "X-Flag-Snapshot"
+
+// servedSnapshot returns the current snapshot and marks the response as served from it
+func servedSnapshot(c *gin.Context) *snapshot.Snapshot {
+	snap := snapshot.Current()
+	if snap != nil {
+		c.Header(SnapshotHeader, snap.TakenAt.UTC().Format(time.RFC3339))
+	}
+	return snap
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/policy"
+	"feature-flag-service/internal/sdkkeys"
+	"feature-flag-service/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SDKKeyRequest represents the expected body for creating an SDK key
+type SDKKeyRequest struct {
+	Environment string `json:"environment" binding:"required" example:"production"`
+	Kind        string `json:"kind" binding:"required" example:"server"`
+}
+
+// RotateSDKKeyRequest represents the expected body for rotating an SDK key
+type RotateSDKKeyRequest struct {
+	GracePeriod string `json:"grace_period" example:"24h"`
+}
+
+// SDKKeyResponse includes the plaintext key, which is only ever shown once
+type SDKKeyResponse struct {
+	models.SDKKey
+	Key string `json:"key"`
+}
+
+// GetSDKKeys lists SDK keys
+// @Summary List SDK keys
+// @Description Lists the SDK keys the caller may read, optionally for one environment; secrets are never returned
+// @Tags SDK Keys
+// @Produce json
+// @Security BearerAuth
+// @Param environment query string false "Environment"
+// @Success 200 {array} models.SDKKey
+// @Failure 500 {object} map[string]string
+// @Router /api/sdk-keys [get]
+func GetSDKKeys(c *gin.Context) {
+	query := store.OrganizationQuery(config.DB, middleware.OrganizationFilter(c))
+	if requested, _ := middleware.RequestResource(c, ""); requested.Environment != "" {
+		query = query.Where("environment = ?", requested.Environment)
+	}
+
+	var keys []models.SDKKey
+	if err := query.Order("environment, created_at").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve SDK keys"})
+		return
+	}
+
+	// The route only checks that the caller may read keys somewhere, so drop the rest
+	readable := []models.SDKKey{}
+	if claims, ok := middleware.CurrentClaims(c); ok {
+		decide := middleware.Decider(claims)
+		for _, key := range keys {
+			if decide(policy.SDKKeyRead, policy.Resource{Environment: key.Environment}).Allowed {
+				readable = append(readable, key)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, readable)
+}
+
+// CreateSDKKey issues a new SDK key for an environment
+// @Summary Create an SDK key
+// @Description Issues a server (full flag config) or client (evaluation of client-visible flags) key; the key is shown only once
+// @Tags SDK Keys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param key body SDKKeyRequest true "Environment and kind"
+// @Success 201 {object} SDKKeyResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/sdk-keys [post]
+func CreateSDKKey(c *gin.Context) {
+	var input SDKKeyRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	requested, _ := middleware.RequestResource(c, "")
+	resource, err := middleware.Scoped(requested, policy.Resource{Environment: input.Environment})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !middleware.AllowedOn(c, policy.SDKKeyManage, resource) {
+		return
+	}
+
+	key, plaintext, err := sdkkeys.Create(input.Environment, input.Kind, middleware.CallerOrganization(c))
+	if errors.Is(err, sdkkeys.ErrInvalidKind) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create SDK key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, SDKKeyResponse{SDKKey: *key, Key: plaintext})
+}
+
+// RotateSDKKey replaces an SDK key, keeping the old one valid for a grace period
+// @Summary Rotate an SDK key
+// @Description Issues a replacement key; the old key keeps working until the grace period (default 24h) ends
+// @Tags SDK Keys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "SDK key ID"
+// @Param rotation body RotateSDKKeyRequest false "Grace period"
+// @Success 201 {object} SDKKeyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/sdk-keys/{id}/rotate [post]
+func RotateSDKKey(c *gin.Context) {
+	id, ok := parseID(c, "SDK key")
+	if !ok {
+		return
+	}
+
+	var input RotateSDKKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	grace := sdkkeys.DefaultGracePeriod
+	if input.GracePeriod != "" {
+		d, err := time.ParseDuration(input.GracePeriod)
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "grace_period must be a duration such as \"24h\""})
+			return
+		}
+		grace = d
+	}
+
+	key, plaintext, err := sdkkeys.Rotate(id, grace, time.Now())
+	switch {
+	case errors.Is(err, sdkkeys.ErrKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, sdkkeys.ErrInvalidKey):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expired keys cannot be rotated"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate SDK key"})
+	default:
+		c.JSON(http.StatusCreated, SDKKeyResponse{SDKKey: *key, Key: plaintext})
+	}
+}
+
+// RevokeSDKKey deletes an SDK key immediately
+// @Summary Revoke an SDK key
+// @Description Deletes an SDK key with no grace period
+// @Tags SDK Keys
+// @Security BearerAuth
+// @Param id path int true "SDK key ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/sdk-keys/{id} [delete]
+func RevokeSDKKey(c *gin.Context) {
+	result := config.DB.Delete(&models.SDKKey{}, c.Param("id"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke SDK key"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SDK key not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "SDK key revoked successfully"})
+}
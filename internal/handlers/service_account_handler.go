@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/store"
+	"feature-flag-service/internal/tokens"
+	"feature-flag-service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ServiceAccountRequest represents the expected body for creating a service account
+type ServiceAccountRequest struct {
+	Name        string `json:"name" binding:"required" example:"ci-pipeline"`
+	Description string `json:"description"`
+	Role        string `json:"role" binding:"required" example:"editor"`
+	Project     string `json:"project" example:"checkout"`
+	Environment string `json:"environment" example:"staging"`
+}
+
+// GetServiceAccounts lists service accounts
+// @Summary List service accounts
+// @Description Lists the caller's organization's non-human accounts used for automation (every account for owners)
+// @Tags Service Accounts
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.ServiceAccount
+// @Failure 500 {object} map[string]string
+// @Router /api/service-accounts [get]
+func GetServiceAccounts(c *gin.Context) {
+	var accounts []models.ServiceAccount
+	if err := store.OrganizationQuery(config.DB, middleware.OrganizationFilter(c)).Order("name").Find(&accounts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve service accounts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, accounts)
+}
+
+// CreateServiceAccount creates a service account with a role
+// @Summary Create a service account
+// @Description Creates an account for automation holding one role, optionally limited to a project and/or environment
+// @Tags Service Accounts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param account body ServiceAccountRequest true "Service account"
+// @Success 201 {object} models.ServiceAccount
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/service-accounts [post]
+func CreateServiceAccount(c *gin.Context) {
+	var input ServiceAccountRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !canGrant(c, input.Role, middleware.CallerOrganization(c)) {
+		return
+	}
+
+	// Names are unique within an organization, so other organizations' accounts don't count
+	organization := utils.OrganizationOf(middleware.CallerOrganization(c))
+	var existing models.ServiceAccount
+	if err := store.OrganizationQuery(config.DB, &organization).Where("name = ?", input.Name).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "A service account with this name already exists"})
+		return
+	}
+
+	account := models.ServiceAccount{
+		Name:        input.Name,
+		Description: input.Description,
+		Role:        input.Role,
+		Project:     input.Project,
+		Environment: input.Environment,
+
+		OrganizationID: middleware.CallerOrganization(c),
+	}
+	if err := config.DB.Create(&account).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create service account"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, account)
+}
+
+// DeleteServiceAccount deletes a service account and revokes its tokens
+// @Summary Delete a service account
+// @Description Deletes a service account; all of its access tokens stop working immediately
+// @Tags Service Accounts
+// @Security BearerAuth
+// @Param id path int true "Service account ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/service-accounts/{id} [delete]
+func DeleteServiceAccount(c *gin.Context) {
+	account, ok := findServiceAccount(c)
+	if !ok || !canGrant(c, account.Role, account.OrganizationID) {
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.AccessToken{}).
+			Where("service_account_id = ? AND revoked_at IS NULL", account.ID).
+			UpdateColumn("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Delete(account).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete service account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Service account deleted successfully"})
+}
+
+// GetServiceAccountTokens lists a service account's tokens
+// @Summary List a service account's tokens
+// @Description Lists a service account's access tokens with their scopes, expiry and last use
+// @Tags Service Accounts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Service account ID"
+// @Success 200 {array} models.AccessToken
+// @Failure 404 {object} map[string]string
+// @Router /api/service-accounts/{id}/tokens [get]
+func GetServiceAccountTokens(c *gin.Context) {
+	account, ok := findServiceAccount(c)
+	if !ok {
+		return
+	}
+
+	var accessTokens []models.AccessToken
+	if err := config.DB.Where("service_account_id = ?", account.ID).Order("created_at").Find(&accessTokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve access tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, accessTokens)
+}
+
+// CreateServiceAccountToken issues an access token for a service account
+// @Summary Create a service account token
+// @Description Issues a token acting as the service account, optionally limited to action scopes and an expiry; the token is shown only once
+// @Tags Service Accounts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Service account ID"
+// @Param token body AccessTokenRequest true "Token name, scopes and expiry"
+// @Success 201 {object} AccessTokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/service-accounts/{id}/tokens [post]
+func CreateServiceAccountToken(c *gin.Context) {
+	account, ok := findServiceAccount(c)
+	if !ok || !canGrant(c, account.Role, account.OrganizationID) {
+		return
+	}
+
+	createAccessToken(c, &models.AccessToken{ServiceAccountID: &account.ID})
+}
+
+// RevokeServiceAccountToken revokes a service account's token
+// @Summary Revoke a service account token
+// @Description Revokes a token immediately; it stays listed with its revoked_at time
+// @Tags Service Accounts
+// @Security BearerAuth
+// @Param id path int true "Service account ID"
+// @Param tokenId path int true "Access token ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/service-accounts/{id}/tokens/{tokenId} [delete]
+func RevokeServiceAccountToken(c *gin.Context) {
+	account, ok := findServiceAccount(c)
+	if !ok || !canGrant(c, account.Role, account.OrganizationID) {
+		return
+	}
+
+	var token models.AccessToken
+	if err := config.DB.Where("service_account_id = ?", account.ID).First(&token, c.Param("tokenId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": tokens.ErrTokenNotFound.Error()})
+		return
+	}
+
+	revokeAccessToken(c, &token)
+}
+
+func findServiceAccount(c *gin.Context) (*models.ServiceAccount, bool) {
+	id, ok := parseID(c, "service account")
+	if !ok {
+		return nil, false
+	}
+
+	var account models.ServiceAccount
+	if err := config.DB.First(&account, id).Error; err != nil || !visible(c, account.OrganizationID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service account not found"})
+		return nil, false
+	}
+	return &account, true
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/sessions"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSessions lists the caller's active sessions
+// @Summary List active sessions
+// @Description Lists the devices the caller is logged in on; the session used for this request is marked current
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Session
+// @Failure 403 {object} map[string]string
+// @Router /api/sessions [get]
+func GetSessions(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	active, err := sessions.Active(user.ID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sessions"})
+		return
+	}
+
+	claims, _ := middleware.CurrentClaims(c)
+	for i := range active {
+		active[i].Current = active[i].ID == claims.SessionID
+	}
+
+	c.JSON(http.StatusOK, active)
+}
+
+// RevokeSession ends one of the caller's sessions
+// @Summary Revoke a session
+// @Description Logs out a device, e.g. a lost laptop; its refresh and access tokens stop working immediately
+// @Tags Authentication
+// @Security BearerAuth
+// @Param id path int true "Session ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/sessions/{id} [delete]
+func RevokeSession(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var session models.Session
+	if err := config.DB.Where("user_id = ?", user.ID).First(&session, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": sessions.ErrSessionNotFound.Error()})
+		return
+	}
+
+	if err := sessions.Revoke(&session, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
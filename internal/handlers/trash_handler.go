@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/dependencies"
+	"feature-flag-service/internal/lifecycle"
+	"feature-flag-service/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeletedFeatureFlag is a soft-deleted flag as shown in the trash
+type DeletedFeatureFlag struct {
+	models.FeatureFlag
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// GetDeletedFeatureFlags lists flags in the trash
+// @Summary List deleted feature flags
+// @Description Lists soft-deleted flags that can still be restored, most recently deleted first
+// @Tags Trash
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} DeletedFeatureFlag
+// @Failure 500 {object} map[string]string
+// @Router /api/trash/flags [get]
+func GetDeletedFeatureFlags(c *gin.Context) {
+	var featureFlags []models.FeatureFlag
+	if err := config.DB.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&featureFlags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve deleted feature flags"})
+		return
+	}
+
+	featureFlags = readableFlags(c, featureFlags)
+	deleted := make([]DeletedFeatureFlag, len(featureFlags))
+	for i, flag := range featureFlags {
+		deleted[i] = DeletedFeatureFlag{FeatureFlag: flag, DeletedAt: flag.DeletedAt.Time}
+	}
+
+	c.JSON(http.StatusOK, deleted)
+}
+
+// RestoreFeatureFlag restores a flag from the trash
+// @Summary Restore a deleted feature flag
+// @Description Undeletes a flag; fails if its name has since been reused or one of its prerequisites no longer exists
+// @Tags Trash
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Feature flag ID"
+// @Success 200 {object} models.FeatureFlag
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/trash/flags/{id}/restore [post]
+func RestoreFeatureFlag(c *gin.Context) {
+	flagID, ok := parseFlagID(c)
+	if !ok {
+		return
+	}
+
+	featureFlag, err := lifecycle.Restore(flagID)
+	switch {
+	case errors.Is(err, lifecycle.ErrNotInTrash):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, lifecycle.ErrNameTaken), errors.Is(err, dependencies.ErrInvalidPrerequisite), errors.Is(err, dependencies.ErrCycle):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore feature flag"})
+	default:
+		c.JSON(http.StatusOK, featureFlag)
+	}
+}
+
+// PurgeFeatureFlag permanently deletes a flag from the trash
+// @Summary Permanently delete a feature flag
+// @Description Purges a soft-deleted flag and its rollout plans, guardrails, signals and history
+// @Tags Trash
+// @Security BearerAuth
+// @Param id path int true "Feature flag ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/trash/flags/{id} [delete]
+func PurgeFeatureFlag(c *gin.Context) {
+	flagID, ok := parseFlagID(c)
+	if !ok {
+		return
+	}
+
+	err := lifecycle.Purge(flagID)
+	switch {
+	case errors.Is(err, lifecycle.ErrNotInTrash):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge feature flag"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Feature flag permanently deleted"})
+	}
+}
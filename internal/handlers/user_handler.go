@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"feature-flag-service/internal/audit"
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/policy"
+	"feature-flag-service/internal/sessions"
+	"feature-flag-service/internal/store"
+	"feature-flag-service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleRequest represents the expected body for changing a user's role
+type RoleRequest struct {
+	Role string `json:"role" binding:"required" example:"editor"`
+}
+
+// RoleBindingRequest represents the expected body for granting a scoped role
+type RoleBindingRequest struct {
+	Project     string `json:"project" example:"checkout"`
+	Environment string `json:"environment" example:"staging"`
+	Role        string `json:"role" binding:"required" example:"editor"`
+}
+
+// UserHandler serves the user administration endpoints from an injected store
+type UserHandler struct {
+	Users store.UserStore
+}
+
+// NewUserHandler creates a UserHandler backed by the given store
+func NewUserHandler(users store.UserStore) *UserHandler {
+	return &UserHandler{Users: users}
+}
+
+// maxUserPageSize caps how many users one request returns
+const maxUserPageSize = 500
+
+// GetUsers lists and searches users
+// @Summary List users
+// @Description Lists user accounts of the caller's organization (every account for owners), optionally searching username, email and display name, or filtering by role or status
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param q query string false "Search text"
+// @Param role query string false "Global role"
+// @Param disabled query bool false "Only disabled (true) or enabled (false) users"
+// @Param limit query int false "Page size (default 100, max 500)"
+// @Param offset query int false "Number of users to skip"
+// @Success 200 {array} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/users [get]
+func (h *UserHandler) GetUsers(c *gin.Context) {
+	filter := store.UserFilter{Query: c.Query("q"), Role: c.Query("role"), Organization: middleware.OrganizationFilter(c)}
+	if disabled := c.Query("disabled"); disabled != "" {
+		value, err := strconv.ParseBool(disabled)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "disabled must be true or false"})
+			return
+		}
+		filter.Disabled = &value
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > maxUserPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must not be negative"})
+		return
+	}
+
+	filter.Limit, filter.Offset = limit, offset
+
+	users, err := h.Users.ListUsers(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// GetUser returns a single user
+// @Summary Get a user
+// @Description Returns a user account with its role, status and last login
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 404 {object} map[string]string
+// @Router /api/users/{id} [get]
+func (h *UserHandler) GetUser(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// DisableUser blocks a user from logging in and ends their sessions
+// @Summary Disable a user
+// @Description Blocks logins, ends all sessions and stops the user's personal access tokens from working
+// @Tags Users
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/users/{id}/disable [post]
+func (h *UserHandler) DisableUser(c *gin.Context) {
+	user, ok := h.managedUser(c)
+	if !ok {
+		return
+	}
+
+	user.Disabled = true
+	if err := h.Users.UpdateUser(user, "disabled"); err != nil {
+		respondUserWriteError(c, err, "Failed to disable user")
+		return
+	}
+	if err := sessions.RevokeAll(user.ID, 0, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end the user's sessions"})
+		return
+	}
+	auditUserChange(c, models.AuditUserDisabled, user, "")
+
+	c.JSON(http.StatusOK, user)
+}
+
+// EnableUser allows a disabled user to log in again
+// @Summary Enable a user
+// @Description Re-enables a disabled user account
+// @Tags Users
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/users/{id}/enable [post]
+func (h *UserHandler) EnableUser(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+	if !canGrant(c, user.Role, user.OrganizationID) {
+		return
+	}
+
+	user.Disabled = false
+	if err := h.Users.UpdateUser(user, "disabled"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable user"})
+		return
+	}
+	auditUserChange(c, models.AuditUserEnabled, user, "")
+
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteUser deletes a user account
+// @Summary Delete a user
+// @Description Deletes a user, their scoped roles and sessions, and revokes their personal access tokens
+// @Tags Users
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/users/{id} [delete]
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	user, ok := h.managedUser(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if err := h.Users.DeleteUser(user, now); err != nil {
+		respondUserWriteError(c, err, "Failed to delete user")
+		return
+	}
+	if err := sessions.RevokeAll(user.ID, 0, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end the user's sessions"})
+		return
+	}
+	auditUserChange(c, models.AuditUserDeleted, user, "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
+
+// UpdateUserRole changes a user's global role
+// @Summary Change a user's role
+// @Description Sets a user's global role (built-in or custom); callers cannot grant a role above their own
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param role body RoleRequest true "New role"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/users/{id}/role [put]
+func (h *UserHandler) UpdateUserRole(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	var input RoleRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !canGrant(c, input.Role, user.OrganizationID) || !canGrant(c, user.Role, user.OrganizationID) {
+		return
+	}
+
+	previous := user.Role
+	user.Role = input.Role
+	if err := h.Users.UpdateUser(user, "role"); err != nil {
+		respondUserWriteError(c, err, "Failed to update role")
+		return
+	}
+	auditUserChange(c, models.AuditRoleChanged, user, previous+" -> "+user.Role)
+
+	c.JSON(http.StatusOK, user)
+}
+
+// GetRoleBindings lists a user's project and environment roles
+// @Summary List a user's scoped roles
+// @Description Lists the roles a user holds in specific projects or environments
+// @Tags Users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {array} models.RoleBinding
+// @Failure 500 {object} map[string]string
+// @Router /api/users/{id}/bindings [get]
+func GetRoleBindings(c *gin.Context) {
+	var bindings []models.RoleBinding
+	if err := config.DB.Where("user_id = ?", c.Param("id")).Find(&bindings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve role bindings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, bindings)
+}
+
+// CreateRoleBinding grants a user a role in a project and/or environment
+// @Summary Grant a scoped role
+// @Description Grants a role in a project and/or environment, e.g. editor in staging; takes effect on the next token refresh
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param binding body RoleBindingRequest true "Scoped role"
+// @Success 201 {object} models.RoleBinding
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/users/{id}/bindings [post]
+func CreateRoleBinding(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var input RoleBindingRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Project == "" && input.Environment == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A project or environment is required; use the role endpoint for global roles"})
+		return
+	}
+
+	if !canGrant(c, input.Role, user.OrganizationID) {
+		return
+	}
+
+	binding := models.RoleBinding{
+		UserID:      user.ID,
+		Project:     input.Project,
+		Environment: input.Environment,
+		Role:        input.Role,
+	}
+	if err := config.DB.Create(&binding).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role binding"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, binding)
+}
+
+// DeleteRoleBinding revokes a scoped role
+// @Summary Revoke a scoped role
+// @Description Removes a project or environment role from a user
+// @Tags Users
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param bindingId path int true "Role binding ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/users/{id}/bindings/{bindingId} [delete]
+func DeleteRoleBinding(c *gin.Context) {
+	var binding models.RoleBinding
+	if err := config.DB.Where("user_id = ?", c.Param("id")).First(&binding, c.Param("bindingId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role binding not found"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.Select("organization_id").First(&user, binding.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role binding not found"})
+		return
+	}
+	if !canGrant(c, binding.Role, user.OrganizationID) {
+		return
+	}
+
+	if err := config.DB.Delete(&binding).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role binding"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role binding deleted successfully"})
+}
+
+// findUser loads the :id user, responding with 400 or 404 on failure; users of other
+// organizations are not found
+func (h *UserHandler) findUser(c *gin.Context) (*models.User, bool) {
+	id, ok := parseID(c, "user")
+	if !ok {
+		return nil, false
+	}
+
+	user, err := h.Users.GetUser(id)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !visible(c, user.OrganizationID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return nil, false
+	}
+	return user, true
+}
+
+// managedUser loads the :id user for disabling or deleting, refusing the caller's own account
+// and users above the caller's role
+func (h *UserHandler) managedUser(c *gin.Context) (*models.User, bool) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	if claims, ok := middleware.CurrentClaims(c); ok && claims.Username == user.Username {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot disable or delete your own account"})
+		return nil, false
+	}
+	if !canGrant(c, user.Role, user.OrganizationID) {
+		return nil, false
+	}
+	return user, true
+}
+
+// respondUserWriteError explains a refused or failed user change; the store refuses to remove
+// the last active owner, which would leave nobody able to manage owners
+func respondUserWriteError(c *gin.Context, err error, message string) {
+	if errors.Is(err, store.ErrLastOwner) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The last owner cannot be removed, disabled or demoted"})
+		return
+	}
+	if errors.Is(err, store.ErrUserTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
+
+func auditUserChange(c *gin.Context, action string, user *models.User, detail string) {
+	entry := models.AuditEntry{Action: action, Target: user.Username, IPAddress: c.ClientIP(), Detail: detail}
+	if claims, ok := middleware.CurrentClaims(c); ok {
+		entry.Actor = claims.Username
+	}
+	audit.Record(entry)
+}
+
+// requireOwner responds with 403 unless the caller is an owner, for settings that span every organization
+func requireOwner(c *gin.Context, message string) bool {
+	claims, ok := middleware.CurrentClaims(c)
+	if !ok || claims.Role != models.RoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": message})
+		return false
+	}
+	return true
+}
+
+// canGrant checks a role exists in the organization of the user or account holding it, nil for
+// none, and that the caller may hand it out: built-in roles up to the caller's own global role,
+// and custom roles of the caller's organization only by callers who hold policy.GrantRole and are
+// allowed everything the role allows, as if they had written it
+func canGrant(c *gin.Context, role string, organization *uint) bool {
+	claims, ok := middleware.CurrentClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return false
+	}
+
+	required := role
+	if !policy.IsBuiltin(role) {
+		var custom models.CustomRole
+		org := utils.OrganizationOf(organization)
+		query := store.OrganizationQuery(config.DB.Select("organization_id", "statements"), &org)
+		if err := query.Where("name = ?", role).First(&custom).Error; err != nil || !visible(c, custom.OrganizationID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+			return false
+		}
+		required = policy.GrantRole(custom.Statements)
+
+		decide := middleware.Decider(claims)
+		for _, action := range policy.GrantedActions(custom.Statements) {
+			if !decide(action, policy.Resource{}).Allowed {
+				c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Cannot grant a role that allows %s, which you are not allowed everywhere", action)})
+				return false
+			}
+		}
+	}
+
+	if models.RoleRank(required) > models.RoleRank(claims.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot manage a role above your own"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+// Package health runs dependency checks for the readiness probe and the detailed health report.
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Check statuses
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded" // A non-critical check is failing; the service still takes traffic
+	StatusFailing  = "failing"
+)
+
+// DefaultTimeout bounds each check so a hung dependency cannot hang the probe
+const DefaultTimeout = 2 * time.Second
+
+// Check is a named probe of one dependency. A failing critical check makes the service not ready.
+type Check struct {
+	Name     string
+	Critical bool
+	Run      func(ctx context.Context) error
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Critical    bool       `json:"critical"`
+	LatencyMS   float64    `json:"latency_ms"`
+	Error       string     `json:"error,omitempty"`      // Error from this run
+	LastError   string     `json:"last_error,omitempty"` // Most recent error, even if the check has since recovered
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Report is the combined outcome of every check
+type Report struct {
+	Status    string    `json:"status"`
+	Ready     bool      `json:"ready"`
+	CheckedAt time.Time `json:"checked_at"`
+	Checks    []Result  `json:"checks"`
+}
+
+// degradedError marks a failure that should not take the service out of rotation
+type degradedError struct{ err error }
+
+func (d degradedError) Error() string { return d.err.Error() }
+func (d degradedError) Unwrap() error { return d.err }
+
+// Degraded wraps an error from a check so it is reported without making the service not ready
+func Degraded(err error) error {
+	if err == nil {
+		return nil
+	}
+	return degradedError{err}
+}
+
+type lastError struct {
+	message string
+	at      time.Time
+}
+
+// Checker holds the registered checks and remembers their last errors
+type Checker struct {
+	Timeout time.Duration
+
+	mu     sync.Mutex
+	checks []Check
+	errors map[string]lastError
+}
+
+// NewChecker creates a checker with the default per-check timeout
+func NewChecker() *Checker {
+	return &Checker{Timeout: DefaultTimeout, errors: map[string]lastError{}}
+}
+
+// Add registers a check; checks are reported in registration order
+func (h *Checker) Add(name string, critical bool, run func(ctx context.Context) error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, Check{Name: name, Critical: critical, Run: run})
+}
+
+// Run executes every check concurrently and reports the service as ready when all critical checks pass
+func (h *Checker) Run(ctx context.Context) Report {
+	h.mu.Lock()
+	checks := append([]Check(nil), h.checks...)
+	h.mu.Unlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = h.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Ready: true, CheckedAt: time.Now(), Checks: results}
+	for _, r := range results {
+		if r.Status == StatusOK {
+			continue
+		}
+		if r.Critical && r.Status == StatusFailing {
+			report.Status, report.Ready = StatusFailing, false
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+func (h *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	started := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check.Run(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{Name: check.Name, Status: StatusOK, Critical: check.Critical,
+		LatencyMS: float64(time.Since(started).Microseconds()) / 1000}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		result.Status, result.Error = StatusFailing, err.Error()
+		if errors.As(err, &degradedError{}) {
+			result.Status = StatusDegraded
+		}
+		h.errors[check.Name] = lastError{message: err.Error(), at: started}
+	}
+	if last, ok := h.errors[check.Name]; ok {
+		at := last.at
+		result.LastError, result.LastErrorAt = last.message, &at
+	}
+	return result
+}
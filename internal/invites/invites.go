@@ -0,0 +1,126 @@
+package invites
+
+import (
+	"errors"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/passwords"
+	"feature-flag-service/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Prefix marks invite tokens so they are recognisable when pasted into the wrong place
+const Prefix = "ffi_"
+
+// DefaultTTL is how long an invite stays valid unless the admin chooses otherwise
+const DefaultTTL = 7 * 24 * time.Hour
+
+// MaxTTL caps how long an invite may stay valid
+const MaxTTL = 30 * 24 * time.Hour
+
+var (
+	ErrInvalidInvite       = errors.New("invite is invalid, expired, revoked or already used")
+	ErrInvalidTTL          = errors.New("invites must expire within 30 days")
+	ErrUsernameTaken       = errors.New("username already taken")
+	ErrEmailTaken          = errors.New("email already in use")
+	ErrInviteNotFound      = errors.New("invite not found")
+	ErrUnknownOrganization = errors.New("organization not found")
+)
+
+// Create generates the token for an invite, stores its hash and returns the plaintext,
+// which is never retrievable again
+func Create(invite *models.Invite, ttl time.Duration, now time.Time) (string, error) {
+	if ttl <= 0 || ttl > MaxTTL {
+		return "", ErrInvalidTTL
+	}
+	if err := config.DB.First(&models.Organization{}, invite.OrganizationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrUnknownOrganization
+		}
+		return "", err
+	}
+
+	plaintext, err := utils.NewSecret(Prefix)
+	if err != nil {
+		return "", err
+	}
+
+	invite.TokenHash = utils.HashSecret(plaintext)
+	invite.ExpiresAt = now.Add(ttl)
+	if err := config.DB.Create(invite).Error; err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+// Revoke stops a pending invite from being accepted
+func Revoke(id uint, now time.Time) (*models.Invite, error) {
+	var invite models.Invite
+	if err := config.DB.Where("accepted_at IS NULL AND revoked_at IS NULL").First(&invite, id).Error; err != nil {
+		return nil, ErrInviteNotFound
+	}
+	invite.RevokedAt = &now
+	if err := config.DB.Model(&invite).UpdateColumn("revoked_at", now).Error; err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// Accept redeems an invite, creating a user with the invited organization and role once the
+// password passes the policy. The invite row is locked so two people racing with the same token cannot both get an account.
+func Accept(plaintext, username, password string, now time.Time) (*models.User, error) {
+	hashedPassword, err := passwords.Hash(username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var invite models.Invite
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", utils.HashSecret(plaintext)).First(&invite).Error; err != nil {
+			return ErrInvalidInvite
+		}
+		if invite.AcceptedAt != nil || invite.RevokedAt != nil || !invite.ExpiresAt.After(now) {
+			return ErrInvalidInvite
+		}
+
+		var taken int64
+		if err := tx.Unscoped().Model(&models.User{}).Where("username = ?", username).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrUsernameTaken
+		}
+		if invite.Email != "" {
+			if err := tx.Model(&models.User{}).Where("email = ?", invite.Email).Count(&taken).Error; err != nil {
+				return err
+			}
+			if taken > 0 {
+				return ErrEmailTaken
+			}
+		}
+
+		user = models.User{
+			Username:       username,
+			Password:       hashedPassword,
+			Role:           invite.Role,
+			Email:          invite.Email,
+			OrganizationID: &invite.OrganizationID,
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if err := passwords.Remember(tx, user.ID, hashedPassword, now); err != nil {
+			return err
+		}
+		return tx.Model(&invite).Updates(map[string]interface{}{"accepted_at": now, "accepted_by": user.ID}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
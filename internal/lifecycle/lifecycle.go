@@ -0,0 +1,125 @@
+package lifecycle
+
+import (
+	"errors"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/degraded"
+	"feature-flag-service/internal/models"
+)
+
+// usageResolution throttles evaluation bookkeeping writes to one per flag variation per interval
+const usageResolution = time.Minute
+
+// Stale flag reasons
+const (
+	ReasonNeverEvaluated  = "never_evaluated"
+	ReasonNotEvaluated    = "not_evaluated_recently"
+	ReasonSingleVariation = "single_variation"
+)
+
+// Candidate is a flag that looks safe to clean up
+type Candidate struct {
+	Flag      models.FeatureFlag `json:"flag"`
+	Reason    string             `json:"reason"`
+	Variation string             `json:"variation,omitempty"` // Variation served exclusively, for single_variation
+}
+
+// ValidState reports whether a lifecycle state is known
+func ValidState(state string) bool {
+	for _, s := range models.FlagStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// Usage is one evaluation to record
+type Usage struct {
+	Flag      *models.FeatureFlag
+	Variation string
+}
+
+// usageColumns are the usage timestamps, in the order RecordEvaluations writes them
+var usageColumns = []string{"last_evaluated_at", "last_control_at", "last_treatment_at"}
+
+// RecordEvaluation stores when a flag was last evaluated and which variation it served
+func RecordEvaluation(flag *models.FeatureFlag, variation string, now time.Time) error {
+	return RecordEvaluations([]Usage{{Flag: flag, Variation: variation}}, now)
+}
+
+// RecordEvaluations stores usage for a batch of evaluations with at most one update per
+// timestamp column, however many flags were evaluated
+func RecordEvaluations(usages []Usage, now time.Time) error {
+	// Usage timestamps are best effort, so evaluations served from the snapshot skip them
+	if degraded.Down(degraded.Database) {
+		return nil
+	}
+
+	ids := map[string][]uint{}
+	for _, u := range usages {
+		if stale(u.Flag.LastEvaluatedAt, now) {
+			ids["last_evaluated_at"] = append(ids["last_evaluated_at"], u.Flag.ID)
+		}
+		switch {
+		case u.Variation == models.VariationControl && stale(u.Flag.LastControlAt, now):
+			ids["last_control_at"] = append(ids["last_control_at"], u.Flag.ID)
+		case u.Variation == models.VariationTreatment && stale(u.Flag.LastTreatmentAt, now):
+			ids["last_treatment_at"] = append(ids["last_treatment_at"], u.Flag.ID)
+		}
+	}
+
+	var errs []error
+	for _, column := range usageColumns {
+		if len(ids[column]) == 0 {
+			continue
+		}
+		// UpdateColumn leaves updated_at alone so evaluations do not look like edits
+		err := config.DB.Model(&models.FeatureFlag{}).Where("id IN ?", ids[column]).UpdateColumn(column, now).Error
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// StaleCandidates lists flags older than days that have served a single variation
+// over that period, or have not been evaluated at all. Draft and archived flags are skipped.
+func StaleCandidates(flags []models.FeatureFlag, days int, now time.Time) []Candidate {
+	cutoff := now.AddDate(0, 0, -days)
+	candidates := []Candidate{}
+
+	for _, flag := range flags {
+		if flag.State == models.FlagStateDraft || flag.State == models.FlagStateArchived {
+			continue
+		}
+		if flag.CreatedAt.After(cutoff) {
+			continue
+		}
+
+		control := seenSince(flag.LastControlAt, cutoff)
+		treatment := seenSince(flag.LastTreatmentAt, cutoff)
+
+		switch {
+		case flag.LastEvaluatedAt == nil:
+			candidates = append(candidates, Candidate{Flag: flag, Reason: ReasonNeverEvaluated})
+		case !control && !treatment:
+			candidates = append(candidates, Candidate{Flag: flag, Reason: ReasonNotEvaluated})
+		case control && !treatment:
+			candidates = append(candidates, Candidate{Flag: flag, Reason: ReasonSingleVariation, Variation: models.VariationControl})
+		case treatment && !control:
+			candidates = append(candidates, Candidate{Flag: flag, Reason: ReasonSingleVariation, Variation: models.VariationTreatment})
+		}
+	}
+	return candidates
+}
+
+func stale(last *time.Time, now time.Time) bool {
+	return last == nil || now.Sub(*last) >= usageResolution
+}
+
+func seenSince(last *time.Time, cutoff time.Time) bool {
+	return last != nil && last.After(cutoff)
+}
@@ -0,0 +1,112 @@
+package lifecycle
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/dependencies"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/store"
+	"feature-flag-service/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotInTrash = errors.New("feature flag is not in the trash")
+	ErrNameTaken  = errors.New("another feature flag already uses this name")
+)
+
+// Restore undeletes a soft-deleted flag, as long as its name has not been reused in its
+// organization and its prerequisites still exist
+func Restore(id uint) (*models.FeatureFlag, error) {
+	var flag models.FeatureFlag
+	err := config.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&flag, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotInTrash
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	org := utils.OrganizationOf(flag.OrganizationID)
+	var count int64
+	if err := store.OrganizationQuery(config.DB.Model(&models.FeatureFlag{}), &org).Where("name = ?", flag.Name).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrNameTaken
+	}
+
+	// Prerequisites are referenced by name and may have been purged or renamed since
+	if len(flag.Prerequisites) > 0 {
+		var existing []models.FeatureFlag
+		if err := store.OrganizationQuery(config.DB, &org).Find(&existing).Error; err != nil {
+			return nil, err
+		}
+		if err := dependencies.Validate(&flag, existing); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := config.DB.Unscoped().Model(&flag).Update("deleted_at", nil).Error; err != nil {
+		return nil, err
+	}
+	flag.DeletedAt = gorm.DeletedAt{}
+	return &flag, nil
+}
+
+// Purge permanently deletes a soft-deleted flag and the records that belong to it
+func Purge(id uint) error {
+	var flag models.FeatureFlag
+	err := config.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&flag, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotInTrash
+	}
+	if err != nil {
+		return err
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		return purge(tx, flag.ID)
+	})
+}
+
+// PurgeExpired hard-deletes flags that have been in the trash longer than the retention period
+func PurgeExpired(retention time.Duration, now time.Time) error {
+	var ids []uint
+	if err := config.DB.Unscoped().Model(&models.FeatureFlag{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", now.Add(-retention)).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := config.DB.Transaction(func(tx *gorm.DB) error {
+			return purge(tx, id)
+		}); err != nil {
+			return err
+		}
+	}
+	if len(ids) > 0 {
+		log.Printf("🗑️ Purged %d feature flag(s) past the trash retention period", len(ids))
+	}
+	return nil
+}
+
+func purge(tx *gorm.DB, flagID uint) error {
+	owned := []interface{}{
+		&models.RolloutPlan{},
+		&models.Guardrail{},
+		&models.HealthSignal{},
+		&models.FlagHistory{},
+	}
+	for _, model := range owned {
+		if err := tx.Unscoped().Where("feature_flag_id = ?", flagID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Unscoped().Delete(&models.FeatureFlag{}, flagID).Error
+}
@@ -0,0 +1,147 @@
+package loginguard
+
+import (
+	"strings"
+	"time"
+
+	"feature-flag-service/internal/config"
+)
+
+// Limits applied to failed logins. Counters are kept per username and per client IP so that
+// both guessing one account's password and spraying many accounts from one address are slowed.
+const (
+	Window        = 15 * time.Minute // Failures older than this are forgotten
+	UserThreshold = 5                // Failures for one username before it is locked
+	IPThreshold   = 20               // Failures from one IP before it is locked
+	BaseLockout   = 30 * time.Second // First lockout; doubles with each further failure
+	MaxLockout    = time.Hour
+)
+
+// Limits on password reset requests, which each send an email. Requests are counted per login
+// and per client IP whether or not the account exists, so a refusal reveals nothing.
+const (
+	ResetWindow    = time.Hour
+	ResetUserLimit = 3  // Requests for one username or email per window
+	ResetIPLimit   = 10 // Requests from one IP per window
+)
+
+// Status describes whether login attempts are currently allowed
+type Status struct {
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+// Check reports whether the username or IP is locked out
+func Check(username, ip string) (Status, error) {
+	wait, err := config.Cache.TTL(lockKey("user", username))
+	if err != nil {
+		return Status{}, err
+	}
+	ipWait, err := config.Cache.TTL(lockKey("ip", ip))
+	if err != nil {
+		return Status{}, err
+	}
+	if ipWait > wait {
+		wait = ipWait
+	}
+	if wait <= 0 {
+		return Status{}, nil
+	}
+	return Status{Locked: true, RetryAfter: wait}, nil
+}
+
+// Fail counts a failed attempt and locks the username and/or IP once they pass their threshold.
+// It returns the resulting status.
+func Fail(username, ip string) (Status, error) {
+	userFailures, err := increment(failKey("user", username))
+	if err != nil {
+		return Status{}, err
+	}
+	ipFailures, err := increment(failKey("ip", ip))
+	if err != nil {
+		return Status{}, err
+	}
+
+	status := Status{}
+	if d := Backoff(userFailures, UserThreshold); d > 0 {
+		if err := config.Cache.Set(lockKey("user", username), []byte("1"), d); err != nil {
+			return Status{}, err
+		}
+		status = Status{Locked: true, RetryAfter: d}
+	}
+	if d := Backoff(ipFailures, IPThreshold); d > 0 {
+		if err := config.Cache.Set(lockKey("ip", ip), []byte("1"), d); err != nil {
+			return Status{}, err
+		}
+		if d > status.RetryAfter {
+			status = Status{Locked: true, RetryAfter: d}
+		}
+	}
+	return status, nil
+}
+
+// Succeed clears a username's failures after a successful login. The IP counter is kept so a
+// single valid account cannot be used to reset a password-spraying run.
+func Succeed(username string) error {
+	return Unlock(username)
+}
+
+// Unlock clears a username's failures and lockout
+func Unlock(username string) error {
+	return config.Cache.Del(failKey("user", username), lockKey("user", username))
+}
+
+// AllowReset counts a password reset request and reports whether it may go ahead
+func AllowReset(login, ip string) (Status, error) {
+	status := Status{}
+	for key, limit := range map[string]int64{resetKey("user", login): ResetUserLimit, resetKey("ip", ip): ResetIPLimit} {
+		requests, err := config.Cache.Incr(key, ResetWindow)
+		if err != nil {
+			return Status{}, err
+		}
+		if requests <= limit {
+			continue
+		}
+		wait, err := config.Cache.TTL(key)
+		if err != nil {
+			return Status{}, err
+		}
+		if wait > status.RetryAfter {
+			status = Status{Locked: true, RetryAfter: wait}
+		}
+	}
+	return status, nil
+}
+
+// Backoff is the lockout after a number of failures: none below the threshold, then
+// BaseLockout doubling with each further failure up to MaxLockout
+func Backoff(failures int64, threshold int64) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+	d := BaseLockout
+	for i := threshold; i < failures && d < MaxLockout; i++ {
+		d *= 2
+	}
+	if d > MaxLockout {
+		d = MaxLockout
+	}
+	return d
+}
+
+func increment(key string) (int64, error) {
+	return config.Cache.Incr(key, Window)
+}
+
+// Usernames are normalised so case variations share one counter
+func failKey(kind, value string) string {
+	return "login:fail:" + kind + ":" + strings.ToLower(value)
+}
+
+func lockKey(kind, value string) string {
+	return "login:lock:" + kind + ":" + strings.ToLower(value)
+}
+
+func resetKey(kind, value string) string {
+	return "reset:requests:" + kind + ":" + strings.ToLower(strings.TrimSpace(value))
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"sync"
+
+	"feature-flag-service/internal/models"
+)
+
+// Metrics recorded by the service
+var (
+	HTTPRequests = NewCounter("http_requests_total",
+		"HTTP requests by method, route pattern and status code.", "method", "route", "status")
+	HTTPDuration = NewHistogram("http_request_duration_seconds",
+		"HTTP request latency by method and route pattern.", DefaultBuckets, "method", "route")
+
+	Evaluations = NewCounter("flag_evaluations_total",
+		"Flag evaluations by flag, variation and reason. Flags beyond METRICS_MAX_FLAGS are counted under \""+OtherFlags+"\", and variations other than control and treatment under \""+OtherVariations+"\".", "flag", "variation", "reason")
+
+	CacheLookups = NewCounter("cache_lookups_total",
+		"Cache reads by result (hit or miss); the hit ratio is hit / (hit + miss).", "result")
+	RedisErrors = NewCounter("redis_errors_total",
+		"Failed Redis commands by operation.", "operation")
+
+	JobRuns = NewCounter("scheduler_job_runs_total",
+		"Scheduled job runs by job and outcome (success or failure).", "job", "outcome")
+	JobDuration = NewHistogram("scheduler_job_duration_seconds",
+		"Scheduled job run time by job.", DefaultBuckets, "job")
+)
+
+// Cache lookup results
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// Job outcomes
+const (
+	JobSuccess = "success"
+	JobFailure = "failure"
+)
+
+// OtherFlags is the flag label shared by every flag past the MaxFlags limit
+const OtherFlags = "__other__"
+
+// OtherVariations is the variation label shared by variations other than control and treatment
+const OtherVariations = "__other__"
+
+// DefaultMaxFlags is the number of distinct flags given their own series by default
+const DefaultMaxFlags = 100
+
+var (
+	flagsMu  sync.Mutex
+	maxFlags = DefaultMaxFlags
+	flags    = map[string]bool{}
+)
+
+// SetMaxFlags bounds the number of distinct flag label values (METRICS_MAX_FLAGS). Flags keep
+// their series once seen; later flags share OtherFlags, so cardinality stays fixed.
+func SetMaxFlags(n int) {
+	flagsMu.Lock()
+	maxFlags = n
+	flagsMu.Unlock()
+}
+
+// FlagLabel returns the label value to use for a flag name
+func FlagLabel(name string) string {
+	flagsMu.Lock()
+	defer flagsMu.Unlock()
+	if flags[name] {
+		return name
+	}
+	if len(flags) >= maxFlags {
+		return OtherFlags
+	}
+	flags[name] = true
+	return name
+}
+
+// VariationLabel returns the label value to use for a variation. Only the known variations get
+// their own series, so a bug or a new variation type cannot grow the label without bound.
+func VariationLabel(variation string) string {
+	switch variation {
+	case models.VariationControl, models.VariationTreatment:
+		return variation
+	}
+	return OtherVariations
+}
+
+// RecordEvaluation counts one evaluation result
+func RecordEvaluation(flag, variation, reason string) {
+	Evaluations.Inc(FlagLabel(flag), VariationLabel(variation), reason)
+}
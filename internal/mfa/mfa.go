@@ -0,0 +1,293 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// ChallengeTTL is how long a user has to enter their second factor after the password
+const ChallengeTTL = 5 * time.Minute
+
+// recoveryCodeCount is how many recovery codes are issued at a time
+const recoveryCodeCount = 10
+
+// challengeAudience keeps challenge tokens from being accepted as access tokens
+const challengeAudience = "feature-flag-service-mfa"
+
+var (
+	ErrInvalidCode     = errors.New("invalid verification code")
+	ErrNotEnrolled     = errors.New("two-factor authentication is not set up")
+	ErrAlreadyEnrolled = errors.New("two-factor authentication is already enabled")
+	ErrRequired        = errors.New("two-factor authentication is required for your role or organization")
+	ErrInvalidToken    = errors.New("invalid or expired MFA challenge")
+)
+
+// ChallengeClaims identify a user who has passed the password step but not the second factor
+type ChallengeClaims struct {
+	Username string `json:"username"`
+	Enroll   bool   `json:"enroll,omitempty"` // The user must set up MFA before logging in
+	jwt.RegisteredClaims
+}
+
+// Required reports whether the user's organization, or any role the user holds globally or
+// through a binding, requires MFA. Single sign-on users are exempt because their identity
+// provider handles the second factor.
+func Required(user *models.User) (bool, error) {
+	if user.ExternalID != nil {
+		return false, nil
+	}
+	roles := config.DB.Model(&models.RoleBinding{}).Select("role").Where("user_id = ?", user.ID)
+
+	var count int64
+	err := config.DB.Model(&models.MFARequirement{}).
+		Where("role = ? OR role IN (?)", user.Role, roles).
+		Count(&count).Error
+	if err != nil || count > 0 || user.OrganizationID == nil {
+		return count > 0, err
+	}
+
+	err = config.DB.Model(&models.Organization{}).
+		Where("id = ? AND require_mfa = ?", *user.OrganizationID, true).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// IssueChallenge signs a short-lived token for the second login step
+func IssueChallenge(user *models.User, enroll bool) (string, error) {
+	keys, err := utils.Keys()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	return keys.Sign(&ChallengeClaims{
+		Username: user.Username,
+		Enroll:   enroll,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.Username,
+			Audience:  jwt.ClaimStrings{challengeAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ChallengeTTL)),
+		},
+	})
+}
+
+// ParseChallenge validates a challenge token
+func ParseChallenge(token string) (*ChallengeClaims, error) {
+	keys, err := utils.Keys()
+	if err != nil {
+		return nil, err
+	}
+	claims := &ChallengeClaims{}
+	parsed, err := keys.Parse(token, claims, jwt.WithAudience(challengeAudience), jwt.WithExpirationRequired())
+	if err != nil || !parsed.Valid {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// BeginEnrollment stores a new TOTP secret for the user, encrypted, and returns it with its
+// otpauth URI. MFA is not enforced until the user proves the secret works with Activate.
+func (a *Authenticator) BeginEnrollment(user *models.User) (string, string, error) {
+	if user.MFAEnabled {
+		return "", "", ErrAlreadyEnrolled
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	sealed, err := a.seal(user.ID, secret)
+	if err != nil {
+		return "", "", err
+	}
+	if err := config.DB.Model(user).UpdateColumns(map[string]interface{}{"totp_secret": sealed, "totp_last_step": 0}).Error; err != nil {
+		return "", "", err
+	}
+	user.TOTPSecret, user.TOTPLastStep = sealed, 0
+	return secret, utils.TOTPURI(a.issuer, user.Username, secret), nil
+}
+
+// Activate enables MFA once the user enters a valid code for the pending secret, and returns
+// the user's recovery codes
+func (a *Authenticator) Activate(user *models.User, code string, now time.Time) ([]string, error) {
+	if user.MFAEnabled {
+		return nil, ErrAlreadyEnrolled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrNotEnrolled
+	}
+	secret, _, err := a.open(user.ID, user.TOTPSecret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := utils.ValidateTOTP(secret, code, now)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	sealed, err := a.seal(user.ID, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).UpdateColumns(map[string]interface{}{
+			"mfa_enabled": true, "totp_secret": sealed, "totp_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.MFAEnabled, user.TOTPSecret, user.TOTPLastStep = true, sealed, step
+	return codes, nil
+}
+
+// Verify checks a TOTP code, or failing that a single-use recovery code. A secret that cannot
+// be decrypted, for example after MFA_ENCRYPTION_KEY changed, leaves only the recovery codes.
+func (a *Authenticator) Verify(user *models.User, code string, now time.Time) error {
+	if !user.MFAEnabled {
+		return ErrNotEnrolled
+	}
+
+	secret, sealed, err := a.open(user.ID, user.TOTPSecret)
+	if err != nil {
+		log.Printf("⚠️ TOTP secret of user %d: %v", user.ID, err)
+		return useRecoveryCode(user.ID, code, now)
+	}
+	if step, ok := utils.ValidateTOTP(secret, code, now); ok {
+		// Only accept each time step once; the conditional update also guards concurrent logins
+		result := config.DB.Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			UpdateColumn("totp_last_step", step)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidCode
+		}
+		user.TOTPLastStep = step
+		if !sealed {
+			a.resealLegacySecret(user, secret)
+		}
+		return nil
+	}
+
+	return useRecoveryCode(user.ID, code, now)
+}
+
+// RegenerateRecoveryCodes replaces all of a user's recovery codes
+func RegenerateRecoveryCodes(user *models.User) ([]string, error) {
+	if !user.MFAEnabled {
+		return nil, ErrNotEnrolled
+	}
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+// Disable removes a user's second factor and recovery codes
+func Disable(user *models.User) error {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(user).UpdateColumns(map[string]interface{}{
+			"mfa_enabled": false, "totp_secret": "", "totp_last_step": 0,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	user.MFAEnabled, user.TOTPSecret, user.TOTPLastStep = false, "", 0
+	return nil
+}
+
+// resealLegacySecret encrypts a secret stored before encryption at rest was introduced
+func (a *Authenticator) resealLegacySecret(user *models.User, secret string) {
+	sealed, err := a.seal(user.ID, secret)
+	if err == nil {
+		err = config.DB.Model(&models.User{}).Where("id = ? AND totp_secret = ?", user.ID, secret).
+			UpdateColumn("totp_secret", sealed).Error
+	}
+	if err != nil {
+		log.Printf("⚠️ Failed to encrypt the TOTP secret of user %d: %v", user.ID, err)
+		return
+	}
+	user.TOTPSecret = sealed
+}
+
+func useRecoveryCode(userID uint, code string, now time.Time) error {
+	var codes []models.RecoveryCode
+	if err := config.DB.Where("user_id = ? AND used_at IS NULL", userID).Find(&codes).Error; err != nil {
+		return err
+	}
+
+	hash := hashRecoveryCode(code)
+	for _, rc := range codes {
+		if subtle.ConstantTimeCompare([]byte(rc.CodeHash), []byte(hash)) != 1 {
+			continue
+		}
+		result := config.DB.Model(&models.RecoveryCode{}).
+			Where("id = ? AND used_at IS NULL", rc.ID).
+			UpdateColumn("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			return nil
+		}
+	}
+	return ErrInvalidCode
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.RecoveryCode, recoveryCodeCount)
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(b))
+		codes[i] = raw[:8] + "-" + raw[8:]
+		records[i] = models.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(codes[i])}
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode normalises case and dashes so codes can be typed loosely
+func hashRecoveryCode(code string) string {
+	normalised := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalised))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,103 @@
+package mfa
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// sealedPrefix marks a TOTP secret encrypted with the MFA key. Secrets stored before
+// encryption was introduced have no prefix and are re-sealed the next time they are used.
+const sealedPrefix = "enc:v1:"
+
+var ErrSecretUnreadable = errors.New("stored TOTP secret cannot be decrypted with the MFA key")
+
+// Authenticator enrolls and verifies TOTP second factors, naming the service in authenticator
+// apps and encrypting the stored secrets with its key
+type Authenticator struct {
+	issuer string
+	aead   cipher.AEAD
+}
+
+// NewAuthenticator creates an Authenticator. issuer names the service in authenticator apps
+// (MFA_ISSUER); encodedKey encrypts TOTP secrets: 32 bytes, base64-encoded (MFA_ENCRYPTION_KEY).
+// Without a key an ephemeral one is used, so enrollments do not survive a restart.
+func NewAuthenticator(issuer, encodedKey string) (*Authenticator, error) {
+	var key []byte
+	if encodedKey == "" {
+		log.Println("⚠️ MFA_ENCRYPTION_KEY is not set; encrypting TOTP secrets with an ephemeral key, so enrollments will not survive a restart")
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if key, err = ParseKey(encodedKey); err != nil {
+			return nil, err
+		}
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Authenticator{issuer: issuer, aead: aead}, nil
+}
+
+// ParseKey decodes a base64 MFA encryption key and checks its length
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("MFA encryption key is not base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("MFA encryption key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts a TOTP secret for storage. The user ID is authenticated with it so a
+// secret copied to another user's row does not decrypt.
+func (a *Authenticator) seal(userID uint, plaintext string) (string, error) {
+	nonce := make([]byte, a.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := a.aead.Seal(nonce, nonce, []byte(plaintext), additionalData(userID))
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts a stored TOTP secret; legacy plaintext secrets are returned as they are
+func (a *Authenticator) open(userID uint, stored string) (string, bool, error) {
+	encoded, ok := strings.CutPrefix(stored, sealedPrefix)
+	if !ok {
+		return stored, false, nil
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < a.aead.NonceSize() {
+		return "", true, ErrSecretUnreadable
+	}
+	plaintext, err := a.aead.Open(nil, sealed[:a.aead.NonceSize()], sealed[a.aead.NonceSize():], additionalData(userID))
+	if err != nil {
+		return "", true, ErrSecretUnreadable
+	}
+	return string(plaintext), true, nil
+}
+
+func additionalData(userID uint) []byte {
+	return []byte("totp:" + strconv.FormatUint(uint64(userID), 10))
+}
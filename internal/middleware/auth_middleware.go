@@ -3,14 +3,20 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
+	"feature-flag-service/internal/sessions"
+	"feature-flag-service/internal/tokens"
 	"feature-flag-service/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware protects routes with JWT
-func AuthMiddleware() gin.HandlerFunc {
+// ClaimsKey is the gin context key holding the authenticated *utils.Claims
+const ClaimsKey = "claims"
+
+// AuthMiddleware protects routes with a JWT signed by issuer or an access token
+func AuthMiddleware(issuer *utils.TokenIssuer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -19,14 +25,54 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		tokenString := strings.Split(authHeader, "Bearer ")[1]
-		_, err := utils.ValidateJWT(tokenString)
+		tokenString, found := strings.CutPrefix(authHeader, "Bearer ")
+		if !found {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+
+		var claims *utils.Claims
+		var err error
+		if tokens.IsAccessToken(tokenString) {
+			claims, err = tokens.Authenticate(tokenString, time.Now())
+		} else {
+			claims, err = issuer.ValidateJWT(tokenString)
+		}
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
+		revoked, err := sessions.IsRevoked(claims)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify token"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
+		c.Set(ClaimsKey, claims)
+		c.Next()
+	}
+}
+
+// RequireSession rejects callers authenticated with an access token. It guards the caller's own
+// credentials and sessions, which no action scope covers, so a token cannot mint further tokens,
+// end sessions or change second factors. Must run after AuthMiddleware.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := CurrentClaims(c)
+		if !ok || claims.TokenID != 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access tokens cannot be used for this request; sign in instead"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"feature-flag-service/internal/degraded"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetryAfterSeconds is the Retry-After hint sent with writes refused in degraded mode
+const RetryAfterSeconds = 30
+
+// RejectWritesWhenDegraded refuses requests that change state while the database is down, so
+// they get a clear 503 instead of failing midway. Reads, and evaluations served from the flag
+// snapshot, continue as normal; a Redis outage alone does not stop writes.
+func RejectWritesWhenDegraded() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if !degraded.Down(degraded.Database) {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", strconv.Itoa(RetryAfterSeconds))
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Service is in degraded mode (" + degraded.Summary() + "); changes cannot be saved right now. Try again shortly.",
+		})
+		c.Abort()
+	}
+}
@@ -0,0 +1,353 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/degraded"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/policy"
+	"feature-flag-service/internal/snapshot"
+	"feature-flag-service/internal/store"
+	"feature-flag-service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// resourceKey is the gin context key holding the policy.Resource of the request
+const resourceKey = "policy_resource"
+
+// Policies returns an engine for the given grants, loading all of their custom roles in one
+// query. Role names are only unique within an organization, so they are resolved among the roles
+// of the grantee's organization, 0 for none. Roles that fail to load grant nothing.
+func Policies(grants []policy.Grant, organization uint) *policy.Engine {
+	var names []string
+	for _, grant := range grants {
+		if !policy.IsBuiltin(grant.Role) {
+			names = append(names, grant.Role)
+		}
+	}
+
+	roles := map[string][]models.PolicyStatement{}
+	if len(names) > 0 {
+		var rows []models.CustomRole
+		if err := store.OrganizationQuery(config.DB, &organization).Where("name IN ?", names).Find(&rows).Error; err == nil {
+			for _, row := range rows {
+				roles[row.Name] = row.Statements
+			}
+		}
+	}
+	return &policy.Engine{Resolve: func(name string) ([]models.PolicyStatement, bool) {
+		stmts, ok := roles[name]
+		return stmts, ok
+	}}
+}
+
+// Authorize allows the request only if the caller's roles permit the action on the requested
+// resource. The project and environment are read from the route parameters or query string, or
+// from the X-Project and X-Environment headers; flag and SDK key routes take them from the stored
+// record identified by :id instead, and reject a request that names another one. A record of
+// another organization is reported as not found, and one that cannot be loaded answers 503.
+// Must run after AuthMiddleware.
+func Authorize(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := CurrentClaims(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+
+		resource, err := RequestResource(c, action)
+		if errors.Is(err, ErrScopeMismatch) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to load the requested resource"})
+			c.Abort()
+			return
+		}
+		c.Set(resourceKey, resource)
+
+		if !Visible(claims, resource) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			c.Abort()
+			return
+		}
+
+		decision := Decide(claims, action, resource)
+		if !decision.Allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden", "action": action, "reason": decision.Reason})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// AuthorizeAny allows the request if the caller may perform the action on at least one resource.
+// It guards listings, which must then filter each item with Decide. Must run after AuthMiddleware.
+func AuthorizeAny(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := CurrentClaims(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+
+		grants := claims.Grants()
+		decision := policy.Restrict(Policies(grants, claims.Organization).AuthorizeAny(grants, action), claims.Actions)
+		if !decision.Allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden", "action": action, "reason": decision.Reason})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Allowed checks an additional action against the request's resource, responding with 403 if denied
+func Allowed(c *gin.Context, action string) bool {
+	resource, _ := c.Get(resourceKey)
+	r, _ := resource.(policy.Resource)
+	return AllowedOn(c, action, r)
+}
+
+// AllowedOn checks an action against an explicit resource, responding with 403 if denied
+func AllowedOn(c *gin.Context, action string, resource policy.Resource) bool {
+	claims, ok := CurrentClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return false
+	}
+
+	decision := Decide(claims, action, resource)
+	if !decision.Allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden", "action": action, "reason": decision.Reason})
+		return false
+	}
+	return true
+}
+
+// Decide authorizes an action for the caller, honouring the scopes of access tokens
+func Decide(claims *utils.Claims, action string, resource policy.Resource) policy.Decision {
+	return Decider(claims)(action, resource)
+}
+
+// Decider returns Decide for one caller with their roles loaded once, for checking many resources
+func Decider(claims *utils.Claims) func(action string, resource policy.Resource) policy.Decision {
+	grants := claims.Grants()
+	engine := Policies(grants, claims.Organization)
+	return func(action string, resource policy.Resource) policy.Decision {
+		if !Visible(claims, resource) {
+			return policy.Decision{Action: action, Allowed: false, Reason: "the resource belongs to another organization"}
+		}
+		return policy.Restrict(engine.Authorize(grants, action, resource), claims.Actions)
+	}
+}
+
+// Visible reports whether the caller may see a resource: records belong to one organization
+func Visible(claims *utils.Claims, resource policy.Resource) bool {
+	return resource.Organization == nil || claims.InOrganization(*resource.Organization)
+}
+
+// OrganizationFilter limits listings to the caller's organization; nil lets owners see every record
+func OrganizationFilter(c *gin.Context) *uint {
+	claims, ok := CurrentClaims(c)
+	if !ok {
+		none := uint(0)
+		return &none
+	}
+	return claims.OrganizationFilter()
+}
+
+// CallerOrganization is the organization records created by the caller belong to, nil for none
+func CallerOrganization(c *gin.Context) *uint {
+	claims, ok := CurrentClaims(c)
+	if !ok {
+		return nil
+	}
+	return claims.OrganizationID()
+}
+
+// NameOrganization is the organization names in the caller's requests are resolved in, 0 for
+// none. Flag and role names are only unique within an organization, so even owners, who see
+// every organization, look names up in their own.
+func NameOrganization(c *gin.Context) *uint {
+	var org uint
+	if claims, ok := CurrentClaims(c); ok {
+		org = claims.Organization
+	}
+	return &org
+}
+
+// CurrentClaims returns the claims stored by AuthMiddleware
+func CurrentClaims(c *gin.Context) (*utils.Claims, bool) {
+	value, exists := c.Get(ClaimsKey)
+	if !exists {
+		return nil, false
+	}
+	claims, ok := value.(*utils.Claims)
+	return claims, ok
+}
+
+var (
+	// ErrScopeMismatch means the request names a project or environment other than the resource's own
+	ErrScopeMismatch = errors.New("project or environment does not match the resource")
+	// ErrResourceUnavailable means the database is down and the snapshot has no copy of the flag
+	ErrResourceUnavailable = errors.New("the resource cannot be loaded while the database is unavailable")
+)
+
+// RequestResource describes what a request acts on. The project and environment come from the
+// request, except on routes that identify a stored flag or SDK key by :id, where they come from
+// the stored record; naming a different project or environment then is an ErrScopeMismatch.
+// Routes identifying a user, invite, organization, role or service account by :id carry the
+// organization of that record. A record that cannot be loaded is an error rather than being
+// authorized against the scope the caller asked for; only a missing one is left to the handler.
+// While the database is down, flags come from the snapshot, as for evaluations.
+func RequestResource(c *gin.Context, action string) (policy.Resource, error) {
+	resource := policy.Resource{
+		Project:     requestScope(c, "project", "X-Project"),
+		Environment: requestScope(c, "environment", "X-Environment"),
+	}
+
+	id := c.Param("id")
+	if id == "" {
+		return resource, nil
+	}
+
+	// Flag routes (including the trash) identify the flag by :id
+	switch {
+	case strings.HasPrefix(action, "flag:") || strings.HasPrefix(action, "signal:"):
+		flag, err := storedFlag(id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return resource, nil
+		}
+		if err != nil {
+			return policy.Resource{}, err
+		}
+		return FlagResource(resource, flag)
+	case strings.HasPrefix(action, "sdkkey:"):
+		var key models.SDKKey
+		err := config.DB.Select("environment", "organization_id").First(&key, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return resource, nil
+		}
+		if err != nil {
+			return policy.Resource{}, err
+		}
+		return Scoped(resource, policy.Resource{Environment: key.Environment, Organization: organization(key.OrganizationID)})
+	}
+
+	org, ok, err := recordOrganization(c.FullPath(), id)
+	if err != nil {
+		return policy.Resource{}, err
+	}
+	if ok {
+		resource.Organization = org
+	}
+	return resource, nil
+}
+
+// storedFlag loads the flag a route identifies by :id, including flags in the trash, falling
+// back to the snapshot's copy while the database is down
+func storedFlag(id string) (*models.FeatureFlag, error) {
+	var flag models.FeatureFlag
+	err := config.DB.Unscoped().Select("name", "tags", "project", "environment", "organization_id").First(&flag, id).Error
+	if err == nil || errors.Is(err, gorm.ErrRecordNotFound) || !degraded.Down(degraded.Database) {
+		return &flag, err
+	}
+
+	var flagID uint
+	if _, scanErr := fmt.Sscan(id, &flagID); scanErr == nil {
+		if snap := snapshot.Current(); snap != nil {
+			if stored := snap.Find(flagID); stored != nil {
+				return stored, nil
+			}
+		}
+	}
+	return nil, ErrResourceUnavailable
+}
+
+// organizationTables maps the collection named before :id in a route to the table of its records
+var organizationTables = map[string]string{
+	"users":            "users",
+	"invites":          "invites",
+	"roles":            "custom_roles",
+	"service-accounts": "service_accounts",
+}
+
+// recordOrganization loads the organization of the record a route identifies by :id; ok is
+// false when the route has none or the record does not exist
+func recordOrganization(route, id string) (*uint, bool, error) {
+	segments := strings.Split(route, "/")
+	for i := 1; i < len(segments); i++ {
+		if segments[i] != ":id" {
+			continue
+		}
+		collection := segments[i-1]
+		if collection == "organizations" {
+			var org uint
+			if _, err := fmt.Sscan(id, &org); err != nil {
+				return nil, false, nil
+			}
+			return &org, true, nil
+		}
+		table, ok := organizationTables[collection]
+		if !ok {
+			return nil, false, nil
+		}
+		var rows []struct{ OrganizationID *uint }
+		if err := config.DB.Table(table).Select("organization_id").Where("id = ?", id).Limit(1).Find(&rows).Error; err != nil {
+			return nil, false, err
+		}
+		if len(rows) == 0 {
+			return nil, false, nil
+		}
+		return organization(rows[0].OrganizationID), true, nil
+	}
+	return nil, false, nil
+}
+
+// organization converts a record's organization ID to a resource organization, 0 for none
+func organization(id *uint) *uint {
+	org := utils.OrganizationOf(id)
+	return &org
+}
+
+// FlagResource describes a flag as a policy resource in its own project and environment. The
+// requested resource may leave either unset, but must not name a different one.
+func FlagResource(requested policy.Resource, flag *models.FeatureFlag) (policy.Resource, error) {
+	return Scoped(requested, policy.Resource{
+		Project: flag.Project, Environment: flag.Environment, Flag: flag.Name, Tags: flag.Tags,
+		Organization: organization(flag.OrganizationID),
+	})
+}
+
+// Scoped returns the stored resource, or ErrScopeMismatch if the request names another project or environment
+func Scoped(requested, stored policy.Resource) (policy.Resource, error) {
+	if (requested.Project != "" && requested.Project != stored.Project) ||
+		(requested.Environment != "" && requested.Environment != stored.Environment) {
+		return policy.Resource{}, ErrScopeMismatch
+	}
+	return stored, nil
+}
+
+func requestScope(c *gin.Context, name, header string) string {
+	if v := c.Param(name); v != "" {
+		return v
+	}
+	if v := c.Query(name); v != "" {
+		return v
+	}
+	return c.GetHeader(header)
+}
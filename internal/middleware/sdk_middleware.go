@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/sdkkeys"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SDKKeyContextKey is the gin context key holding the authenticated *models.SDKKey
+const SDKKeyContextKey = "sdk_key"
+
+// SDKKeyMiddleware authenticates SDKs by environment key instead of user JWTs. The key is
+// read from the Authorization header, with or without a "Bearer " prefix. Server keys are
+// accepted everywhere; client keys only where allowClient is true.
+func SDKKeyMiddleware(allowClient bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		plaintext := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if plaintext == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "SDK key required"})
+			c.Abort()
+			return
+		}
+
+		key, err := sdkkeys.Authenticate(plaintext, time.Now())
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		if key.Kind == models.SDKKeyClient && !allowClient {
+			c.JSON(http.StatusForbidden, gin.H{"error": "A server-side SDK key is required"})
+			c.Abort()
+			return
+		}
+
+		c.Set(SDKKeyContextKey, key)
+		c.Next()
+	}
+}
+
+// CurrentSDKKey returns the key stored by SDKKeyMiddleware
+func CurrentSDKKey(c *gin.Context) (*models.SDKKey, bool) {
+	value, exists := c.Get(SDKKeyContextKey)
+	if !exists {
+		return nil, false
+	}
+	key, ok := value.(*models.SDKKey)
+	return key, ok
+}
@@ -0,0 +1,301 @@
+package migrations
+
+import (
+	"time"
+
+	"feature-flag-service/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// The baseline is the schema as AutoMigrate left it before versioned migrations. The tables are
+// frozen copies of the models at that point, so later model changes need their own migration
+// instead of silently changing what version 1 creates. On databases that AutoMigrate already
+// set up, applying it is a no-op apart from recording the version.
+
+type baselineFeatureFlag struct {
+	ID                uint   `gorm:"primaryKey"`
+	Name              string `gorm:"uniqueIndex:idx_feature_flags_name,where:deleted_at IS NULL;not null"`
+	Description       string
+	IsEnabled         bool
+	State             string   `gorm:"index;not null;default:active"`
+	Tags              []string `gorm:"serializer:json"`
+	ClientVisible     bool
+	RolloutPercentage *int
+	ActiveFrom        *time.Time
+	ActiveUntil       *time.Time
+	Schedule          string
+	TimeZone          string
+	Prerequisites     []models.Prerequisite `gorm:"serializer:json"`
+	LastEvaluatedAt   *time.Time
+	LastControlAt     *time.Time
+	LastTreatmentAt   *time.Time
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	DeletedAt         gorm.DeletedAt `gorm:"index"`
+}
+
+func (baselineFeatureFlag) TableName() string { return "feature_flags" }
+
+type baselineUser struct {
+	ID             uint   `gorm:"primaryKey"`
+	Username       string `gorm:"unique;not null"`
+	Password       string `gorm:"not null"`
+	Role           string `gorm:"not null;default:viewer"`
+	Email          string `gorm:"uniqueIndex:idx_users_email,where:email <> ''"`
+	DisplayName    string
+	Disabled       bool `gorm:"index"`
+	LastLoginAt    *time.Time
+	OrganizationID *uint   `gorm:"index"`
+	ExternalID     *string `gorm:"uniqueIndex"`
+	MFAEnabled     bool
+	TOTPSecret     string
+	TOTPLastStep   int64
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineRoleBinding struct {
+	ID          uint `gorm:"primaryKey"`
+	UserID      uint `gorm:"index;not null"`
+	Project     string
+	Environment string
+	Role        string `gorm:"not null"`
+	CreatedAt   time.Time
+}
+
+func (baselineRoleBinding) TableName() string { return "role_bindings" }
+
+type baselineCustomRole struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"uniqueIndex;not null"`
+	Description string
+	Statements  []models.PolicyStatement `gorm:"serializer:json;not null"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+func (baselineCustomRole) TableName() string { return "custom_roles" }
+
+type baselineRolloutPlan struct {
+	ID              uint                 `gorm:"primaryKey"`
+	FeatureFlagID   uint                 `gorm:"index;not null"`
+	Steps           []models.RolloutStep `gorm:"serializer:json;not null"`
+	CurrentStep     int
+	Status          string `gorm:"index;not null"`
+	StepStartedAt   time.Time
+	PausedAt        *time.Time
+	StartIsEnabled  bool
+	StartPercentage *int
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	DeletedAt       gorm.DeletedAt `gorm:"index"`
+}
+
+func (baselineRolloutPlan) TableName() string { return "rollout_plans" }
+
+type baselineGuardrail struct {
+	ID            uint   `gorm:"primaryKey"`
+	FeatureFlagID uint   `gorm:"index;not null"`
+	Metric        string `gorm:"not null"`
+	Aggregation   string `gorm:"not null"`
+	Threshold     float64
+	Window        string `gorm:"not null"`
+	MinSamples    int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
+}
+
+func (baselineGuardrail) TableName() string { return "guardrails" }
+
+type baselineHealthSignal struct {
+	ID            uint   `gorm:"primaryKey"`
+	FeatureFlagID uint   `gorm:"index:idx_health_signals_lookup;not null"`
+	Variation     string `gorm:"not null"`
+	Metric        string `gorm:"not null"`
+	Value         float64
+	ObservedAt    time.Time `gorm:"index:idx_health_signals_lookup"`
+	CreatedAt     time.Time
+}
+
+func (baselineHealthSignal) TableName() string { return "health_signals" }
+
+type baselineFlagHistory struct {
+	ID            uint   `gorm:"primaryKey"`
+	FeatureFlagID uint   `gorm:"index;not null"`
+	Action        string `gorm:"not null"`
+	Reason        string
+	Actor         string
+	CreatedAt     time.Time
+}
+
+func (baselineFlagHistory) TableName() string { return "flag_histories" }
+
+type baselineSDKKey struct {
+	ID          uint   `gorm:"primaryKey"`
+	Environment string `gorm:"index;not null"`
+	Kind        string `gorm:"not null"`
+	Prefix      string `gorm:"not null"`
+	KeyHash     string `gorm:"uniqueIndex;not null"`
+	ExpiresAt   *time.Time
+	CreatedAt   time.Time
+}
+
+func (baselineSDKKey) TableName() string { return "sdk_keys" }
+
+type baselineServiceAccount struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"uniqueIndex:idx_service_accounts_name,where:deleted_at IS NULL;not null"`
+	Description string
+	Role        string `gorm:"not null"`
+	Project     string
+	Environment string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+func (baselineServiceAccount) TableName() string { return "service_accounts" }
+
+type baselineAccessToken struct {
+	ID               uint     `gorm:"primaryKey"`
+	Name             string   `gorm:"not null"`
+	Prefix           string   `gorm:"not null"`
+	TokenHash        string   `gorm:"uniqueIndex;not null"`
+	UserID           *uint    `gorm:"index"`
+	ServiceAccountID *uint    `gorm:"index"`
+	Scopes           []string `gorm:"serializer:json"`
+	ExpiresAt        *time.Time
+	LastUsedAt       *time.Time
+	RevokedAt        *time.Time
+	CreatedAt        time.Time
+}
+
+func (baselineAccessToken) TableName() string { return "access_tokens" }
+
+type baselineSession struct {
+	ID         uint `gorm:"primaryKey"`
+	UserID     uint `gorm:"index;not null"`
+	UserAgent  string
+	IPAddress  string
+	ExpiresAt  time.Time `gorm:"index;not null"`
+	LastUsedAt time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+func (baselineSession) TableName() string { return "sessions" }
+
+type baselineRefreshToken struct {
+	ID        uint   `gorm:"primaryKey"`
+	SessionID uint   `gorm:"index;not null"`
+	TokenHash string `gorm:"uniqueIndex;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (baselineRefreshToken) TableName() string { return "refresh_tokens" }
+
+type baselineRecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index;not null"`
+	CodeHash  string `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (baselineRecoveryCode) TableName() string { return "recovery_codes" }
+
+type baselineMFARequirement struct {
+	ID        uint   `gorm:"primaryKey"`
+	Role      string `gorm:"uniqueIndex;not null"`
+	CreatedAt time.Time
+}
+
+func (baselineMFARequirement) TableName() string { return "mfa_requirements" }
+
+type baselineAuditEntry struct {
+	ID        uint   `gorm:"primaryKey"`
+	Action    string `gorm:"index;not null"`
+	Actor     string
+	Target    string `gorm:"index"`
+	IPAddress string
+	Detail    string
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (baselineAuditEntry) TableName() string { return "audit_entries" }
+
+type baselineOrganization struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"uniqueIndex;not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (baselineOrganization) TableName() string { return "organizations" }
+
+type baselineInvite struct {
+	ID             uint   `gorm:"primaryKey"`
+	OrganizationID uint   `gorm:"index;not null"`
+	Role           string `gorm:"not null"`
+	Email          string
+	TokenHash      string `gorm:"uniqueIndex;not null"`
+	CreatedBy      string
+	ExpiresAt      time.Time `gorm:"not null"`
+	AcceptedAt     *time.Time
+	AcceptedBy     *uint
+	RevokedAt      *time.Time
+	CreatedAt      time.Time
+}
+
+func (baselineInvite) TableName() string { return "invites" }
+
+type baselinePasswordHistory struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"index;not null"`
+	PasswordHash string `gorm:"not null"`
+	CreatedAt    time.Time
+}
+
+func (baselinePasswordHistory) TableName() string { return "password_histories" }
+
+type baselinePasswordResetToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"index;not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (baselinePasswordResetToken) TableName() string { return "password_reset_tokens" }
+
+// baselineTables in creation order; they are dropped in reverse
+var baselineTables = []interface{}{
+	&baselineFeatureFlag{}, &baselineUser{}, &baselineRoleBinding{}, &baselineCustomRole{}, &baselineRolloutPlan{},
+	&baselineGuardrail{}, &baselineHealthSignal{}, &baselineFlagHistory{}, &baselineSDKKey{},
+	&baselineServiceAccount{}, &baselineAccessToken{}, &baselineSession{}, &baselineRefreshToken{},
+	&baselineRecoveryCode{}, &baselineMFARequirement{}, &baselineAuditEntry{}, &baselineOrganization{}, &baselineInvite{},
+	&baselinePasswordHistory{}, &baselinePasswordResetToken{},
+}
+
+var baseline = Migration{
+	Version: 1,
+	Name:    "baseline",
+	Up: func(tx *gorm.DB) error {
+		// Flag names are unique only among non-deleted flags; drop the old table-wide constraint
+		if tx.Migrator().HasConstraint(&baselineFeatureFlag{}, "feature_flags_name_key") {
+			if err := tx.Migrator().DropConstraint(&baselineFeatureFlag{}, "feature_flags_name_key"); err != nil {
+				return err
+			}
+		}
+		return tx.AutoMigrate(baselineTables...)
+	},
+	// No Down step: reverting the baseline would drop every table and its data
+}
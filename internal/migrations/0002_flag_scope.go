@@ -0,0 +1,40 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// flagScopeFeatureFlag adds the project and environment a flag belongs to, so project- and
+// environment-bound grants are checked against the flag itself rather than request headers.
+// Existing flags stay unscoped.
+type flagScopeFeatureFlag struct {
+	Project     string `gorm:"index;not null;default:''"`
+	Environment string `gorm:"index;not null;default:''"`
+}
+
+func (flagScopeFeatureFlag) TableName() string { return "feature_flags" }
+
+var flagScope = Migration{
+	Version: 2,
+	Name:    "flag_scope",
+	Up: func(tx *gorm.DB) error {
+		for _, field := range []string{"Project", "Environment"} {
+			if tx.Migrator().HasColumn(&flagScopeFeatureFlag{}, field) {
+				continue
+			}
+			if err := tx.Migrator().AddColumn(&flagScopeFeatureFlag{}, field); err != nil {
+				return err
+			}
+			if err := tx.Migrator().CreateIndex(&flagScopeFeatureFlag{}, field); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		for _, field := range []string{"Project", "Environment"} {
+			if err := tx.Migrator().DropIndex(&flagScopeFeatureFlag{}, field); err != nil {
+				return err
+			}
+		}
+		return dropColumns(tx, &flagScopeFeatureFlag{}, "Project", "Environment")
+	},
+}
@@ -0,0 +1,25 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// organizationMFAOrganization lets an organization require two-factor authentication of all
+// its members
+type organizationMFAOrganization struct {
+	RequireMFA bool `gorm:"not null;default:false"`
+}
+
+func (organizationMFAOrganization) TableName() string { return "organizations" }
+
+var organizationMFA = Migration{
+	Version: 3,
+	Name:    "organization_mfa",
+	Up: func(tx *gorm.DB) error {
+		if tx.Migrator().HasColumn(&organizationMFAOrganization{}, "RequireMFA") {
+			return nil
+		}
+		return tx.Migrator().AddColumn(&organizationMFAOrganization{}, "RequireMFA")
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropColumns(tx, &organizationMFAOrganization{}, "RequireMFA")
+	},
+}
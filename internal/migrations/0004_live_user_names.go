@@ -0,0 +1,65 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// liveUserNamesUser makes usernames and emails unique only among users that are not deleted,
+// so a deleted user's name can be reused. Every index on users is listed because SQLite
+// rebuilds the table, losing its indexes, to drop the old table-wide constraint.
+type liveUserNamesUser struct {
+	Username       string         `gorm:"uniqueIndex:idx_users_username,where:deleted_at IS NULL;not null"`
+	Email          string         `gorm:"uniqueIndex:idx_users_email,where:email <> '' AND deleted_at IS NULL"`
+	Disabled       bool           `gorm:"index"`
+	OrganizationID *uint          `gorm:"index"`
+	ExternalID     *string        `gorm:"uniqueIndex"`
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+}
+
+func (liveUserNamesUser) TableName() string { return "users" }
+
+// liveUserNamesPrevious restores the table-wide uniqueness on the way down
+type liveUserNamesPrevious struct {
+	Username string `gorm:"uniqueIndex:idx_users_username"`
+	Email    string `gorm:"uniqueIndex:idx_users_email,where:email <> ''"`
+}
+
+func (liveUserNamesPrevious) TableName() string { return "users" }
+
+var liveUserNames = Migration{
+	Version: 4,
+	Name:    "live_user_names",
+	Up: func(tx *gorm.DB) error {
+		// uni_users_username from current gorm versions, users_username_key from older ones
+		for _, constraint := range []string{"uni_users_username", "users_username_key"} {
+			if tx.Migrator().HasConstraint(&liveUserNamesUser{}, constraint) {
+				if err := tx.Migrator().DropConstraint(&liveUserNamesUser{}, constraint); err != nil {
+					return err
+				}
+			}
+		}
+		if tx.Migrator().HasIndex(&liveUserNamesUser{}, "idx_users_email") {
+			if err := tx.Migrator().DropIndex(&liveUserNamesUser{}, "idx_users_email"); err != nil {
+				return err
+			}
+		}
+		for _, index := range []string{"idx_users_username", "idx_users_email", "Disabled", "OrganizationID", "ExternalID", "DeletedAt"} {
+			if tx.Migrator().HasIndex(&liveUserNamesUser{}, index) {
+				continue
+			}
+			if err := tx.Migrator().CreateIndex(&liveUserNamesUser{}, index); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		for _, index := range []string{"idx_users_username", "idx_users_email"} {
+			if err := tx.Migrator().DropIndex(&liveUserNamesPrevious{}, index); err != nil {
+				return err
+			}
+			if err := tx.Migrator().CreateIndex(&liveUserNamesPrevious{}, index); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,78 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// organizationScope records which organization owns each flag, SDK key, custom role and
+// service account, so members of one organization cannot see another's. When there is a single
+// organization, existing rows and users without one are moved into it so nothing disappears
+// from its members; otherwise they belong to no organization.
+type organizationScopeFeatureFlag struct {
+	OrganizationID *uint `gorm:"index"`
+}
+
+func (organizationScopeFeatureFlag) TableName() string { return "feature_flags" }
+
+type organizationScopeSDKKey struct {
+	OrganizationID *uint `gorm:"index"`
+}
+
+func (organizationScopeSDKKey) TableName() string { return "sdk_keys" }
+
+type organizationScopeCustomRole struct {
+	OrganizationID *uint `gorm:"index"`
+}
+
+func (organizationScopeCustomRole) TableName() string { return "custom_roles" }
+
+type organizationScopeServiceAccount struct {
+	OrganizationID *uint `gorm:"index"`
+}
+
+func (organizationScopeServiceAccount) TableName() string { return "service_accounts" }
+
+var organizationScopeTables = []interface{}{
+	&organizationScopeFeatureFlag{}, &organizationScopeSDKKey{}, &organizationScopeCustomRole{}, &organizationScopeServiceAccount{},
+}
+
+var organizationScope = Migration{
+	Version: 5,
+	Name:    "organization_scope",
+	Up: func(tx *gorm.DB) error {
+		for _, table := range organizationScopeTables {
+			if tx.Migrator().HasColumn(table, "OrganizationID") {
+				continue
+			}
+			if err := tx.Migrator().AddColumn(table, "OrganizationID"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().CreateIndex(table, "OrganizationID"); err != nil {
+				return err
+			}
+		}
+
+		var orgs []uint
+		if err := tx.Table("organizations").Limit(2).Pluck("id", &orgs).Error; err != nil {
+			return err
+		}
+		if len(orgs) != 1 {
+			return nil
+		}
+		for _, table := range []string{"feature_flags", "sdk_keys", "custom_roles", "service_accounts", "users"} {
+			if err := tx.Table(table).Where("organization_id IS NULL").Update("organization_id", orgs[0]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		for _, table := range organizationScopeTables {
+			if err := tx.Migrator().DropIndex(table, "OrganizationID"); err != nil {
+				return err
+			}
+			if err := dropColumns(tx, table, "OrganizationID"); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// organizationNames makes flag, custom role and service account names unique within an
+// organization rather than across the deployment, so one organization cannot learn another's
+// names by being refused one. Deleted rows don't hold on to their name. Rows without an
+// organization are compared as organization 0, since a unique index never matches NULLs.
+// Each entry names the per-organization index and the deployment-wide one it replaces.
+var organizationNamesIndexes = []struct {
+	table, index, previous string
+}{
+	{"feature_flags", "idx_feature_flags_organization_name", "idx_feature_flags_name"},
+	{"custom_roles", "idx_custom_roles_organization_name", "idx_custom_roles_name"},
+	{"service_accounts", "idx_service_accounts_organization_name", "idx_service_accounts_name"},
+}
+
+// organizationNamesFeatureFlag and the types below restore the deployment-wide indexes on the way down
+type organizationNamesFeatureFlag struct {
+	Name string `gorm:"uniqueIndex:idx_feature_flags_name,where:deleted_at IS NULL"`
+}
+
+func (organizationNamesFeatureFlag) TableName() string { return "feature_flags" }
+
+type organizationNamesCustomRole struct {
+	Name string `gorm:"uniqueIndex:idx_custom_roles_name"`
+}
+
+func (organizationNamesCustomRole) TableName() string { return "custom_roles" }
+
+type organizationNamesServiceAccount struct {
+	Name string `gorm:"uniqueIndex:idx_service_accounts_name,where:deleted_at IS NULL"`
+}
+
+func (organizationNamesServiceAccount) TableName() string { return "service_accounts" }
+
+var organizationNamesPrevious = []interface{}{
+	&organizationNamesFeatureFlag{}, &organizationNamesCustomRole{}, &organizationNamesServiceAccount{},
+}
+
+var organizationNames = Migration{
+	Version: 6,
+	Name:    "organization_names",
+	Up: func(tx *gorm.DB) error {
+		// uni_custom_roles_name and custom_roles_name_key are left by AutoMigrate from `unique` tags
+		for _, constraint := range []string{"uni_custom_roles_name", "custom_roles_name_key"} {
+			if tx.Migrator().HasConstraint(&organizationNamesCustomRole{}, constraint) {
+				if err := tx.Migrator().DropConstraint(&organizationNamesCustomRole{}, constraint); err != nil {
+					return err
+				}
+			}
+		}
+		for _, names := range organizationNamesIndexes {
+			if tx.Migrator().HasIndex(names.table, names.previous) {
+				if err := tx.Migrator().DropIndex(names.table, names.previous); err != nil {
+					return err
+				}
+			}
+			if tx.Migrator().HasIndex(names.table, names.index) {
+				continue
+			}
+			if err := tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (COALESCE(organization_id, 0), name) WHERE deleted_at IS NULL",
+				names.index, names.table)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		for i, names := range organizationNamesIndexes {
+			if err := tx.Migrator().DropIndex(names.table, names.index); err != nil {
+				return err
+			}
+			if err := tx.Migrator().CreateIndex(organizationNamesPrevious[i], names.previous); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
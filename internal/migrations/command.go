@@ -0,0 +1,77 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrUsage is returned for an unknown migrate subcommand or bad arguments
+var ErrUsage = errors.New("usage: migrate up | down [steps] | status | to <version>")
+
+// Run executes the migrate command line, e.g. "up", "down 2", "status" or "to 3"
+func Run(db *gorm.DB, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	var (
+		done []Migration
+		err  error
+	)
+	switch args[0] {
+	case "up":
+		done, err = Up(db)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return ErrUsage
+			}
+		}
+		done, err = Down(db, steps)
+	case "to":
+		if len(args) < 2 {
+			return ErrUsage
+		}
+		version, perr := strconv.ParseInt(args[1], 10, 64)
+		if perr != nil || version < 0 {
+			return ErrUsage
+		}
+		done, err = To(db, version)
+	case "status":
+		return printStatus(db, out)
+	default:
+		return ErrUsage
+	}
+
+	for _, m := range done {
+		fmt.Fprintf(out, "%d %s\n", m.Version, m.Name)
+	}
+	if err == nil && len(done) == 0 {
+		fmt.Fprintln(out, "Nothing to do")
+	}
+	return err
+}
+
+func printStatus(db *gorm.DB, out io.Writer) error {
+	statuses, err := List(db)
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		state := "pending"
+		if s.AppliedAt != nil {
+			state = "applied " + s.AppliedAt.Format(time.RFC3339)
+		}
+		if s.Unknown {
+			state += " (unknown to this build)"
+		}
+		fmt.Fprintf(out, "%4d  %-30s %s\n", s.Version, s.Name, state)
+	}
+	return nil
+}
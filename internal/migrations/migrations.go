@@ -0,0 +1,335 @@
+// Package migrations applies versioned schema changes and records them in the schema_migrations table.
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is one versioned schema change. Up and Down run inside a transaction.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// All known migrations in the order they are applied. Append new ones with the next version;
+// never edit or renumber one that has shipped.
+var All = []Migration{
+	baseline,
+	flagScope,
+	organizationMFA,
+	liveUserNames,
+	organizationScope,
+	organizationNames,
+}
+
+// AppliedMigration is a row of the schema_migrations table
+type AppliedMigration struct {
+	Version   int64  `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"not null"`
+	AppliedAt time.Time
+}
+
+// TableName keeps the conventional name rather than gorm's pluralisation
+func (AppliedMigration) TableName() string { return "schema_migrations" }
+
+// Status of a single migration
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+	Unknown   bool // Applied to the database but not part of this build
+}
+
+var (
+	ErrSchemaTooNew     = errors.New("database schema is newer than this build")
+	ErrSchemaBehind     = errors.New("database schema has pending migrations")
+	ErrUnknownVersion   = errors.New("unknown migration version")
+	ErrIrreversible     = errors.New("migration cannot be reverted")
+	ErrInvalidMigration = errors.New("invalid migration list")
+)
+
+// advisoryLockKey identifies the migration lock among other Postgres advisory locks
+const advisoryLockKey int64 = 0x66666c61676d6967 // "fflagmig"
+
+// Validate checks that versions are positive, strictly increasing and have an Up step
+func Validate(list []Migration) error {
+	var last int64
+	for _, m := range list {
+		if m.Version <= last {
+			return fmt.Errorf("%w: version %d must be greater than %d", ErrInvalidMigration, m.Version, last)
+		}
+		if m.Name == "" || m.Up == nil {
+			return fmt.Errorf("%w: version %d needs a name and an Up step", ErrInvalidMigration, m.Version)
+		}
+		last = m.Version
+	}
+	return nil
+}
+
+// Latest returns the newest version this build knows about
+func Latest() int64 {
+	if len(All) == 0 {
+		return 0
+	}
+	return All[len(All)-1].Version
+}
+
+// Up applies every pending migration
+func Up(db *gorm.DB) ([]Migration, error) {
+	return To(db, Latest())
+}
+
+// Down reverts the most recently applied migrations, newest first
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	var done []Migration
+	err := locked(db, func(conn *gorm.DB) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		versions := sortedVersions(applied)
+		if steps > len(versions) {
+			steps = len(versions)
+		}
+		pending, err := reversible(versions[len(versions)-steps:])
+		if err != nil {
+			return err
+		}
+		for _, m := range pending {
+			if err := revert(conn, m); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// To migrates up or down until version is the newest applied migration. Reverting the baseline
+// is refused with ErrIrreversible.
+func To(db *gorm.DB, version int64) ([]Migration, error) {
+	if version != 0 {
+		if _, ok := find(version); !ok {
+			return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+		}
+	}
+
+	var done []Migration
+	err := locked(db, func(conn *gorm.DB) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		// Revert anything above the target first, newest first
+		versions := sortedVersions(applied)
+		above := sort.Search(len(versions), func(i int) bool { return versions[i] > version })
+		pending, err := reversible(versions[above:])
+		if err != nil {
+			return err
+		}
+		for _, m := range pending {
+			if err := revert(conn, m); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+
+		for _, m := range All {
+			if m.Version > version {
+				break
+			}
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := apply(conn, m); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// List reports every known migration and whether it has been applied, plus any applied versions
+// this build does not know
+func List(db *gorm.DB) ([]Status, error) {
+	applied := map[int64]AppliedMigration{}
+	if db.Migrator().HasTable(&AppliedMigration{}) {
+		var err error
+		if applied, err = appliedVersions(db); err != nil {
+			return nil, err
+		}
+	}
+
+	var statuses []Status
+	for _, m := range All {
+		s := Status{Version: m.Version, Name: m.Name}
+		if row, ok := applied[m.Version]; ok {
+			at := row.AppliedAt
+			s.AppliedAt = &at
+			delete(applied, m.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for _, v := range sortedVersions(applied) {
+		at := applied[v].AppliedAt
+		statuses = append(statuses, Status{Version: v, Name: applied[v].Name, AppliedAt: &at, Unknown: true})
+	}
+	return statuses, nil
+}
+
+// Check refuses a schema that a newer build has migrated, and reports pending migrations as ErrSchemaBehind
+func Check(db *gorm.DB) error {
+	statuses, err := List(db)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, s := range statuses {
+		if s.Unknown {
+			return fmt.Errorf("%w: version %d (%s) is applied, this build knows up to %d", ErrSchemaTooNew, s.Version, s.Name, Latest())
+		}
+		if s.AppliedAt == nil {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d to apply", ErrSchemaBehind, pending)
+	}
+	return nil
+}
+
+func apply(db *gorm.DB, m Migration) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := m.Up(tx); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		return tx.Create(&AppliedMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+	})
+}
+
+// reversible returns the migrations for the applied versions, newest first, checking that all of
+// them can be reverted before any is, so a refused revert leaves the schema untouched
+func reversible(versions []int64) ([]Migration, error) {
+	var list []Migration
+	for i := len(versions) - 1; i >= 0; i-- {
+		m, ok := find(versions[i])
+		if !ok {
+			return nil, fmt.Errorf("%w: version %d is applied, this build knows up to %d", ErrSchemaTooNew, versions[i], Latest())
+		}
+		if m.Down == nil {
+			return nil, fmt.Errorf("%w: %d (%s)", ErrIrreversible, m.Version, m.Name)
+		}
+		list = append(list, m)
+	}
+	return list, nil
+}
+
+func revert(db *gorm.DB, m Migration) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := m.Down(tx); err != nil {
+			return fmt.Errorf("reverting migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		return tx.Delete(&AppliedMigration{}, m.Version).Error
+	})
+}
+
+// locked runs fn on a single connection while holding the migration lock, so replicas starting
+// at the same time apply each migration once. SQLite serialises writers itself and is single-instance.
+func locked(db *gorm.DB, fn func(conn *gorm.DB) error) error {
+	if err := Validate(All); err != nil {
+		return err
+	}
+	if db.Dialector.Name() != "postgres" {
+		if err := ensureTable(db); err != nil {
+			return err
+		}
+		return fn(db)
+	}
+
+	return db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", advisoryLockKey).Error; err != nil {
+			return fmt.Errorf("acquiring migration lock: %w", err)
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", advisoryLockKey)
+
+		if err := ensureTable(conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+func ensureTable(db *gorm.DB) error {
+	if db.Migrator().HasTable(&AppliedMigration{}) {
+		return nil
+	}
+	return db.Migrator().CreateTable(&AppliedMigration{})
+}
+
+func appliedVersions(db *gorm.DB) (map[int64]AppliedMigration, error) {
+	var rows []AppliedMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]AppliedMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+func sortedVersions(applied map[int64]AppliedMigration) []int64 {
+	versions := make([]int64, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+func find(version int64) (Migration, bool) {
+	for _, m := range All {
+		if m.Version == version {
+			return m, true
+		}
+	}
+	return Migration{}, false
+}
+
+// dropColumns drops columns from a model's table. SQLite rebuilds the table to drop a column,
+// losing every index on it, so the remaining indexes are recreated afterwards. Indexes on the
+// dropped columns must be dropped first.
+func dropColumns(tx *gorm.DB, model interface{}, fields ...string) error {
+	var indexes []string
+	if tx.Dialector.Name() == "sqlite" {
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		if err := tx.Raw("SELECT sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", stmt.Table).Scan(&indexes).Error; err != nil {
+			return err
+		}
+	}
+	for _, field := range fields {
+		if err := tx.Migrator().DropColumn(model, field); err != nil {
+			return err
+		}
+	}
+	for _, index := range indexes {
+		if err := tx.Exec(index).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"errors"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// Startup applies pending migrations when apply is set (MIGRATE_ON_START), and refuses to continue
+// against a schema this build does not match
+func Startup(db *gorm.DB, apply bool) error {
+	if err := Check(db); err == nil || !errors.Is(err, ErrSchemaBehind) {
+		return err
+	}
+	if !apply {
+		return Check(db)
+	}
+
+	applied, err := Up(db)
+	if err != nil {
+		return err
+	}
+	for _, m := range applied {
+		log.Printf("📦 Applied migration %d (%s)", m.Version, m.Name)
+	}
+	return Check(db)
+}
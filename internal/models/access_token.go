@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ServiceAccount is a non-human identity for automation such as CI pipelines. It holds a
+// single role, optionally limited to a project and/or environment, and authenticates
+// with access tokens only.
+type ServiceAccount struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	Name           string         `gorm:"not null" json:"name" example:"ci-pipeline"` // Unique within the organization among accounts not deleted
+	Description    string         `json:"description"`
+	Role           string         `gorm:"not null" json:"role" example:"editor"`
+	Project        string         `json:"project,omitempty"`
+	Environment    string         `json:"environment,omitempty"`
+	OrganizationID *uint          `gorm:"index" json:"organization_id,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// AccessToken is a long-lived API token owned by either a user (a personal access token) or a
+// service account. Only a hash of the secret is stored.
+type AccessToken struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	Name             string     `gorm:"not null" json:"name" example:"github-actions"`
+	Prefix           string     `gorm:"not null" json:"prefix"` // First characters of the token, for identification
+	TokenHash        string     `gorm:"uniqueIndex;not null" json:"-"`
+	UserID           *uint      `gorm:"index" json:"user_id,omitempty"`
+	ServiceAccountID *uint      `gorm:"index" json:"service_account_id,omitempty"`
+	Scopes           []string   `gorm:"serializer:json" json:"scopes"` // Action patterns the token may use; empty means all of the owner's
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Audit actions
+const (
+	AuditLoginFailed            = "login_failed"
+	AuditLoginLocked            = "login_locked"
+	AuditAccountUnlock          = "account_unlocked"
+	AuditMFAFailed              = "mfa_failed"
+	AuditRoleChanged            = "role_changed"
+	AuditUserDisabled           = "user_disabled"
+	AuditUserEnabled            = "user_enabled"
+	AuditUserDeleted            = "user_deleted"
+	AuditPasswordChanged        = "password_changed"
+	AuditPasswordResetRequested = "password_reset_requested"
+	AuditPasswordReset          = "password_reset"
+	AuditInviteCreated          = "invite_created"
+	AuditInviteAccepted         = "invite_accepted"
+	AuditInviteRevoked          = "invite_revoked"
+	AuditUserBootstrap          = "user_bootstrapped"
+)
+
+// AuditEntry records a security-relevant event
+type AuditEntry struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Action    string    `gorm:"index;not null" json:"action" example:"login_failed"`
+	Actor     string    `json:"actor,omitempty"`               // Who performed the action, if authenticated
+	Target    string    `gorm:"index" json:"target,omitempty"` // Username or resource affected
+	IPAddress string    `json:"ip_address,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Policy statement effects
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// PolicyStatement allows or denies actions on resources matching any of its patterns.
+// Actions are globs such as "flag:toggle" or "flag:*"; resources are "/"-separated
+// constraints such as "env:production/tag:mobile", or "*" for everything.
+type PolicyStatement struct {
+	Effect    string   `json:"effect" example:"allow"`
+	Actions   []string `json:"actions" example:"flag:toggle"`
+	Resources []string `json:"resources" example:"env:production/tag:mobile"`
+}
+
+// CustomRole is a named set of policy statements that can be granted like a built-in role
+type CustomRole struct {
+	ID             uint              `gorm:"primaryKey" json:"id"`
+	Name           string            `gorm:"not null" json:"name"` // Unique within the organization among roles not deleted
+	Description    string            `json:"description"`
+	Statements     []PolicyStatement `gorm:"serializer:json;not null" json:"statements"`
+	OrganizationID *uint             `gorm:"index" json:"organization_id,omitempty"` // Only the organization's members can see and grant the role
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt    `gorm:"index" json:"-"`
+}
@@ -6,13 +6,48 @@ import (
 	"gorm.io/gorm"
 )
 
+// Feature flag lifecycle states
+const (
+	FlagStateDraft      = "draft"
+	FlagStateActive     = "active"
+	FlagStateLaunched   = "launched"
+	FlagStateDeprecated = "deprecated"
+	FlagStateArchived   = "archived"
+)
+
+// FlagStates lists every valid lifecycle state
+var FlagStates = []string{FlagStateDraft, FlagStateActive, FlagStateLaunched, FlagStateDeprecated, FlagStateArchived}
+
 // FeatureFlag represents a feature flag in the system
 type FeatureFlag struct {
-	ID          uint           `gorm:"primaryKey" json:"id"`
-	Name        string         `gorm:"unique;not null" json:"name"`
-	Description string         `json:"description"`
-	IsEnabled   bool           `json:"is_enabled"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                uint           `gorm:"primaryKey" json:"id"`
+	Name              string         `gorm:"not null" json:"name"`                                   // Unique within the organization among flags not deleted
+	Project           string         `gorm:"index;not null;default:''" json:"project,omitempty"`     // Scopes project-bound grants
+	Environment       string         `gorm:"index;not null;default:''" json:"environment,omitempty"` // Scopes environment-bound grants
+	OrganizationID    *uint          `gorm:"index" json:"organization_id,omitempty"`                 // Only members of the organization see the flag
+	Description       string         `json:"description"`
+	IsEnabled         bool           `json:"is_enabled"`
+	State             string         `gorm:"index;not null;default:active" json:"state"`
+	Tags              []string       `gorm:"serializer:json" json:"tags,omitempty"`
+	ClientVisible     bool           `json:"client_visible"`               // Exposed to client-side SDK keys
+	RolloutPercentage *int           `json:"rollout_percentage,omitempty"` // nil serves every user
+	ActiveFrom        *time.Time     `json:"active_from,omitempty"`        // Start of the activation window
+	ActiveUntil       *time.Time     `json:"active_until,omitempty"`       // End of the activation window
+	Schedule          string         `json:"schedule,omitempty"`           // Recurring schedule, e.g. "* 9-16 * * 1-5"
+	TimeZone          string         `json:"time_zone,omitempty"`          // IANA zone for Schedule, UTC if empty
+	Prerequisites     []Prerequisite `gorm:"serializer:json" json:"prerequisites,omitempty"`
+	LastEvaluatedAt   *time.Time     `json:"last_evaluated_at,omitempty"`
+	LastControlAt     *time.Time     `json:"last_control_at,omitempty"`   // Last time the control variation was served
+	LastTreatmentAt   *time.Time     `json:"last_treatment_at,omitempty"` // Last time the treatment variation was served
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+
+	ActiveRollout *RolloutPlan `gorm:"-" json:"active_rollout,omitempty"` // Running or paused plan, not persisted
+}
+
+// Prerequisite requires another flag, by name, to serve a variation before this flag is evaluated
+type Prerequisite struct {
+	Flag      string `json:"flag" example:"new_checkout"`
+	Variation string `json:"variation" example:"treatment"`
 }
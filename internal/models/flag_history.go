@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Flag history actions
+const (
+	HistoryGuardrailRollback = "guardrail_rollback"
+)
+
+// FlagHistory records notable changes made to a feature flag
+type FlagHistory struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	FeatureFlagID uint      `gorm:"index;not null" json:"feature_flag_id"`
+	Action        string    `gorm:"not null" json:"action"`
+	Reason        string    `json:"reason"`
+	Actor         string    `json:"actor"`
+	CreatedAt     time.Time `json:"created_at"`
+}
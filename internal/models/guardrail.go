@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Flag variations that health signals can be tagged with
+const (
+	VariationControl   = "control"   // Users served the flag's off state
+	VariationTreatment = "treatment" // Users served the flag's on state
+)
+
+// Guardrail aggregations
+const (
+	AggregationMean = "mean"
+	AggregationSum  = "sum"
+	AggregationMax  = "max"
+)
+
+// Guardrail is a health threshold the treatment variation must stay under during a rollout
+type Guardrail struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	FeatureFlagID uint           `gorm:"index;not null" json:"feature_flag_id"`
+	Metric        string         `gorm:"not null" json:"metric" example:"error_count"`
+	Aggregation   string         `gorm:"not null" json:"aggregation" example:"sum"`
+	Threshold     float64        `json:"threshold" example:"10"`
+	Window        string         `gorm:"not null" json:"window" example:"5m"`
+	MinSamples    int            `json:"min_samples" example:"20"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// HealthSignal is a single metric observation reported by a client for one flag variation
+type HealthSignal struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	FeatureFlagID uint      `gorm:"index:idx_health_signals_lookup;not null" json:"feature_flag_id"`
+	Variation     string    `gorm:"not null" json:"variation"`
+	Metric        string    `gorm:"not null" json:"metric"`
+	Value         float64   `json:"value"`
+	ObservedAt    time.Time `gorm:"index:idx_health_signals_lookup" json:"observed_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
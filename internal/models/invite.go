@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Organization groups the users of one deployment; invites add users to an organization
+type Organization struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Name       string    `gorm:"uniqueIndex;not null" json:"name" example:"acme"`
+	RequireMFA bool      `gorm:"not null;default:false" json:"require_mfa"` // Every member must use two-factor authentication
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Invite is a single-use, expiring invitation to join an organization with a role. Only a
+// hash of the invite token is stored.
+type Invite struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	OrganizationID uint       `gorm:"index;not null" json:"organization_id"`
+	Role           string     `gorm:"not null" json:"role" example:"editor"`
+	Email          string     `json:"email,omitempty"`
+	TokenHash      string     `gorm:"uniqueIndex;not null" json:"-"`
+	CreatedBy      string     `json:"created_by,omitempty"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	AcceptedBy     *uint      `json:"accepted_by,omitempty"` // ID of the user created from the invite
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// RecoveryCode is a single-use backup for a user's second factor. Only a hash is stored.
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index;not null"`
+	CodeHash  string `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// MFARequirement makes two-factor authentication mandatory for every user holding a role
+type MFARequirement struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Role      string    `gorm:"uniqueIndex;not null" json:"role" example:"admin"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Built-in roles, from least to most privileged
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+	RoleOwner  = "owner"
+)
+
+var roleRanks = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+// RoleRank orders roles by privilege; unknown roles rank 0
+func RoleRank(role string) int {
+	return roleRanks[role]
+}
+
+// RoleBinding grants a user a role within a project and/or environment.
+// An empty Project or Environment matches any project or environment.
+type RoleBinding struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"index;not null" json:"user_id"`
+	Project     string    `json:"project,omitempty" example:"checkout"`
+	Environment string    `json:"environment,omitempty" example:"staging"`
+	Role        string    `gorm:"not null" json:"role" example:"editor"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Rollout plan statuses
+const (
+	RolloutStatusRunning   = "running"
+	RolloutStatusPaused    = "paused"
+	RolloutStatusCompleted = "completed"
+	RolloutStatusAborted   = "aborted"
+)
+
+// RolloutStep is a single stage of a progressive rollout
+type RolloutStep struct {
+	Percentage int    `json:"percentage" example:"5"`
+	Duration   string `json:"duration" example:"24h"` // How long to hold this step, e.g. "24h"
+}
+
+// RolloutPlan ramps a feature flag through a series of timed percentage steps
+type RolloutPlan struct {
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	FeatureFlagID   uint           `gorm:"index;not null" json:"feature_flag_id"`
+	Steps           []RolloutStep  `gorm:"serializer:json;not null" json:"steps"`
+	CurrentStep     int            `json:"current_step"`
+	Status          string         `gorm:"index;not null" json:"status"`
+	StepStartedAt   time.Time      `json:"step_started_at"`
+	PausedAt        *time.Time     `json:"paused_at,omitempty"`
+	StartIsEnabled  bool           `json:"start_is_enabled"`
+	StartPercentage *int           `json:"start_percentage,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// IsActive reports whether the plan is still driving its flag
+func (p *RolloutPlan) IsActive() bool {
+	return p.Status == RolloutStatusRunning || p.Status == RolloutStatusPaused
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// SDK key kinds
+const (
+	SDKKeyServer = "server" // Full read access to flag configurations
+	SDKKeyClient = "client" // Evaluation only, limited to client-visible flags
+)
+
+// SDKKey authenticates an SDK for one environment. Only a hash of the key is stored.
+type SDKKey struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	Environment    string     `gorm:"index;not null" json:"environment" example:"production"`
+	OrganizationID *uint      `gorm:"index" json:"organization_id,omitempty"` // The key only serves the organization's flags
+	Kind           string     `gorm:"not null" json:"kind" example:"server"`
+	Prefix         string     `gorm:"not null" json:"prefix"` // First characters of the key, for identification
+	KeyHash        string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"` // Set when the key is rotated out
+	CreatedAt      time.Time  `json:"created_at"`
+}
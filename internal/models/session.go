@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Session is a login on one device. Its refresh tokens form a family: each refresh replaces the
+// current token, and presenting a replaced token again revokes the whole session.
+type Session struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	ExpiresAt  time.Time  `gorm:"index;not null" json:"expires_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Current    bool       `gorm:"-" json:"current"` // Whether the listing request was made with this session
+}
+
+// RefreshToken is one token in a session's refresh family. Only a hash is stored.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	SessionID uint       `gorm:"index;not null"`
+	TokenHash string     `gorm:"uniqueIndex;not null"`
+	UsedAt    *time.Time // Set once the token has been exchanged; a second use is a replay
+	CreatedAt time.Time
+}
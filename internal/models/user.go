@@ -8,10 +8,20 @@ import (
 
 // User model for authentication
 type User struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Username  string         `gorm:"unique;not null" json:"username"`
-	Password  string         `gorm:"not null" json:"-"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	Username       string         `gorm:"uniqueIndex:idx_users_username,where:deleted_at IS NULL;not null" json:"username"`
+	Password       string         `gorm:"not null" json:"-"`
+	Role           string         `gorm:"not null;default:viewer" json:"role"`
+	Email          string         `gorm:"uniqueIndex:idx_users_email,where:email <> '' AND deleted_at IS NULL" json:"email,omitempty" example:"alice@example.com"`
+	DisplayName    string         `json:"display_name,omitempty" example:"Alice Example"`
+	Disabled       bool           `gorm:"index" json:"disabled"` // Disabled users cannot log in or use existing tokens
+	LastLoginAt    *time.Time     `json:"last_login_at,omitempty"`
+	OrganizationID *uint          `gorm:"index" json:"organization_id,omitempty"`
+	ExternalID     *string        `gorm:"uniqueIndex" json:"-"` // "issuer|subject" for users provisioned by single sign-on
+	MFAEnabled     bool           `json:"mfa_enabled"`
+	TOTPSecret     string         `json:"-"` // Set at enrollment; MFA is only enforced once MFAEnabled is true
+	TOTPLastStep   int64          `json:"-"` // Last accepted TOTP time step, so a code cannot be replayed
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 }
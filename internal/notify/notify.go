@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message is a notification for one recipient, such as a password reset email
+type Message struct {
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// Notifier delivers messages to users. Production deployments plug in an email or chat
+// integration; the log and file notifiers are for local development.
+type Notifier interface {
+	Send(msg Message) error
+}
+
+// LogNotifier writes messages to the service log
+type LogNotifier struct{}
+
+// Send logs the message
+func (LogNotifier) Send(msg Message) error {
+	log.Printf("📨 Notification to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileNotifier appends messages to a file as JSON lines, so tests and local tools can read them
+type FileNotifier struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// Send appends the message to the file
+func (n *FileNotifier) Send(msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(msg)
+}
+
+var (
+	mu      sync.RWMutex
+	current Notifier
+)
+
+// Default returns the process-wide notifier, logging messages unless Use chose another
+func Default() Notifier {
+	mu.RLock()
+	defer mu.RUnlock()
+	if current == nil {
+		return LogNotifier{}
+	}
+	return current
+}
+
+// Use replaces the process-wide notifier, e.g. with an email integration
+func Use(n Notifier) {
+	mu.Lock()
+	current = n
+	mu.Unlock()
+}
+
+// New returns the notifier selected by NOTIFIER: "log", or "file" appending to path (NOTIFIER_FILE)
+func New(kind, path string) (Notifier, error) {
+	switch kind {
+	case "", "log":
+		return LogNotifier{}, nil
+	case "file":
+		return &FileNotifier{Path: path}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %q", kind)
+	}
+}
+
+// Sendf builds and sends a message, stamping the send time
+func Sendf(to, subject, format string, args ...interface{}) error {
+	return Default().Send(Message{To: to, Subject: subject, Body: fmt.Sprintf(format, args...), SentAt: time.Now()})
+}
@@ -0,0 +1,365 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"feature-flag-service/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ID token signing algorithms accepted from the identity provider
+var allowedAlgorithms = []string{"RS256", "ES256", "EdDSA"}
+
+var (
+	ErrNotConfigured = errors.New("OIDC single sign-on is not configured")
+	ErrInvalidToken  = errors.New("invalid ID token")
+	ErrExchange      = errors.New("authorization code exchange failed")
+)
+
+// Config describes the relying party registration with the identity provider
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	GroupsClaim  string            // ID token claim listing the user's groups
+	RoleMapping  map[string]string // IdP group to role
+	DefaultRole  string            // Role for users in no mapped group
+}
+
+// ParseScopes splits a space- or comma-separated scope list (OIDC_SCOPES)
+func ParseScopes(scopes string) []string {
+	return strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+}
+
+// ParseRoleMapping reads a mapping such as "flag-admins=admin,flag-editors=editor" (OIDC_ROLE_MAPPING)
+func ParseRoleMapping(mapping string) map[string]string {
+	roles := map[string]string{}
+	for _, pair := range strings.Split(mapping, ",") {
+		group, role, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		roles[strings.TrimSpace(group)] = strings.TrimSpace(role)
+	}
+	return roles
+}
+
+// RoleFor maps a user's groups to the most privileged mapped role, or the default role
+func (cfg Config) RoleFor(groups []string) string {
+	role := ""
+	for _, group := range groups {
+		mapped, ok := cfg.RoleMapping[group]
+		if ok && (role == "" || models.RoleRank(mapped) > models.RoleRank(role)) {
+			role = mapped
+		}
+	}
+	if role == "" {
+		return cfg.DefaultRole
+	}
+	return role
+}
+
+// Identity is the verified content of an ID token
+type Identity struct {
+	Subject           string   `json:"sub"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+	Nonce             string   `json:"nonce"`
+	Groups            []string `json:"-"`
+}
+
+// Username picks a readable username for a newly provisioned user
+func (id *Identity) Username() string {
+	switch {
+	case id.PreferredUsername != "":
+		return id.PreferredUsername
+	case id.Email != "":
+		return id.Email
+	}
+	return id.Subject
+}
+
+// AuthRequest holds the per-login secrets that must survive the redirect to the provider
+type AuthRequest struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"` // PKCE code verifier; only its S256 challenge is sent to the provider
+}
+
+// NewAuthRequest generates a fresh state, nonce and PKCE verifier
+func NewAuthRequest() (*AuthRequest, error) {
+	values := make([]string, 3)
+	for i := range values {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return &AuthRequest{State: values[0], Nonce: values[1], Verifier: values[2]}, nil
+}
+
+// Challenge returns the S256 PKCE code challenge for the verifier
+func (r *AuthRequest) Challenge() string {
+	sum := sha256.Sum256([]byte(r.Verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+var (
+	defaultMu       sync.Mutex
+	defaultConfig   Config
+	defaultProvider *Provider
+)
+
+// Configure sets the relying party used by Default; single sign-on stays off without an issuer
+func Configure(cfg Config) {
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	if cfg.DefaultRole == "" {
+		cfg.DefaultRole = models.RoleViewer
+	}
+
+	defaultMu.Lock()
+	defaultConfig = cfg
+	defaultProvider = nil
+	defaultMu.Unlock()
+}
+
+// Default returns the provider set with Configure, discovering it on first use.
+// A failed discovery is retried on the next call rather than cached.
+func Default(ctx context.Context) (*Provider, error) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	if defaultProvider != nil {
+		return defaultProvider, nil
+	}
+	if defaultConfig.Issuer == "" {
+		return nil, ErrNotConfigured
+	}
+	provider, err := Discover(ctx, defaultConfig, nil)
+	if err != nil {
+		return nil, err
+	}
+	defaultProvider = provider
+	return provider, nil
+}
+
+// Provider is a discovered OpenID provider
+type Provider struct {
+	Config                Config
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+
+	client *http.Client
+	mu     sync.Mutex
+	keys   map[string]interface{}
+}
+
+// Discover loads the provider's metadata from its issuer's well-known configuration
+func Discover(ctx context.Context, cfg Config, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	var metadata struct {
+		Issuer string `json:"issuer"`
+		Provider
+	}
+	if err := getJSON(ctx, client, cfg.Issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != cfg.Issuer {
+		return nil, fmt.Errorf("OIDC discovery failed: issuer %q does not match %q", metadata.Issuer, cfg.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery failed: provider metadata is incomplete")
+	}
+
+	return &Provider{
+		Config:                cfg,
+		AuthorizationEndpoint: metadata.AuthorizationEndpoint,
+		TokenEndpoint:         metadata.TokenEndpoint,
+		JWKSURI:               metadata.JWKSURI,
+		client:                client,
+	}, nil
+}
+
+// AuthCodeURL is where the user is sent to log in
+func (p *Provider) AuthCodeURL(req *AuthRequest) string {
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.Config.ClientID},
+		"redirect_uri":          {p.Config.RedirectURL},
+		"scope":                 {strings.Join(p.Config.Scopes, " ")},
+		"state":                 {req.State},
+		"nonce":                 {req.Nonce},
+		"code_challenge":        {req.Challenge()},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// Exchange redeems an authorization code and returns the verified identity
+func (p *Provider) Exchange(ctx context.Context, code string, req *AuthRequest) (*Identity, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.Config.RedirectURL},
+		"client_id":     {p.Config.ClientID},
+		"code_verifier": {req.Verifier},
+	}
+	if p.Config.ClientSecret != "" {
+		form.Set("client_secret", p.Config.ClientSecret)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return nil, fmt.Errorf("%w: %s %s", ErrExchange, body.Error, body.ErrorDescription)
+	}
+
+	identity, err := p.Verify(ctx, body.IDToken)
+	if err != nil {
+		return nil, err
+	}
+	if identity.Nonce != req.Nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+	return identity, nil
+}
+
+// Verify checks an ID token's signature, issuer, audience and expiry
+func (p *Provider) Verify(ctx context.Context, rawIDToken string) (*Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods(allowedAlgorithms),
+		jwt.WithIssuer(p.Config.Issuer),
+		jwt.WithAudience(p.Config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	// Round-trip through JSON to pick out the standard profile claims
+	raw, _ := json.Marshal(claims)
+	identity := &Identity{}
+	if err := json.Unmarshal(raw, identity); err != nil || identity.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+	identity.Groups = stringList(claims[p.Config.GroupsClaim])
+	return identity, nil
+}
+
+// key returns the provider's verification key, refetching the JWKS once for an unknown kid
+// so provider key rotation is picked up
+func (p *Provider) key(ctx context.Context, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := getJSON(ctx, p.client, p.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	p.keys = map[string]interface{}{}
+	for _, k := range set.Keys {
+		if public, err := k.publicKey(); err == nil {
+			p.keys[k.Kid] = public
+		}
+	}
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// stringList accepts a group claim sent as either a list or a single string
+func stringList(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		list := make([]string, 0, len(t))
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package passwords
+
+import (
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// Validate checks a password for a new account against the current policy
+func Validate(username, password string) error {
+	return Current().Check(username, password)
+}
+
+// Hash validates a password for a new account and hashes it
+func Hash(username, password string) (string, error) {
+	if err := Validate(username, password); err != nil {
+		return "", err
+	}
+	return utils.HashPassword(password)
+}
+
+// Set replaces a user's password after checking the policy and that it is not one of their
+// recent passwords, and records it in the user's history
+func Set(tx *gorm.DB, user *models.User, password string, now time.Time) error {
+	policy := Current()
+	if err := policy.Check(user.Username, password); err != nil {
+		return err
+	}
+	if err := checkHistory(tx, policy, user, password); err != nil {
+		return err
+	}
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(user).Update("password", hashedPassword).Error; err != nil {
+		return err
+	}
+	return Remember(tx, user.ID, hashedPassword, now)
+}
+
+// Change sets a user's new password and records it in their history in one transaction
+func Change(user *models.User, password string, now time.Time) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		return Set(tx, user, password, now)
+	})
+}
+
+// Remember adds a password hash to a user's history, keeping only as many entries as the policy checks
+func Remember(tx *gorm.DB, userID uint, hashedPassword string, now time.Time) error {
+	policy := Current()
+	if policy.HistorySize == 0 {
+		return nil
+	}
+
+	if err := tx.Create(&models.PasswordHistory{UserID: userID, PasswordHash: hashedPassword, CreatedAt: now}).Error; err != nil {
+		return err
+	}
+	keep := tx.Model(&models.PasswordHistory{}).Select("id").Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").Limit(policy.HistorySize)
+	return tx.Where("user_id = ? AND id NOT IN (?)", userID, keep).Delete(&models.PasswordHistory{}).Error
+}
+
+// checkHistory rejects the current password and the ones kept in the user's history
+func checkHistory(tx *gorm.DB, policy *Policy, user *models.User, password string) error {
+	if policy.HistorySize == 0 {
+		return nil
+	}
+	if user.Password != "" && utils.CheckPassword(user.Password, password) == nil {
+		return ErrReused
+	}
+
+	var history []models.PasswordHistory
+	if err := tx.Where("user_id = ?", user.ID).Order("created_at DESC, id DESC").Limit(policy.HistorySize).Find(&history).Error; err != nil {
+		return err
+	}
+	for _, previous := range history {
+		if utils.CheckPassword(previous.PasswordHash, password) == nil {
+			return ErrReused
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package passwords
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// bcryptMaxLength is the number of bytes bcrypt looks at; anything longer would be silently truncated
+const bcryptMaxLength = 72
+
+var (
+	ErrTooShort         = errors.New("password is too short")
+	ErrTooLong          = fmt.Errorf("password must be at most %d bytes", bcryptMaxLength)
+	ErrBreached         = errors.New("password appears in a list of breached passwords")
+	ErrContainsUsername = errors.New("password must not contain the username")
+	ErrReused           = errors.New("password was used recently; choose a different one")
+)
+
+// Policy decides which passwords are acceptable
+type Policy struct {
+	MinLength   int                 // Minimum length in characters
+	HistorySize int                 // Number of previous passwords that may not be reused; 0 disables the check
+	breached    map[string]struct{} // Lowercased passwords and uppercase SHA-1 hex digests
+}
+
+// DefaultPolicy applies until Configure is called: 12 characters and no reuse of the last 5 passwords
+func DefaultPolicy() *Policy {
+	return &Policy{MinLength: 12, HistorySize: 5}
+}
+
+// NewPolicy builds a policy, loading breachedList, a file of breached passwords, when it is set
+func NewPolicy(minLength, historySize int, breachedList string) (*Policy, error) {
+	policy := &Policy{MinLength: minLength, HistorySize: historySize}
+	if breachedList != "" {
+		if err := policy.LoadBreached(breachedList); err != nil {
+			return nil, fmt.Errorf("PASSWORD_BREACHED_LIST: %w", err)
+		}
+	}
+	return policy, nil
+}
+
+// LoadBreached reads a breached-password list with one entry per line. An entry is either a
+// plaintext password or a SHA-1 hex digest, optionally followed by ":count" as in the
+// Have I Been Pwned downloads.
+func (p *Policy) LoadBreached(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if p.breached == nil {
+		p.breached = make(map[string]struct{})
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if digest, _, _ := strings.Cut(line, ":"); isSHA1(digest) {
+			p.breached[strings.ToUpper(digest)] = struct{}{}
+			continue
+		}
+		p.breached[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Check reports why a password is unacceptable for a user, or nil
+func (p *Policy) Check(username, password string) error {
+	if password == "" || utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("%w: use at least %d characters", ErrTooShort, p.MinLength)
+	}
+	if len(password) > bcryptMaxLength {
+		return ErrTooLong
+	}
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return ErrContainsUsername
+	}
+	if p.isBreached(password) {
+		return ErrBreached
+	}
+	return nil
+}
+
+func (p *Policy) isBreached(password string) bool {
+	if len(p.breached) == 0 {
+		return false
+	}
+	if _, ok := p.breached[strings.ToLower(password)]; ok {
+		return true
+	}
+	sum := sha1.Sum([]byte(password))
+	_, ok := p.breached[strings.ToUpper(hex.EncodeToString(sum[:]))]
+	return ok
+}
+
+func isSHA1(s string) bool {
+	if len(s) != 2*sha1.Size {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+var (
+	policyMu sync.RWMutex
+	policy   = DefaultPolicy()
+)
+
+// Use replaces the process-wide policy
+func Use(p *Policy) {
+	policyMu.Lock()
+	policy = p
+	policyMu.Unlock()
+}
+
+// Current returns the process-wide policy
+func Current() *Policy {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+	return policy
+}
+
+// IsRejected reports whether an error means the password was refused by the policy, as opposed to an internal failure
+func IsRejected(err error) bool {
+	for _, target := range []error{ErrTooShort, ErrTooLong, ErrBreached, ErrContainsUsername, ErrReused} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
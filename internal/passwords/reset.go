@@ -0,0 +1,103 @@
+package passwords
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/notify"
+	"feature-flag-service/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// resetPrefix marks password reset tokens
+const resetPrefix = "ffr_"
+
+// ResetTTL is how long a password reset token stays valid
+const ResetTTL = time.Hour
+
+var ErrInvalidResetToken = errors.New("reset token is invalid, expired or already used")
+
+// RequestReset sends a reset token to the user with the given username or email. Unknown,
+// disabled and single sign-on accounts, and accounts without an email, are skipped without an
+// error so callers cannot learn which accounts exist; the user is returned only when a token was sent.
+// resetURL is the page of the web app that collects the new password (PASSWORD_RESET_URL); the
+// token is appended to it in the link sent. Without it the bare token is sent.
+func RequestReset(login, resetURL string, now time.Time) (*models.User, error) {
+	login = strings.TrimSpace(login)
+	var user models.User
+	err := config.DB.Where("(username = ? OR email = ?) AND disabled = ? AND external_id IS NULL AND email <> ''",
+		login, strings.ToLower(login), false).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := utils.NewSecret(resetPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the newest link works, so an older email that leaks later is harmless
+		if err := tx.Model(&models.PasswordResetToken{}).Where("user_id = ? AND used_at IS NULL", user.ID).
+			UpdateColumn("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{UserID: user.ID, TokenHash: utils.HashSecret(plaintext), ExpiresAt: now.Add(ResetTTL)}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := notify.Sendf(user.Email, "Reset your password",
+		"Someone asked to reset the password for %s. To choose a new password, open %s within %s. If this wasn't you, ignore this message.",
+		user.Username, resetLink(resetURL, plaintext), ResetTTL); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Reset redeems a reset token and sets the user's new password
+func Reset(plaintext, password string, now time.Time) (*models.User, error) {
+	var user models.User
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var token models.PasswordResetToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", utils.HashSecret(plaintext)).First(&token).Error; err != nil {
+			return ErrInvalidResetToken
+		}
+		if token.UsedAt != nil || !token.ExpiresAt.After(now) {
+			return ErrInvalidResetToken
+		}
+		if err := tx.Where("disabled = ?", false).First(&user, token.UserID).Error; err != nil {
+			return ErrInvalidResetToken
+		}
+
+		if err := Set(tx, &user, password, now); err != nil {
+			return err
+		}
+		return tx.Model(&token).UpdateColumn("used_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func resetLink(base, token string) string {
+	if base == "" {
+		return "the reset form with token " + token
+	}
+	separator := "?"
+	if strings.Contains(base, "?") {
+		separator = "&"
+	}
+	return base + separator + "token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,344 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"feature-flag-service/internal/models"
+)
+
+// Actions checked by the API
+const (
+	FlagRead        = "flag:read"
+	FlagCreate      = "flag:create"
+	FlagUpdate      = "flag:update"
+	FlagToggle      = "flag:toggle"
+	FlagUpdateRules = "flag:updateRules"
+	FlagDelete      = "flag:delete"
+	FlagRestore     = "flag:restore"
+	FlagPurge       = "flag:purge"
+	SignalWrite     = "signal:write"
+	SegmentWrite    = "segment:write"
+	UserRead        = "user:read"
+	UserManage      = "user:manage"
+	RoleRead        = "role:read"
+	RoleManage      = "role:manage"
+	SDKKeyRead      = "sdkkey:read"
+	SDKKeyManage    = "sdkkey:manage"
+
+	ServiceAccountRead   = "serviceaccount:read"
+	ServiceAccountManage = "serviceaccount:manage"
+	SystemHealth         = "system:health" // Not a read action, so viewers and editors do not get it
+)
+
+// Actions lists every known action, used to explain what a subject may do
+var Actions = []string{
+	FlagRead, FlagCreate, FlagUpdate, FlagToggle, FlagUpdateRules, FlagDelete, FlagRestore, FlagPurge,
+	SignalWrite, SegmentWrite, UserRead, UserManage, RoleRead, RoleManage, SDKKeyRead, SDKKeyManage,
+	ServiceAccountRead, ServiceAccountManage, SystemHealth,
+}
+
+var ErrInvalidStatement = errors.New("invalid policy statement")
+
+// builtins expresses the fixed roles as policies
+var builtins = map[string][]models.PolicyStatement{
+	models.RoleViewer: {
+		{Effect: models.EffectAllow, Actions: []string{"*:read"}, Resources: []string{"*"}},
+	},
+	models.RoleEditor: {
+		{Effect: models.EffectAllow, Actions: []string{"*:read"}, Resources: []string{"*"}},
+		{Effect: models.EffectAllow, Actions: []string{FlagCreate, FlagUpdate, FlagToggle, FlagUpdateRules, SignalWrite, SegmentWrite}, Resources: []string{"*"}},
+	},
+	models.RoleAdmin: {
+		{Effect: models.EffectAllow, Actions: []string{"*"}, Resources: []string{"*"}},
+	},
+	models.RoleOwner: {
+		{Effect: models.EffectAllow, Actions: []string{"*"}, Resources: []string{"*"}},
+	},
+}
+
+// Resource is the target of an action
+type Resource struct {
+	Project     string   `json:"project,omitempty"`
+	Environment string   `json:"environment,omitempty"`
+	Flag        string   `json:"flag,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+
+	// Organization owning a stored record, 0 for none; nil when the request names no record
+	Organization *uint `json:"organization_id,omitempty"`
+}
+
+// Grant is a role held by a subject, optionally limited to a project and/or environment
+type Grant struct {
+	Role        string `json:"role"`
+	Project     string `json:"project,omitempty"`
+	Environment string `json:"environment,omitempty"`
+}
+
+// RoleResolver returns the statements of a custom role
+type RoleResolver func(name string) ([]models.PolicyStatement, bool)
+
+// Decision is the result of an authorization check, with enough detail to debug a denial
+type Decision struct {
+	Action    string                  `json:"action"`
+	Allowed   bool                    `json:"allowed"`
+	Reason    string                  `json:"reason"`
+	Role      string                  `json:"role,omitempty"`
+	Statement *models.PolicyStatement `json:"statement,omitempty"`
+}
+
+// Engine evaluates grants against policy statements
+type Engine struct {
+	Resolve RoleResolver
+}
+
+// IsBuiltin reports whether a role is one of the fixed roles
+func IsBuiltin(role string) bool {
+	_, ok := builtins[role]
+	return ok
+}
+
+// Authorize decides whether the grants allow an action on a resource. Explicit denies
+// win over allows, and anything not allowed is denied.
+func (e *Engine) Authorize(grants []Grant, action string, resource Resource) Decision {
+	var allow *Decision
+	for _, grant := range grants {
+		if !grantApplies(grant, resource) {
+			continue
+		}
+		for _, stmt := range e.statements(grant.Role) {
+			if !matchAction(stmt.Actions, action) || !matchResource(stmt.Resources, resource) {
+				continue
+			}
+			matched := stmt
+			if stmt.Effect == models.EffectDeny {
+				return Decision{Action: action, Allowed: false, Role: grant.Role, Statement: &matched,
+					Reason: fmt.Sprintf("explicitly denied by role %q", grant.Role)}
+			}
+			if allow == nil {
+				allow = &Decision{Action: action, Allowed: true, Role: grant.Role, Statement: &matched,
+					Reason: fmt.Sprintf("allowed by role %q", grant.Role)}
+			}
+		}
+	}
+
+	if allow != nil {
+		return *allow
+	}
+	return Decision{Action: action, Allowed: false, Reason: "no statement allows this action on the resource"}
+}
+
+// AuthorizeAny decides whether the grants allow an action on at least one resource, for listings
+// that then check each item with Authorize. Only a deny covering every resource rules it out.
+func (e *Engine) AuthorizeAny(grants []Grant, action string) Decision {
+	var allow *Decision
+	for _, grant := range grants {
+		for _, stmt := range e.statements(grant.Role) {
+			if !matchAction(stmt.Actions, action) {
+				continue
+			}
+			matched := stmt
+			unconditional := grant.Project == "" && grant.Environment == "" && coversEverything(stmt.Resources)
+			if stmt.Effect == models.EffectDeny && unconditional {
+				return Decision{Action: action, Allowed: false, Role: grant.Role, Statement: &matched,
+					Reason: fmt.Sprintf("explicitly denied by role %q", grant.Role)}
+			}
+			if stmt.Effect == models.EffectAllow && allow == nil {
+				allow = &Decision{Action: action, Allowed: true, Role: grant.Role, Statement: &matched,
+					Reason: fmt.Sprintf("allowed on some resources by role %q", grant.Role)}
+			}
+		}
+	}
+
+	if allow != nil {
+		return *allow
+	}
+	return Decision{Action: action, Allowed: false, Reason: "no statement allows this action"}
+}
+
+// Explain evaluates every known action for the grants on a resource
+func (e *Engine) Explain(grants []Grant, resource Resource) []Decision {
+	decisions := make([]Decision, len(Actions))
+	for i, action := range Actions {
+		decisions[i] = e.Authorize(grants, action, resource)
+	}
+	return decisions
+}
+
+// GrantedActions lists the known actions that some allow statement grants on at least one resource
+func GrantedActions(statements []models.PolicyStatement) []string {
+	var granted []string
+	for _, action := range Actions {
+		for _, stmt := range statements {
+			if stmt.Effect == models.EffectAllow && matchAction(stmt.Actions, action) {
+				granted = append(granted, action)
+				break
+			}
+		}
+	}
+	return granted
+}
+
+// GrantRole returns the built-in role needed to grant a custom role: a role that allows every
+// action reaches as far as the owner role, so only owners grant it; admins grant the rest
+func GrantRole(statements []models.PolicyStatement) string {
+	if len(GrantedActions(statements)) == len(Actions) {
+		return models.RoleOwner
+	}
+	return models.RoleAdmin
+}
+
+// Validate checks statements before they are saved in a custom role
+func Validate(statements []models.PolicyStatement) error {
+	if len(statements) == 0 {
+		return fmt.Errorf("%w: at least one statement is required", ErrInvalidStatement)
+	}
+	for i, stmt := range statements {
+		if stmt.Effect != models.EffectAllow && stmt.Effect != models.EffectDeny {
+			return fmt.Errorf("%w: statement %d effect must be allow or deny", ErrInvalidStatement, i+1)
+		}
+		if len(stmt.Actions) == 0 || len(stmt.Resources) == 0 {
+			return fmt.Errorf("%w: statement %d needs actions and resources", ErrInvalidStatement, i+1)
+		}
+		for _, action := range stmt.Actions {
+			if _, err := path.Match(action, ""); err != nil {
+				return fmt.Errorf("%w: statement %d action %q is not a valid pattern", ErrInvalidStatement, i+1, action)
+			}
+		}
+		for _, pattern := range stmt.Resources {
+			if err := validateResourcePattern(pattern); err != nil {
+				return fmt.Errorf("%w: statement %d: %v", ErrInvalidStatement, i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Restrict narrows an allow decision to the action patterns an access token was scoped to.
+// Tokens without scopes keep everything their owner is allowed.
+func Restrict(decision Decision, scopes []string) Decision {
+	if !decision.Allowed || len(scopes) == 0 || matchAction(scopes, decision.Action) {
+		return decision
+	}
+	return Decision{Action: decision.Action, Allowed: false, Reason: "the access token is not scoped for this action"}
+}
+
+// ValidateScopes checks that each token scope is a pattern matching at least one known action
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		known := false
+		for _, action := range Actions {
+			if ok, err := path.Match(scope, action); err != nil {
+				return fmt.Errorf("%w: scope %q is not a valid pattern", ErrInvalidStatement, scope)
+			} else if ok {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("%w: scope %q does not match any action", ErrInvalidStatement, scope)
+		}
+	}
+	return nil
+}
+
+func (e *Engine) statements(role string) []models.PolicyStatement {
+	if stmts, ok := builtins[role]; ok {
+		return stmts
+	}
+	if e.Resolve != nil {
+		if stmts, ok := e.Resolve(role); ok {
+			return stmts
+		}
+	}
+	return nil
+}
+
+func grantApplies(grant Grant, resource Resource) bool {
+	return (grant.Project == "" || grant.Project == resource.Project) &&
+		(grant.Environment == "" || grant.Environment == resource.Environment)
+}
+
+func coversEverything(patterns []string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAction(patterns []string, action string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, action); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchResource checks whether any pattern matches; every constraint in a pattern must hold
+func matchResource(patterns []string, resource Resource) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+		matched := true
+		for _, constraint := range strings.Split(pattern, "/") {
+			kind, glob, _ := strings.Cut(constraint, ":")
+			if !matchConstraint(kind, glob, resource) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func matchConstraint(kind, glob string, resource Resource) bool {
+	match := func(value string) bool {
+		ok, _ := path.Match(glob, value)
+		return ok
+	}
+	switch kind {
+	case "project":
+		return match(resource.Project)
+	case "env":
+		return match(resource.Environment)
+	case "flag":
+		return match(resource.Flag)
+	case "tag":
+		for _, tag := range resource.Tags {
+			if match(tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func validateResourcePattern(pattern string) error {
+	if pattern == "*" {
+		return nil
+	}
+	for _, constraint := range strings.Split(pattern, "/") {
+		kind, glob, found := strings.Cut(constraint, ":")
+		if !found {
+			return fmt.Errorf("resource constraint %q must look like kind:pattern", constraint)
+		}
+		switch kind {
+		case "project", "env", "flag", "tag":
+		default:
+			return fmt.Errorf("unknown resource kind %q (use project, env, flag or tag)", kind)
+		}
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("resource constraint %q is not a valid pattern", constraint)
+		}
+	}
+	return nil
+}
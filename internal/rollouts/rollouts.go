@@ -0,0 +1,265 @@
+package rollouts
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPlanActive   = errors.New("flag already has an active rollout plan")
+	ErrNoActivePlan = errors.New("flag has no active rollout plan")
+	ErrInvalidState = errors.New("rollout plan is not in a valid state for this action")
+	ErrInvalidSteps = errors.New("invalid rollout steps")
+)
+
+// ValidateSteps checks that a plan has at least one step with sane values
+func ValidateSteps(steps []models.RolloutStep) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("%w: at least one step is required", ErrInvalidSteps)
+	}
+	for i, step := range steps {
+		if step.Percentage < 0 || step.Percentage > 100 {
+			return fmt.Errorf("%w: step %d percentage must be between 0 and 100", ErrInvalidSteps, i+1)
+		}
+		d, err := time.ParseDuration(step.Duration)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("%w: step %d duration must be a positive duration such as \"24h\"", ErrInvalidSteps, i+1)
+		}
+	}
+	return nil
+}
+
+// ActivePlan returns the running or paused plan for a flag
+func ActivePlan(db *gorm.DB, flagID uint) (*models.RolloutPlan, error) {
+	var plan models.RolloutPlan
+	err := db.Where("feature_flag_id = ? AND status IN ?", flagID,
+		[]string{models.RolloutStatusRunning, models.RolloutStatusPaused}).
+		Order("id DESC").First(&plan).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoActivePlan
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// Start attaches a new plan to the flag and applies its first step
+func Start(flag *models.FeatureFlag, steps []models.RolloutStep, now time.Time) (*models.RolloutPlan, error) {
+	if err := ValidateSteps(steps); err != nil {
+		return nil, err
+	}
+
+	var plan *models.RolloutPlan
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := ActivePlan(tx, flag.ID); err == nil {
+			return ErrPlanActive
+		} else if !errors.Is(err, ErrNoActivePlan) {
+			return err
+		}
+
+		plan = &models.RolloutPlan{
+			FeatureFlagID:   flag.ID,
+			Steps:           steps,
+			Status:          models.RolloutStatusRunning,
+			StepStartedAt:   now,
+			StartIsEnabled:  flag.IsEnabled,
+			StartPercentage: flag.RolloutPercentage,
+		}
+		if err := tx.Create(plan).Error; err != nil {
+			return err
+		}
+
+		return applyPercentage(tx, flag, true, percentagePtr(steps[0].Percentage))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Pause freezes the plan on its current step
+func Pause(flagID uint, now time.Time) (*models.RolloutPlan, error) {
+	plan, err := ActivePlan(config.DB, flagID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Status != models.RolloutStatusRunning {
+		return nil, ErrInvalidState
+	}
+
+	plan.Status = models.RolloutStatusPaused
+	plan.PausedAt = &now
+	err = transition(config.DB, plan, models.RolloutStatusRunning, map[string]interface{}{
+		"status": plan.Status, "paused_at": plan.PausedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Resume continues a paused plan, keeping the time left on the current step
+func Resume(flagID uint, now time.Time) (*models.RolloutPlan, error) {
+	plan, err := ActivePlan(config.DB, flagID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Status != models.RolloutStatusPaused || plan.PausedAt == nil {
+		return nil, ErrInvalidState
+	}
+
+	plan.StepStartedAt = plan.StepStartedAt.Add(now.Sub(*plan.PausedAt))
+	plan.PausedAt = nil
+	plan.Status = models.RolloutStatusRunning
+	err = transition(config.DB, plan, models.RolloutStatusPaused, map[string]interface{}{
+		"status": plan.Status, "paused_at": nil, "step_started_at": plan.StepStartedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Abort stops the plan and returns the flag to the state it had before the plan started
+func Abort(flagID uint) (*models.RolloutPlan, error) {
+	plan, err := ActivePlan(config.DB, flagID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		return abort(tx, plan)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// AbortForFlag aborts the flag's active plan, if it has one, so a deleted flag does not keep a plan running
+func AbortForFlag(tx *gorm.DB, flagID uint) error {
+	plan, err := ActivePlan(tx, flagID)
+	if errors.Is(err, ErrNoActivePlan) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return abort(tx, plan)
+}
+
+func abort(tx *gorm.DB, plan *models.RolloutPlan) error {
+	from := plan.Status
+	plan.Status = models.RolloutStatusAborted
+	plan.PausedAt = nil
+	err := transition(tx, plan, from, map[string]interface{}{"status": plan.Status, "paused_at": nil})
+	if err != nil {
+		return err
+	}
+
+	var flag models.FeatureFlag
+	if err := tx.Unscoped().First(&flag, plan.FeatureFlagID).Error; err != nil {
+		return err
+	}
+	return applyPercentage(tx, &flag, plan.StartIsEnabled, plan.StartPercentage)
+}
+
+// AdvanceDue moves every running plan whose current step has elapsed to its next step. A plan
+// that fails to advance is reported without holding back the others.
+func AdvanceDue(now time.Time) error {
+	var plans []models.RolloutPlan
+	if err := config.DB.Where("status = ?", models.RolloutStatusRunning).Find(&plans).Error; err != nil {
+		return err
+	}
+
+	var failures []error
+	for i := range plans {
+		plan := &plans[i]
+		if !stepElapsed(plan, now) {
+			continue
+		}
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			return advance(tx, plan, now)
+		})
+		if errors.Is(err, ErrInvalidState) {
+			continue // Paused or aborted since it was loaded
+		}
+		if err != nil {
+			failures = append(failures, fmt.Errorf("advance rollout plan %d: %w", plan.ID, err))
+		}
+	}
+	return errors.Join(failures...)
+}
+
+func stepElapsed(plan *models.RolloutPlan, now time.Time) bool {
+	d, err := time.ParseDuration(plan.Steps[plan.CurrentStep].Duration)
+	if err != nil {
+		return false
+	}
+	return !now.Before(plan.StepStartedAt.Add(d))
+}
+
+func advance(tx *gorm.DB, plan *models.RolloutPlan, now time.Time) error {
+	if plan.CurrentStep == len(plan.Steps)-1 {
+		plan.Status = models.RolloutStatusCompleted
+		return transition(tx, plan, models.RolloutStatusRunning, map[string]interface{}{"status": plan.Status})
+	}
+
+	var flag models.FeatureFlag
+	err := tx.First(&flag, plan.FeatureFlagID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// The flag was deleted without aborting its plan; stop driving it
+		return abort(tx, plan)
+	}
+	if err != nil {
+		return err
+	}
+
+	step := plan.CurrentStep
+	plan.CurrentStep++
+	plan.StepStartedAt = now
+	res := tx.Model(&models.RolloutPlan{}).
+		Where("id = ? AND status = ? AND current_step = ?", plan.ID, models.RolloutStatusRunning, step).
+		Updates(map[string]interface{}{"current_step": plan.CurrentStep, "step_started_at": now})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidState
+	}
+	return applyPercentage(tx, &flag, true, percentagePtr(plan.Steps[plan.CurrentStep].Percentage))
+}
+
+// transition writes updates only if the plan is still in the from status, so a Pause or
+// Abort made since the plan was loaded is never overwritten; otherwise it returns ErrInvalidState
+func transition(tx *gorm.DB, plan *models.RolloutPlan, from string, updates map[string]interface{}) error {
+	res := tx.Model(&models.RolloutPlan{}).Where("id = ? AND status = ?", plan.ID, from).Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidState
+	}
+	return nil
+}
+
+func applyPercentage(tx *gorm.DB, flag *models.FeatureFlag, enabled bool, percentage *int) error {
+	flag.IsEnabled = enabled
+	flag.RolloutPercentage = percentage
+	return tx.Model(flag).Select("IsEnabled", "RolloutPercentage").Updates(flag).Error
+}
+
+// percentagePtr stores a full rollout as nil so the flag serves everyone
+func percentagePtr(p int) *int {
+	if p >= 100 {
+		return nil
+	}
+	return &p
+}
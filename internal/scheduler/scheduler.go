@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"feature-flag-service/internal/metrics"
+)
+
+// Job is a named task run on a fixed interval
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(now time.Time) error
+}
+
+// Scheduler runs background jobs until stopped
+type Scheduler struct {
+	jobs []Job
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates an empty scheduler
+func New() *Scheduler {
+	return &Scheduler{stop: make(chan struct{})}
+}
+
+// Every registers a job to run on the given interval
+func (s *Scheduler) Every(interval time.Duration, name string, run func(now time.Time) error) {
+	s.jobs = append(s.jobs, Job{Name: name, Interval: interval, Run: run})
+}
+
+// Start launches one goroutine per registered job
+func (s *Scheduler) Start() {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(job)
+	}
+	log.Printf("⏱️ Scheduler started with %d job(s)", len(s.jobs))
+}
+
+// Stop signals all jobs to exit and waits for in-flight runs to finish
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(job Job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			started, outcome := time.Now(), metrics.JobSuccess
+			if err := job.Run(now); err != nil {
+				outcome = metrics.JobFailure
+				log.Printf("❌ Scheduled job %q failed: %v", job.Name, err)
+			}
+			metrics.JobRuns.Inc(job.Name, outcome)
+			metrics.JobDuration.Observe(time.Since(started).Seconds(), job.Name)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package sdkkeys
+
+import (
+	"errors"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/degraded"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/snapshot"
+	"feature-flag-service/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// DefaultGracePeriod keeps a rotated key valid long enough for SDKs to pick up the new one
+const DefaultGracePeriod = 24 * time.Hour
+
+var (
+	ErrInvalidKey  = errors.New("invalid or expired SDK key")
+	ErrInvalidKind = errors.New("kind must be server or client")
+	ErrKeyNotFound = errors.New("SDK key not found")
+)
+
+var kindPrefixes = map[string]string{
+	models.SDKKeyServer: "ffs-srv-",
+	models.SDKKeyClient: "ffs-cli-",
+}
+
+// Create issues a new key for an environment of an organization (nil for none) and returns the
+// stored record with the plaintext key, which is never retrievable again
+func Create(environment, kind string, organization *uint) (*models.SDKKey, string, error) {
+	return create(config.DB, environment, kind, organization)
+}
+
+// Rotate issues a replacement for a key and lets the old key keep working for the grace period
+func Rotate(id uint, grace time.Duration, now time.Time) (*models.SDKKey, string, error) {
+	var key *models.SDKKey
+	var plaintext string
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var old models.SDKKey
+		if err := tx.First(&old, id).Error; err != nil {
+			return ErrKeyNotFound
+		}
+		if old.ExpiresAt != nil && !old.ExpiresAt.After(now) {
+			return ErrInvalidKey
+		}
+
+		expires := now.Add(grace)
+		if old.ExpiresAt == nil || expires.Before(*old.ExpiresAt) {
+			if err := tx.Model(&old).Update("expires_at", expires).Error; err != nil {
+				return err
+			}
+		}
+
+		var err error
+		key, plaintext, err = create(tx, old.Environment, old.Kind, old.OrganizationID)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return key, plaintext, nil
+}
+
+// Authenticate resolves a plaintext key to its record if it is still valid. While the database
+// is unavailable keys are checked against the last snapshot, so SDKs keep evaluating.
+func Authenticate(plaintext string, now time.Time) (*models.SDKKey, error) {
+	key, err := find(utils.HashSecret(plaintext))
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// find looks a key up by hash, falling back to the snapshot when the database cannot answer
+func find(hash string) (*models.SDKKey, error) {
+	if !degraded.Down(degraded.Database) {
+		var key models.SDKKey
+		err := config.DB.Where("key_hash = ?", hash).First(&key).Error
+		if err == nil || errors.Is(err, gorm.ErrRecordNotFound) {
+			return &key, err
+		}
+	}
+	if snap := snapshot.Current(); snap != nil {
+		if key := snap.SDKKey(hash); key != nil {
+			return key, nil
+		}
+	}
+	return nil, ErrInvalidKey
+}
+
+func create(tx *gorm.DB, environment, kind string, organization *uint) (*models.SDKKey, string, error) {
+	prefix, ok := kindPrefixes[kind]
+	if !ok {
+		return nil, "", ErrInvalidKind
+	}
+
+	plaintext, err := utils.NewSecret(prefix)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &models.SDKKey{
+		Environment:    environment,
+		OrganizationID: organization,
+		Kind:           kind,
+		Prefix:         plaintext[:len(prefix)+6],
+		KeyHash:        utils.HashSecret(plaintext),
+	}
+	if err := tx.Create(key).Error; err != nil {
+		return nil, "", err
+	}
+	return key, plaintext, nil
+}
@@ -0,0 +1,206 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefreshTokenTTL is how long a session lasts without being refreshed
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; the session has been revoked")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+// Start opens a session for a user and returns its first refresh token
+func Start(userID uint, userAgent, ipAddress string, now time.Time) (*models.Session, string, error) {
+	session := &models.Session{
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		ExpiresAt:  now.Add(RefreshTokenTTL),
+		LastUsedAt: now,
+	}
+
+	var refresh string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		var err error
+		refresh, err = issue(tx, session.ID)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return session, refresh, nil
+}
+
+// Refresh exchanges a refresh token for the next one in its family and extends the session.
+// Presenting a token that was already exchanged means it leaked, so the whole session is revoked.
+func Refresh(plaintext string, now time.Time) (*models.Session, string, error) {
+	var session models.Session
+	var refresh string
+	var reused bool
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var token models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", utils.HashSecret(plaintext)).First(&token).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+		if err := tx.First(&session, token.SessionID).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+		if session.RevokedAt != nil || !session.ExpiresAt.After(now) {
+			return ErrInvalidRefreshToken
+		}
+
+		if token.UsedAt != nil {
+			reused = true
+			return nil
+		}
+
+		if err := tx.Model(&token).UpdateColumn("used_at", now).Error; err != nil {
+			return err
+		}
+		session.ExpiresAt, session.LastUsedAt = now.Add(RefreshTokenTTL), now
+		if err := tx.Model(&session).UpdateColumns(map[string]interface{}{
+			"expires_at":   session.ExpiresAt,
+			"last_used_at": session.LastUsedAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		refresh, err = issue(tx, session.ID)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	if reused {
+		log.Printf("⚠️ Refresh token reuse detected for session %d of user %d; revoking the session", session.ID, session.UserID)
+		if err := Revoke(&session, now); err != nil {
+			return nil, "", err
+		}
+		return nil, "", ErrRefreshTokenReused
+	}
+	return &session, refresh, nil
+}
+
+// Revoke ends a session: its refresh tokens stop working at once, and access tokens issued for
+// it are rejected until they expire
+func Revoke(session *models.Session, now time.Time) error {
+	if session.RevokedAt == nil {
+		session.RevokedAt = &now
+		if err := config.DB.Model(session).UpdateColumn("revoked_at", now).Error; err != nil {
+			return err
+		}
+	}
+	return config.Cache.Set(sessionKey(session.ID), []byte("1"), utils.AccessTokenTTL)
+}
+
+// RevokeAll ends every active session of a user except the one given (0 to end them all)
+func RevokeAll(userID, except uint, now time.Time) error {
+	active, err := Active(userID, now)
+	if err != nil {
+		return err
+	}
+	for i := range active {
+		if active[i].ID == except {
+			continue
+		}
+		if err := Revoke(&active[i], now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokeAccessToken adds a single access token to the revocation list until it expires
+func RevokeAccessToken(claims *utils.Claims, now time.Time) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	ttl := claims.ExpiresAt.Time.Sub(now)
+	if ttl <= 0 {
+		return nil
+	}
+	return config.Cache.Set(tokenKey(claims.ID), []byte("1"), ttl)
+}
+
+// IsRevoked checks the revocation list for an access token or its session
+func IsRevoked(claims *utils.Claims) (bool, error) {
+	var keys []string
+	if claims.ID != "" {
+		keys = append(keys, tokenKey(claims.ID))
+	}
+	if claims.SessionID != 0 {
+		keys = append(keys, sessionKey(claims.SessionID))
+	}
+	if len(keys) == 0 {
+		return false, nil
+	}
+
+	return config.Cache.Exists(keys...)
+}
+
+// Active lists a user's sessions that are neither revoked nor expired
+func Active(userID uint, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	err := config.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_used_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// PruneExpired deletes sessions and their refresh tokens once they can no longer be used
+func PruneExpired(now time.Time) error {
+	expired := config.DB.Model(&models.Session{}).Select("id").
+		Where("expires_at <= ? OR revoked_at <= ?", now, now.Add(-utils.AccessTokenTTL))
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id IN (?)", expired).Delete(&models.RefreshToken{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("expires_at <= ? OR revoked_at <= ?", now, now.Add(-utils.AccessTokenTTL)).Delete(&models.Session{})
+		if result.RowsAffected > 0 {
+			log.Printf("🧹 Pruned %d expired sessions", result.RowsAffected)
+		}
+		return result.Error
+	})
+}
+
+func issue(tx *gorm.DB, sessionID uint) (string, error) {
+	plaintext, err := utils.NewSecret("")
+	if err != nil {
+		return "", err
+	}
+
+	token := models.RefreshToken{SessionID: sessionID, TokenHash: utils.HashSecret(plaintext)}
+	if err := tx.Create(&token).Error; err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+func sessionKey(id uint) string {
+	return fmt.Sprintf("revoked:session:%d", id)
+}
+
+func tokenKey(jti string) string {
+	return "revoked:jti:" + jti
+}
@@ -0,0 +1,156 @@
+// Package snapshot keeps the last known set of flags so evaluations can continue while the
+// database is unavailable. The snapshot can be persisted to a file to survive restarts.
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"feature-flag-service/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Snapshot is every non-deleted flag and SDK key at a point in time
+type Snapshot struct {
+	TakenAt time.Time            `json:"taken_at"`
+	Flags   []models.FeatureFlag `json:"flags"`
+	Keys    []Key                `json:"sdk_keys"`
+}
+
+// Key is the part of an SDK key needed to authenticate SDKs without the database
+type Key struct {
+	ID          uint       `json:"id"`
+	Environment string     `json:"environment"`
+	Kind        string     `json:"kind"`
+	KeyHash     string     `json:"key_hash"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// Find returns the flag with the given ID
+func (s *Snapshot) Find(id uint) *models.FeatureFlag {
+	for i := range s.Flags {
+		if s.Flags[i].ID == id {
+			return &s.Flags[i]
+		}
+	}
+	return nil
+}
+
+// Lookup returns a function finding flags of an organization by name, for prerequisite
+// evaluation. Names are only unique within an organization; nil looks among flags of none.
+func (s *Snapshot) Lookup(organization *uint) func(name string) *models.FeatureFlag {
+	return func(name string) *models.FeatureFlag {
+		for i := range s.Flags {
+			if s.Flags[i].Name == name && sameOrganization(s.Flags[i].OrganizationID, organization) {
+				return &s.Flags[i]
+			}
+		}
+		return nil
+	}
+}
+
+func sameOrganization(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// SDKKey returns the SDK key with the given hash
+func (s *Snapshot) SDKKey(hash string) *models.SDKKey {
+	for _, key := range s.Keys {
+		if key.KeyHash == hash {
+			return &models.SDKKey{ID: key.ID, Environment: key.Environment, Kind: key.Kind, KeyHash: key.KeyHash, ExpiresAt: key.ExpiresAt}
+		}
+	}
+	return nil
+}
+
+var (
+	mu      sync.RWMutex
+	current *Snapshot
+	file    string
+)
+
+// UseFile persists snapshots to path (SNAPSHOT_FILE); an empty path keeps them in memory only
+func UseFile(path string) {
+	mu.Lock()
+	file = path
+	mu.Unlock()
+}
+
+// Load restores the snapshot persisted by a previous run, if there is one
+func Load() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if file == "" {
+		return nil
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var loaded Snapshot
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return err
+	}
+	current = &loaded
+	return nil
+}
+
+// Current returns the latest snapshot, or nil if none has been taken or loaded
+func Current() *Snapshot {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Refresh takes a new snapshot from the database and persists it
+func Refresh(db *gorm.DB, now time.Time) error {
+	var flags []models.FeatureFlag
+	if err := db.Order("id").Find(&flags).Error; err != nil {
+		return err
+	}
+	var keys []Key
+	if err := db.Model(&models.SDKKey{}).Order("id").Find(&keys).Error; err != nil {
+		return err
+	}
+
+	taken := &Snapshot{TakenAt: now, Flags: flags, Keys: keys}
+	mu.Lock()
+	defer mu.Unlock()
+	current = taken
+	if file == "" {
+		return nil
+	}
+	return write(file, taken)
+}
+
+// write replaces the file atomically so a crash mid-write cannot leave a truncated snapshot
+func write(path string, s *Snapshot) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
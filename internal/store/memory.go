@@ -0,0 +1,253 @@
+package store
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/rollouts"
+
+	"gorm.io/gorm"
+)
+
+// Memory implements Store in process memory. It keeps only flags and users, not rollout plans or
+// the other tables, so it suits handler tests rather than running the service, and no setting
+// selects it. Deleted flags stay in the trash like soft-deleted rows, and names are unique as
+// the database's indexes make them.
+type Memory struct {
+	mu         sync.Mutex
+	flags      map[uint]models.FeatureFlag
+	users      map[uint]models.User
+	nextFlagID uint
+	nextUserID uint
+}
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{flags: make(map[uint]models.FeatureFlag), users: make(map[uint]models.User)}
+}
+
+func (m *Memory) ListFlags(filter FlagFilter) ([]models.FeatureFlag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	flags := []models.FeatureFlag{}
+	for _, flag := range m.flags {
+		if !flag.DeletedAt.Valid && (filter.State == "" || flag.State == filter.State) &&
+			(filter.Project == "" || flag.Project == filter.Project) &&
+			(filter.Environment == "" || flag.Environment == filter.Environment) &&
+			inOrganization(flag.OrganizationID, filter.Organization) {
+			flags = append(flags, flag)
+		}
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].ID < flags[j].ID })
+	return flags, nil
+}
+
+func (m *Memory) GetFlag(id uint) (*models.FeatureFlag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	flag, ok := m.flags[id]
+	if !ok || flag.DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+	return &flag, nil
+}
+
+func (m *Memory) CreateFlag(flag *models.FeatureFlag) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.flagNameTaken(flag) {
+		return ErrNameTaken
+	}
+	m.nextFlagID++
+	flag.ID = m.nextFlagID
+	flag.CreatedAt = time.Now()
+	flag.UpdatedAt = flag.CreatedAt
+	m.flags[flag.ID] = *flag
+	return nil
+}
+
+func (m *Memory) SaveFlag(flag *models.FeatureFlag) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, ok := m.flags[flag.ID]; !ok || stored.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	if m.flagNameTaken(flag) {
+		return ErrNameTaken
+	}
+	flag.UpdatedAt = time.Now()
+	m.flags[flag.ID] = *flag
+	return nil
+}
+
+func (m *Memory) SetFlagEnabled(flag *models.FeatureFlag, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.flags[flag.ID]
+	if !ok || stored.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	stored.IsEnabled = enabled
+	m.flags[flag.ID] = stored
+	flag.IsEnabled = enabled
+	return nil
+}
+
+func (m *Memory) DeleteFlag(id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if flag, ok := m.flags[id]; ok && !flag.DeletedAt.Valid {
+		flag.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		m.flags[id] = flag
+	}
+	return nil
+}
+
+// ActiveRollout never finds a plan, since Memory keeps none
+func (m *Memory) ActiveRollout(flagID uint) (*models.RolloutPlan, error) {
+	return nil, rollouts.ErrNoActivePlan
+}
+
+func (m *Memory) ListUsers(filter UserFilter) ([]models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	users := m.matchingUsers(filter)
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	if filter.Offset >= len(users) {
+		return []models.User{}, nil
+	}
+	users = users[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(users) {
+		users = users[:filter.Limit]
+	}
+	return users, nil
+}
+
+func (m *Memory) CountUsers(filter UserFilter) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return int64(len(m.matchingUsers(filter))), nil
+}
+
+func (m *Memory) GetUser(id uint) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (m *Memory) CreateUser(user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.userTaken(user) {
+		return ErrUserTaken
+	}
+	m.nextUserID++
+	user.ID = m.nextUserID
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	m.users[user.ID] = *user
+	return nil
+}
+
+// UpdateUser stores the whole user; the fields only matter to the SQL store, which writes columns
+// individually, and to the last owner check
+func (m *Memory) UpdateUser(user *models.User, fields ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[user.ID]; !ok {
+		return ErrNotFound
+	}
+	if removesOwner(user, fields) && m.lastOwner(user.ID) {
+		return ErrLastOwner
+	}
+	if m.userTaken(user) {
+		return ErrUserTaken
+	}
+	user.UpdatedAt = time.Now()
+	m.users[user.ID] = *user
+	return nil
+}
+
+func (m *Memory) DeleteUser(user *models.User, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lastOwner(user.ID) {
+		return ErrLastOwner
+	}
+	delete(m.users, user.ID)
+	return nil
+}
+
+// flagNameTaken reports whether another flag of the same organization outside the trash has the
+// flag's name; callers hold the lock
+func (m *Memory) flagNameTaken(flag *models.FeatureFlag) bool {
+	var org uint
+	if flag.OrganizationID != nil {
+		org = *flag.OrganizationID
+	}
+	for id, other := range m.flags {
+		if id != flag.ID && !other.DeletedAt.Valid && other.Name == flag.Name && inOrganization(other.OrganizationID, &org) {
+			return true
+		}
+	}
+	return false
+}
+
+// userTaken reports whether another user has the user's username or email; callers hold the lock
+func (m *Memory) userTaken(user *models.User) bool {
+	for id, other := range m.users {
+		if id != user.ID && (other.Username == user.Username || (user.Email != "" && other.Email == user.Email)) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastOwner reports whether the user is the only active owner; callers hold the lock
+func (m *Memory) lastOwner(id uint) bool {
+	disabled := false
+	owners := m.matchingUsers(UserFilter{Role: models.RoleOwner, Disabled: &disabled})
+	return len(owners) == 1 && owners[0].ID == id
+}
+
+// matchingUsers applies a filter's conditions; callers hold the lock
+func (m *Memory) matchingUsers(filter UserFilter) []models.User {
+	q := strings.ToLower(strings.TrimSpace(filter.Query))
+	users := []models.User{}
+	for _, user := range m.users {
+		if q != "" && !strings.Contains(strings.ToLower(user.Username), q) &&
+			!strings.Contains(strings.ToLower(user.Email), q) && !strings.Contains(strings.ToLower(user.DisplayName), q) {
+			continue
+		}
+		if filter.Role != "" && user.Role != filter.Role {
+			continue
+		}
+		if filter.Disabled != nil && user.Disabled != *filter.Disabled {
+			continue
+		}
+		if !inOrganization(user.OrganizationID, filter.Organization) {
+			continue
+		}
+		users = append(users, user)
+	}
+	return users
+}
@@ -0,0 +1,199 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/rollouts"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SQL implements Store with gorm, so the same code serves PostgreSQL and SQLite. Queries stick
+// to SQL both dialects understand.
+type SQL struct {
+	db *gorm.DB
+}
+
+// NewSQL wraps an open database connection
+func NewSQL(db *gorm.DB) *SQL {
+	return &SQL{db: db}
+}
+
+func (s *SQL) ListFlags(filter FlagFilter) ([]models.FeatureFlag, error) {
+	query := s.db
+	if filter.State != "" {
+		query = query.Where("state = ?", filter.State)
+	}
+	if filter.Project != "" {
+		query = query.Where("project = ?", filter.Project)
+	}
+	if filter.Environment != "" {
+		query = query.Where("environment = ?", filter.Environment)
+	}
+	query = OrganizationQuery(query, filter.Organization)
+
+	var flags []models.FeatureFlag
+	return flags, query.Find(&flags).Error
+}
+
+func (s *SQL) GetFlag(id uint) (*models.FeatureFlag, error) {
+	var flag models.FeatureFlag
+	if err := s.db.First(&flag, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &flag, nil
+}
+
+func (s *SQL) CreateFlag(flag *models.FeatureFlag) error {
+	return s.duplicate(s.db.Create(flag).Error, ErrNameTaken)
+}
+
+func (s *SQL) SaveFlag(flag *models.FeatureFlag) error {
+	return s.duplicate(s.db.Save(flag).Error, ErrNameTaken)
+}
+
+func (s *SQL) SetFlagEnabled(flag *models.FeatureFlag, enabled bool) error {
+	flag.IsEnabled = enabled
+	result := s.db.Model(flag).Update("is_enabled", enabled)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+func (s *SQL) DeleteFlag(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// A flag in the trash must not keep changing; restoring it brings back its pre-rollout state
+		if err := rollouts.AbortForFlag(tx, id); err != nil {
+			return err
+		}
+		return tx.Delete(&models.FeatureFlag{}, id).Error
+	})
+}
+
+func (s *SQL) ActiveRollout(flagID uint) (*models.RolloutPlan, error) {
+	return rollouts.ActivePlan(s.db, flagID)
+}
+
+func (s *SQL) ListUsers(filter UserFilter) ([]models.User, error) {
+	query := s.userQuery(filter).Order("username")
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	var users []models.User
+	return users, query.Find(&users).Error
+}
+
+func (s *SQL) CountUsers(filter UserFilter) (int64, error) {
+	var count int64
+	return count, s.userQuery(filter).Model(&models.User{}).Count(&count).Error
+}
+
+func (s *SQL) GetUser(id uint) (*models.User, error) {
+	var user models.User
+	if err := s.db.First(&user, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (s *SQL) CreateUser(user *models.User) error {
+	return s.duplicate(s.db.Create(user).Error, ErrUserTaken)
+}
+
+func (s *SQL) UpdateUser(user *models.User, fields ...string) error {
+	if !removesOwner(user, fields) {
+		return s.duplicate(s.db.Model(user).Select(fields).Updates(user).Error, ErrUserTaken)
+	}
+	return s.duplicate(s.db.Transaction(func(tx *gorm.DB) error {
+		if err := keepAnOwner(tx, user.ID); err != nil {
+			return err
+		}
+		return tx.Model(user).Select(fields).Updates(user).Error
+	}), ErrUserTaken)
+}
+
+func (s *SQL) DeleteUser(user *models.User, now time.Time) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := keepAnOwner(tx, user.ID); err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RoleBinding{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.AccessToken{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			UpdateColumn("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Delete(user).Error
+	})
+}
+
+// keepAnOwner refuses to take the user out of the active owners if they are the last one. The
+// owner rows stay locked until the transaction ends, so two concurrent demotions cannot both
+// see another owner.
+func keepAnOwner(tx *gorm.DB, userID uint) error {
+	var owners []uint
+	if err := tx.Model(&models.User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("role = ? AND disabled = ?", models.RoleOwner, false).Pluck("id", &owners).Error; err != nil {
+		return err
+	}
+	if len(owners) == 1 && owners[0] == userID {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func (s *SQL) userQuery(filter UserFilter) *gorm.DB {
+	query := s.db
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		// LOWER(...) LIKE rather than ILIKE, which SQLite lacks
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(q)) + "%"
+		query = query.Where(`LOWER(username) LIKE ? ESCAPE '\' OR LOWER(email) LIKE ? ESCAPE '\' OR LOWER(display_name) LIKE ? ESCAPE '\'`,
+			pattern, pattern, pattern)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.Disabled != nil {
+		query = query.Where("disabled = ?", *filter.Disabled)
+	}
+	return OrganizationQuery(query, filter.Organization)
+}
+
+// OrganizationQuery applies an organization filter, also to tables the store does not cover; nil
+// matches every organization
+func OrganizationQuery(query *gorm.DB, org *uint) *gorm.DB {
+	switch {
+	case org == nil:
+		return query
+	case *org == 0:
+		return query.Where("organization_id IS NULL")
+	default:
+		return query.Where("organization_id = ?", *org)
+	}
+}
+
+// duplicate translates a unique index violation into taken. Flags and users have no unique
+// columns besides their names and email, so that is what a violation means.
+func (s *SQL) duplicate(err, taken error) error {
+	if translator, ok := s.db.Dialector.(gorm.ErrorTranslator); ok && errors.Is(translator.Translate(err), gorm.ErrDuplicatedKey) {
+		return taken
+	}
+	return err
+}
+
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
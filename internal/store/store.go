@@ -0,0 +1,96 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"feature-flag-service/internal/models"
+)
+
+var (
+	ErrNotFound  = errors.New("record not found")
+	ErrLastOwner = errors.New("the last owner cannot be removed, disabled or demoted")
+	ErrNameTaken = errors.New("a feature flag with this name already exists")
+	ErrUserTaken = errors.New("username or email already in use")
+)
+
+// FlagFilter narrows a flag listing; zero values match everything
+type FlagFilter struct {
+	State       string
+	Project     string
+	Environment string
+
+	// Organization limits the listing to flags of one organization, 0 for flags of none
+	Organization *uint
+}
+
+// UserFilter narrows a user listing; zero values match everything
+type UserFilter struct {
+	Query    string // Case-insensitive substring of the username, email or display name
+	Role     string
+	Disabled *bool
+	Limit    int // 0 means no limit
+	Offset   int
+
+	// Organization limits the listing to members of one organization, 0 for users of none
+	Organization *uint
+}
+
+// FlagStore persists feature flags
+type FlagStore interface {
+	ListFlags(filter FlagFilter) ([]models.FeatureFlag, error)
+	GetFlag(id uint) (*models.FeatureFlag, error)
+	CreateFlag(flag *models.FeatureFlag) error                   // ErrNameTaken when the organization has a flag of that name
+	SaveFlag(flag *models.FeatureFlag) error                     // ErrNameTaken when renaming to a name the organization has
+	SetFlagEnabled(flag *models.FeatureFlag, enabled bool) error // Changes only is_enabled, leaving concurrent edits intact
+	DeleteFlag(id uint) error                                    // Moves the flag to the trash, aborting its rollout
+	ActiveRollout(flagID uint) (*models.RolloutPlan, error)      // rollouts.ErrNoActivePlan when none is running or paused
+}
+
+// UserStore persists user accounts. UpdateUser and DeleteUser return ErrLastOwner rather than
+// leave no active owner, and writes return ErrUserTaken for a username or email in use.
+type UserStore interface {
+	ListUsers(filter UserFilter) ([]models.User, error)
+	CountUsers(filter UserFilter) (int64, error)
+	GetUser(id uint) (*models.User, error)
+	CreateUser(user *models.User) error                   // ErrUserTaken when another user has the username or email
+	UpdateUser(user *models.User, fields ...string) error // Writes only the named columns
+	DeleteUser(user *models.User, now time.Time) error    // Also removes the user's role bindings and revokes their access tokens
+}
+
+// Store is what the flag and user handlers persist through. Other handlers, such as segments,
+// rollouts, the trash, evaluation, SDK, roles, service accounts and audit, still use config.DB,
+// as do the policy middleware and role grant checks.
+type Store interface {
+	FlagStore
+	UserStore
+}
+
+// inOrganization reports whether a record's organization matches a filter's
+func inOrganization(id, filter *uint) bool {
+	if filter == nil {
+		return true
+	}
+	if id == nil {
+		return *filter == 0
+	}
+	return *id == *filter
+}
+
+// removesOwner reports whether writing the named fields of user takes away an owner: a
+// demotion from owner or disabling
+func removesOwner(user *models.User, fields []string) bool {
+	for _, field := range fields {
+		switch field {
+		case "role":
+			if user.Role != models.RoleOwner {
+				return true
+			}
+		case "disabled":
+			if user.Disabled {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/app"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppStopsComponentsInReverse(t *testing.T) {
+	var events []string
+	component := func(name string, startErr error) app.Component {
+		return app.Component{
+			Name:  name,
+			Start: func(context.Context) error { events = append(events, "start "+name); return startErr },
+			Stop:  func(context.Context) error { events = append(events, "stop "+name); return nil },
+		}
+	}
+
+	a := app.New(0, time.Second)
+	a.Register(component("storage", nil))
+	a.Register(component("scheduler", nil))
+	a.Register(component("http", errors.New("address in use")))
+
+	// A failed start stops what was already running
+	assert.Error(t, a.Start(context.Background()))
+	assert.False(t, a.Ready())
+	assert.Equal(t, []string{"start storage", "start scheduler", "start http", "stop scheduler", "stop storage"}, events)
+}
+
+func TestAppDrainsRequestsOnShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	a := app.New(0, 5*time.Second)
+	a.Serve("http", &http.Server{Addr: addr, Handler: handler})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan error)
+	go func() { finished <- a.Run(ctx) }()
+	assert.Eventually(t, a.Ready, time.Second, 10*time.Millisecond)
+
+	// Shut down while a request is in flight; it still completes
+	response := make(chan string)
+	go func() {
+		resp, err := http.Get("http://" + addr)
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		response <- string(body)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	assert.Equal(t, "done", <-response)
+	assert.NoError(t, <-finished)
+	assert.False(t, a.Ready())
+}
@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/invites"
+	"feature-flag-service/internal/loginguard"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/passwords"
+	"feature-flag-service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessTokensAreShortLivedAndRevocable(t *testing.T) {
+	first, err := testTokens.GenerateJWT("alice", models.RoleEditor, nil, nil, 42)
+	assert.NoError(t, err)
+	second, err := testTokens.GenerateJWT("alice", models.RoleEditor, nil, nil, 42)
+	assert.NoError(t, err)
+
+	claims, err := testTokens.ValidateJWT(first)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", claims.Username)
+	assert.Equal(t, "alice", claims.Subject)
+	assert.Equal(t, uint(42), claims.SessionID)
+	assert.NotEmpty(t, claims.ID, "each token needs a jti so it can be revoked on its own")
+	assert.WithinDuration(t, time.Now().Add(utils.AccessTokenTTL), claims.ExpiresAt.Time, 5*time.Second)
+
+	other, err := testTokens.ValidateJWT(second)
+	assert.NoError(t, err)
+	assert.NotEqual(t, claims.ID, other.ID)
+}
+
+func TestValidateJWTRejectsSymmetricAndUnsignedTokens(t *testing.T) {
+	claims := jwt.MapClaims{
+		"username": "mallory", "sub": "mallory", "role": models.RoleOwner, "jti": "x",
+		"iss": "feature-flag-service", "aud": "feature-flag-service-api",
+		"iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix(),
+	}
+
+	hs256, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("guessed-secret"))
+	assert.NoError(t, err)
+	_, err = testTokens.ValidateJWT(hs256)
+	assert.Error(t, err)
+
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.NoError(t, err)
+	_, err = testTokens.ValidateJWT(none)
+	assert.Error(t, err)
+}
+
+func TestKeyRotationKeepsOldTokensValid(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "2024-12", rsaKeyPEM(t))
+	writeKey(t, dir, "2025-01", ed25519KeyPEM(t))
+
+	before, err := utils.LoadKeySet(dir, "2024-12")
+	assert.NoError(t, err)
+	after, err := utils.LoadKeySet(dir, "2025-01")
+	assert.NoError(t, err)
+
+	claims := jwt.RegisteredClaims{Subject: "alice", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+	old, err := before.Sign(claims)
+	assert.NoError(t, err)
+	current, err := after.Sign(claims)
+	assert.NoError(t, err)
+
+	// Tokens signed before the rotation still verify, and the kid picks the right key
+	_, err = after.Parse(old, &jwt.RegisteredClaims{})
+	assert.NoError(t, err)
+	token, err := after.Parse(current, &jwt.RegisteredClaims{})
+	assert.NoError(t, err)
+	assert.Equal(t, "2025-01", token.Header["kid"])
+	assert.Equal(t, "EdDSA", token.Method.Alg())
+
+	jwks := after.JWKS()
+	assert.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "RSA", jwks.Keys[0].Kty)
+	assert.Equal(t, "OKP", jwks.Keys[1].Kty)
+
+	// Once the old key is removed its tokens are rejected
+	assert.NoError(t, os.Remove(filepath.Join(dir, "2024-12.pem")))
+	retired, err := utils.LoadKeySet(dir, "2025-01")
+	assert.NoError(t, err)
+	_, err = retired.Parse(old, &jwt.RegisteredClaims{})
+	assert.ErrorIs(t, err, utils.ErrUnknownKey)
+}
+
+func writeKey(t *testing.T, dir, kid string, data []byte) {
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600))
+}
+
+func rsaKeyPEM(t *testing.T) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+func ed25519KeyPEM(t *testing.T) []byte {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestTOTPMatchesRFC6238Vectors(t *testing.T) {
+	// RFC 6238 appendix B, SHA1 secret "12345678901234567890", truncated to 6 digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	code, err := utils.TOTPCode(secret, time.Unix(59, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "287082", code)
+
+	code, err = utils.TOTPCode(secret, time.Unix(1111111109, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "081804", code)
+}
+
+func TestValidateTOTPAllowsOneStepOfDrift(t *testing.T) {
+	secret, err := utils.GenerateTOTPSecret()
+	assert.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+
+	code, _ := utils.TOTPCode(secret, now.Add(-30*time.Second))
+	step, ok := utils.ValidateTOTP(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Unix()/30-1, step)
+
+	stale, _ := utils.TOTPCode(secret, now.Add(-2*time.Minute))
+	_, ok = utils.ValidateTOTP(secret, stale, now)
+	assert.False(t, ok)
+
+	_, ok = utils.ValidateTOTP(secret, "12345", now)
+	assert.False(t, ok)
+
+	uri := utils.TOTPURI("Feature Flag Service", "alice", secret)
+	assert.Contains(t, uri, "otpauth://totp/Feature%20Flag%20Service:alice?")
+	assert.Contains(t, uri, "secret="+secret)
+}
+
+func TestLoginBackoffDoublesAfterThreshold(t *testing.T) {
+	assert.Equal(t, time.Duration(0), loginguard.Backoff(4, 5))
+	assert.Equal(t, loginguard.BaseLockout, loginguard.Backoff(5, 5))
+	assert.Equal(t, 2*loginguard.BaseLockout, loginguard.Backoff(6, 5))
+	assert.Equal(t, 8*loginguard.BaseLockout, loginguard.Backoff(8, 5))
+	assert.Equal(t, loginguard.MaxLockout, loginguard.Backoff(500, 5))
+}
+
+func TestRegistrationIsClosedByDefault(t *testing.T) {
+	useSQLite(t)
+	gin.SetMode(gin.TestMode)
+	closed, open := gin.New(), gin.New()
+	closed.POST("/register", newAuthHandler(t, false).Register)
+	open.POST("/register", newAuthHandler(t, true).Register)
+
+	body := `{"username": "alice", "password": "plum-tractor-violet-19"}`
+	assert.Equal(t, http.StatusForbidden, send(closed, http.MethodPost, "/register", body).Code)
+	assert.Equal(t, http.StatusCreated, send(open, http.MethodPost, "/register", body).Code)
+}
+
+func TestInvitesMustExpire(t *testing.T) {
+	invite := models.Invite{OrganizationID: 1, Role: models.RoleEditor}
+	_, err := invites.Create(&invite, 0, time.Now())
+	assert.ErrorIs(t, err, invites.ErrInvalidTTL)
+	_, err = invites.Create(&invite, invites.MaxTTL+time.Hour, time.Now())
+	assert.ErrorIs(t, err, invites.ErrInvalidTTL)
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "breached.txt")
+	// One plaintext entry and the SHA-1 of "correct horse battery staple", as in a Have I Been Pwned download
+	assert.NoError(t, os.WriteFile(list, []byte("Password123456\nABF7AAD6438836DBE526AA231ABDE2D0EEF74D42:3\n"), 0o600))
+
+	policy := &passwords.Policy{MinLength: 12}
+	assert.NoError(t, policy.LoadBreached(list))
+
+	assert.ErrorIs(t, policy.Check("alice", ""), passwords.ErrTooShort)
+	assert.ErrorIs(t, policy.Check("alice", "short"), passwords.ErrTooShort)
+	assert.ErrorIs(t, policy.Check("alice", strings.Repeat("x", 73)), passwords.ErrTooLong)
+	assert.ErrorIs(t, policy.Check("alice", "my-name-is-ALICE!"), passwords.ErrContainsUsername)
+	assert.ErrorIs(t, policy.Check("alice", "password123456"), passwords.ErrBreached)
+	assert.ErrorIs(t, policy.Check("alice", "correct horse battery staple"), passwords.ErrBreached)
+	assert.NoError(t, policy.Check("alice", "plum-tractor-violet-19"))
+	assert.True(t, passwords.IsRejected(policy.Check("alice", "short")))
+}
@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/degraded"
+	"feature-flag-service/internal/handlers"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/migrations"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/policy"
+	"feature-flag-service/internal/snapshot"
+	"feature-flag-service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestSnapshotServesEvaluationsWhileDatabaseIsDown(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	assert.NoError(t, err)
+	assert.NoError(t, migrations.Startup(db, true))
+	assert.NoError(t, db.Create(&models.FeatureFlag{Name: "new_checkout", IsEnabled: true, State: models.FlagStateActive}).Error)
+	assert.NoError(t, db.Create(&models.FeatureFlag{Name: "old_banner", State: models.FlagStateArchived}).Error)
+	assert.NoError(t, db.Create(&models.SDKKey{Environment: "production", Kind: models.SDKKeyServer, Prefix: "ffs-srv-abcdef", KeyHash: utils.HashSecret("ffs-srv-test")}).Error)
+
+	// The snapshot written to disk is restored by the next process
+	file := filepath.Join(t.TempDir(), "snapshot.json")
+	snapshot.UseFile(file)
+	assert.NoError(t, snapshot.Refresh(db, time.Now()))
+	snapshot.UseFile(file)
+	assert.NoError(t, snapshot.Load())
+	assert.Len(t, snapshot.Current().Flags, 2)
+
+	degraded.MarkDown(degraded.Database, errors.New("connection refused"), time.Now())
+	defer degraded.MarkUp(degraded.Database)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RejectWritesWhenDegraded())
+	r.GET("/sdk/flags", middleware.SDKKeyMiddleware(false), handlers.GetSDKFlags)
+	r.POST("/flags", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/sdk/flags", nil)
+	req.Header.Set("Authorization", "ffs-srv-test")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get(handlers.SnapshotHeader))
+	assert.Contains(t, w.Body.String(), "new_checkout")
+	assert.NotContains(t, w.Body.String(), "old_banner")
+
+	// Writes are refused with a clear error until the database is back
+	w = send(r, http.MethodPost, "/flags", `{}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "database unavailable")
+
+	degraded.MarkUp(degraded.Database)
+	assert.Equal(t, http.StatusCreated, send(r, http.MethodPost, "/flags", `{}`).Code)
+
+	// Writes only need the database, so a Redis outage alone does not refuse them
+	degraded.MarkDown(degraded.Redis, errors.New("connection refused"), time.Now())
+	defer degraded.MarkUp(degraded.Redis)
+	assert.Equal(t, http.StatusCreated, send(r, http.MethodPost, "/flags", `{}`).Code)
+}
+
+func TestFlagRoutesAuthorizeAgainstTheSnapshotWhileDatabaseIsDown(t *testing.T) {
+	db := useSQLite(t)
+	flag := models.FeatureFlag{Name: "billing_retry", Environment: "production", IsEnabled: true, State: models.FlagStateActive}
+	db.Create(&flag)
+	snapshot.UseFile("")
+	assert.NoError(t, snapshot.Refresh(db, time.Now()))
+
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	assert.NoError(t, sqlDB.Close())
+	degraded.MarkDown(degraded.Database, errors.New("connection refused"), time.Now())
+	defer degraded.MarkUp(degraded.Database)
+
+	// Only allowed to read staging flags, so naming staging must not reach a production flag
+	r := policyRouter(&utils.Claims{Username: "alice", Scopes: []policy.Grant{{Role: models.RoleViewer, Environment: "staging"}}})
+	r.GET("/flags/:id/evaluate", middleware.Authorize(policy.FlagRead), handlers.EvaluateFeatureFlag)
+	evaluate := func(id uint, environment string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/flags/%d/evaluate", id), nil)
+		req.Header.Set("X-Environment", environment)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusBadRequest, evaluate(flag.ID, "staging"))
+	assert.Equal(t, http.StatusForbidden, evaluate(flag.ID, ""))
+	assert.Equal(t, http.StatusServiceUnavailable, evaluate(flag.ID+1, "staging"), "a flag missing from the snapshot is refused")
+}
+
+func TestDegradedWatchTracksOutages(t *testing.T) {
+	pingErr := errors.New("connection refused")
+	var recovered []string
+	watch := degraded.Watch(map[string]func(context.Context) error{
+		degraded.Redis: func(context.Context) error { return pingErr },
+	}, func(dependency string) { recovered = append(recovered, dependency) })
+
+	started := time.Now()
+	assert.NoError(t, watch(started))
+	assert.NoError(t, watch(started.Add(5*time.Second)))
+	assert.True(t, degraded.Down(degraded.Redis))
+	assert.Equal(t, "redis unavailable", degraded.Summary())
+	assert.Equal(t, started, degraded.Outages()[0].Since)
+
+	pingErr = nil
+	assert.NoError(t, watch(started.Add(10*time.Second)))
+	assert.False(t, degraded.Active())
+	assert.Equal(t, []string{degraded.Redis}, recovered)
+}
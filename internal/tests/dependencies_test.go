@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"feature-flag-service/internal/dependencies"
+	"feature-flag-service/internal/handlers"
+	"feature-flag-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func requires(name string) []models.Prerequisite {
+	return []models.Prerequisite{{Flag: name, Variation: models.VariationTreatment}}
+}
+
+func TestValidateRejectsCycles(t *testing.T) {
+	existing := []models.FeatureFlag{
+		{ID: 1, Name: "a"},
+		{ID: 2, Name: "b", Prerequisites: requires("a")},
+		{ID: 3, Name: "c", Prerequisites: requires("b")},
+	}
+
+	// a -> c -> b -> a
+	candidate := &models.FeatureFlag{ID: 1, Name: "a", Prerequisites: requires("c")}
+	err := dependencies.Validate(candidate, existing)
+	assert.ErrorIs(t, err, dependencies.ErrCycle)
+	assert.Contains(t, err.Error(), "a -> c -> b -> a")
+
+	// A new flag depending on c is fine
+	assert.NoError(t, dependencies.Validate(&models.FeatureFlag{Name: "d", Prerequisites: requires("c")}, existing))
+}
+
+func TestValidateRejectsUnknownPrerequisites(t *testing.T) {
+	candidate := &models.FeatureFlag{Name: "d", Prerequisites: requires("missing")}
+	assert.ErrorIs(t, dependencies.Validate(candidate, nil), dependencies.ErrInvalidPrerequisite)
+}
+
+func TestGraphDependentsAndDOT(t *testing.T) {
+	flags := []models.FeatureFlag{
+		{Name: "a"},
+		{Name: "b", Prerequisites: requires("a")},
+		{Name: "c", Prerequisites: requires("a")},
+	}
+
+	assert.Equal(t, []string{"b", "c"}, dependencies.Dependents("a", flags))
+	assert.Empty(t, dependencies.Dependents("b", flags))
+
+	dot := dependencies.Build(flags).DOT()
+	assert.Contains(t, dot, `"b" -> "a" [label="treatment"];`)
+}
+
+func TestDependencyGraphFiltersByProject(t *testing.T) {
+	db := useSQLite(t)
+	db.Create(&models.FeatureFlag{Name: "a", Project: "checkout", State: models.FlagStateActive})
+	db.Create(&models.FeatureFlag{Name: "b", Project: "checkout", State: models.FlagStateActive, Prerequisites: requires("a")})
+	db.Create(&models.FeatureFlag{Name: "c", Project: "search", State: models.FlagStateActive, Prerequisites: requires("a")})
+
+	r := newRouter("alice", models.RoleViewer)
+	r.GET("/dependency-graph", handlers.GetDependencyGraph)
+
+	w := send(r, "GET", "/dependency-graph?project=checkout", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var graph dependencies.Graph
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &graph))
+	assert.Equal(t, []string{"a", "b"}, graph.Nodes)
+	assert.Equal(t, []dependencies.Edge{{From: "b", To: "a", Variation: models.VariationTreatment}}, graph.Edges)
+
+	w = send(r, "GET", "/dependency-graph", "")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &graph))
+	assert.Equal(t, []string{"a", "b", "c"}, graph.Nodes)
+}
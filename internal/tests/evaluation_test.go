@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/evaluation"
+	"feature-flag-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluateActivationWindow(t *testing.T) {
+	from := time.Date(2024, 11, 29, 0, 0, 0, 0, time.UTC)
+	until := from.Add(72 * time.Hour)
+	flag := &models.FeatureFlag{Name: "black_friday_banner", IsEnabled: true, ActiveFrom: &from, ActiveUntil: &until}
+
+	before := evaluation.Evaluate(flag, evaluation.Context{Now: from.Add(-time.Minute)})
+	assert.False(t, before.Enabled)
+	assert.Equal(t, evaluation.ReasonOutsideWindow, before.Reason)
+
+	during := evaluation.Evaluate(flag, evaluation.Context{Now: from.Add(time.Hour)})
+	assert.True(t, during.Enabled)
+	assert.Equal(t, evaluation.ReasonOn, during.Reason)
+
+	after := evaluation.Evaluate(flag, evaluation.Context{Now: until})
+	assert.False(t, after.Enabled)
+	assert.Equal(t, evaluation.ReasonOutsideWindow, after.Reason)
+}
+
+func TestEvaluateRecurringScheduleInTimeZone(t *testing.T) {
+	flag := &models.FeatureFlag{Name: "live_support", IsEnabled: true, Schedule: "* 9-16 * * 1-5", TimeZone: "America/New_York"}
+	assert.NoError(t, evaluation.ValidateConditions(flag))
+
+	// Wednesday 10:30 in New York (EDT) is 14:30 UTC
+	open := evaluation.Evaluate(flag, evaluation.Context{Now: time.Date(2024, 3, 13, 14, 30, 0, 0, time.UTC)})
+	assert.True(t, open.Enabled)
+
+	// Wednesday 17:00 in New York
+	closed := evaluation.Evaluate(flag, evaluation.Context{Now: time.Date(2024, 3, 13, 21, 0, 0, 0, time.UTC)})
+	assert.False(t, closed.Enabled)
+	assert.Equal(t, evaluation.ReasonOutsideSchedule, closed.Reason)
+
+	// Saturday 12:00 in New York
+	weekend := evaluation.Evaluate(flag, evaluation.Context{Now: time.Date(2024, 3, 16, 16, 0, 0, 0, time.UTC)})
+	assert.False(t, weekend.Enabled)
+	assert.Equal(t, evaluation.ReasonOutsideSchedule, weekend.Reason)
+}
+
+func TestEvaluateDisabledFlagReportsOff(t *testing.T) {
+	flag := &models.FeatureFlag{Name: "disabled", IsEnabled: false, Schedule: "* * * * *"}
+
+	result := evaluation.Evaluate(flag, evaluation.Context{Now: time.Now()})
+	assert.False(t, result.Enabled)
+	assert.Equal(t, evaluation.ReasonOff, result.Reason)
+	assert.Equal(t, models.VariationControl, result.Variation)
+}
+
+func TestValidateConditionsRejectsBadSchedules(t *testing.T) {
+	assert.Error(t, evaluation.ValidateConditions(&models.FeatureFlag{Schedule: "* 9-17 * *"}))
+	assert.Error(t, evaluation.ValidateConditions(&models.FeatureFlag{Schedule: "* 25 * * *"}))
+	assert.Error(t, evaluation.ValidateConditions(&models.FeatureFlag{Schedule: "* * * * *", TimeZone: "Mars/Olympus"}))
+	assert.NoError(t, evaluation.ValidateConditions(&models.FeatureFlag{Schedule: "*/15 0-6,22-23 * * 0,6-7"}))
+}
+
+func TestEvaluatePrerequisitesBeforeOwnRules(t *testing.T) {
+	flags := map[string]*models.FeatureFlag{
+		"new_checkout": {Name: "new_checkout", IsEnabled: false},
+	}
+	child := &models.FeatureFlag{
+		Name:          "one_click_pay",
+		IsEnabled:     true,
+		Prerequisites: []models.Prerequisite{{Flag: "new_checkout", Variation: models.VariationTreatment}},
+	}
+	ctx := evaluation.Context{Now: time.Now(), Lookup: func(name string) *models.FeatureFlag { return flags[name] }}
+
+	blocked := evaluation.Evaluate(child, ctx)
+	assert.False(t, blocked.Enabled)
+	assert.Equal(t, evaluation.ReasonPrerequisite, blocked.Reason)
+	assert.Equal(t, "new_checkout", blocked.Prerequisite)
+
+	flags["new_checkout"].IsEnabled = true
+	assert.True(t, evaluation.Evaluate(child, ctx).Enabled)
+}
@@ -15,7 +15,7 @@ func TestMain(m *testing.M) {
 	os.Setenv("TEST_MODE", "true")
 
 	// Initialize database with mock
-	config.ConnectDB()
+	config.ConnectDB(config.DatabaseSettings{})
 
 	// Run tests
 	code := m.Run()
@@ -29,11 +29,12 @@ func TestCreateFeatureFlag(t *testing.T) {
 		Name:        "test_feature",
 		Description: "A test feature",
 		IsEnabled:   true,
+		State:       models.FlagStateActive,
 	}
 
 	// Expecting a query (not Exec) because GORM auto-appends RETURNING "id" in PostgreSQL
-	config.Mock.ExpectQuery(`INSERT INTO "feature_flags" \("name","description","is_enabled","created_at","updated_at","deleted_at"\) VALUES \(\$1,\$2,\$3,\$4,\$5,\$6\) RETURNING "id"`).
-		WithArgs(flag.Name, flag.Description, flag.IsEnabled, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+	config.Mock.ExpectQuery(`INSERT INTO "feature_flags" \("name","project","environment","organization_id","description","is_enabled","state","tags","client_visible","rollout_percentage","active_from","active_until","schedule","time_zone","prerequisites","last_evaluated_at","last_control_at","last_treatment_at","created_at","updated_at","deleted_at"\) VALUES \(\$1,\$2,\$3,\$4,\$5,\$6,\$7,\$8,\$9,\$10,\$11,\$12,\$13,\$14,\$15,\$16,\$17,\$18,\$19,\$20,\$21\) RETURNING "id"`).
+		WithArgs(flag.Name, "", "", nil, flag.Description, flag.IsEnabled, flag.State, sqlmock.AnyArg(), false, nil, nil, nil, "", "", sqlmock.AnyArg(), nil, nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
 	err := config.DB.Create(&flag).Error
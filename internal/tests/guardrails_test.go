@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/guardrails"
+	"feature-flag-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// syntheticStream emits one event per second for each variation with the given values
+func syntheticStream(start time.Time, metric string, control, treatment []float64) []guardrails.Event {
+	var events []guardrails.Event
+	for i, v := range control {
+		events = append(events, guardrails.Event{Variation: models.VariationControl, Metric: metric, Value: v, At: start.Add(time.Duration(i) * time.Second)})
+	}
+	for i, v := range treatment {
+		events = append(events, guardrails.Event{Variation: models.VariationTreatment, Metric: metric, Value: v, At: start.Add(time.Duration(i) * time.Second)})
+	}
+	return events
+}
+
+func TestReplayDetectsTreatmentBreach(t *testing.T) {
+	rules := []models.Guardrail{{Metric: "error_count", Aggregation: models.AggregationSum, Threshold: 5, Window: "1m", MinSamples: 3}}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Treatment errors accumulate past the threshold on the 6th event
+	events := syntheticStream(start, "error_count", []float64{9, 9, 9}, []float64{0, 1, 1, 1, 1, 2, 3})
+
+	breach := guardrails.Replay(rules, events)
+	if assert.NotNil(t, breach) {
+		assert.Equal(t, 6.0, breach.Observed)
+		assert.Equal(t, 6, breach.Samples)
+		assert.Equal(t, start.Add(5*time.Second), breach.At)
+		assert.Contains(t, breach.Reason(), "error_count")
+	}
+}
+
+func TestReplayIgnoresControlAndExpiredEvents(t *testing.T) {
+	rules := []models.Guardrail{{Metric: "latency_ms", Aggregation: models.AggregationMean, Threshold: 200, Window: "10s", MinSamples: 2}}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Control latency is high, and the single slow treatment sample falls out of the window
+	events := syntheticStream(start, "latency_ms", []float64{900, 900, 900}, []float64{1000})
+	events = append(events,
+		guardrails.Event{Variation: models.VariationTreatment, Metric: "latency_ms", Value: 100, At: start.Add(30 * time.Second)},
+		guardrails.Event{Variation: models.VariationTreatment, Metric: "latency_ms", Value: 120, At: start.Add(31 * time.Second)},
+	)
+
+	assert.Nil(t, guardrails.Replay(rules, events))
+}
+
+func TestReplayRespectsMinSamples(t *testing.T) {
+	rules := []models.Guardrail{{Metric: "checkout_failures", Aggregation: models.AggregationMax, Threshold: 1, Window: "5m", MinSamples: 10}}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	events := syntheticStream(start, "checkout_failures", nil, []float64{5, 5, 5})
+
+	assert.Nil(t, guardrails.Replay(rules, events))
+}
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/handlers"
+	"feature-flag-service/internal/mfa"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/migrations"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/store"
+	"feature-flag-service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newRouter serves handlers as the given caller, skipping token authentication
+func newRouter(username, role string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(middleware.ClaimsKey, &utils.Claims{Username: username, Role: role})
+		c.Next()
+	})
+	return r
+}
+
+func send(r *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+// useSQLite points config.DB at a fresh, migrated SQLite database for the rest of the test
+func useSQLite(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrations.Startup(db, true); err != nil {
+		t.Fatal(err)
+	}
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() { config.DB = previous })
+	return db
+}
+
+// testTokens signs and validates access tokens with the default JWT settings
+var testTokens = utils.NewTokenIssuer("feature-flag-service", "feature-flag-service-api")
+
+// newAuthHandler creates an AuthHandler with the default token settings and a fresh MFA key
+func newAuthHandler(t *testing.T, openRegistration bool) *handlers.AuthHandler {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := mfa.NewAuthenticator("Feature Flag Service", base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handlers.NewAuthHandler(testTokens, authenticator, openRegistration)
+}
+
+// useMemoryCache points config.Cache at an empty in-process cache for the rest of the test
+func useMemoryCache(t *testing.T) {
+	previous := config.Cache
+	config.Cache = config.InstrumentedCache{CacheStore: config.NewMemoryCache()}
+	t.Cleanup(func() { config.Cache = previous })
+}
+
+func TestFlagHandlerWithMemoryStore(t *testing.T) {
+	flags := handlers.NewFlagHandler(store.NewMemory())
+	r := newRouter("alice", models.RoleEditor)
+	r.GET("/flags", flags.GetFeatureFlags)
+	r.POST("/flags", flags.CreateFeatureFlag)
+	r.PATCH("/flags/:id/toggle", flags.ToggleFeatureFlag)
+	r.DELETE("/flags/:id", flags.DeleteFeatureFlag)
+
+	assert.Equal(t, http.StatusCreated, send(r, "POST", "/flags", `{"name": "new_checkout"}`).Code)
+	assert.Equal(t, http.StatusCreated, send(r, "POST", "/flags", `{"name": "express_pay", "prerequisites": [{"flag": "new_checkout", "variation": "treatment"}]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(r, "POST", "/flags", `{"name": "orphan", "prerequisites": [{"flag": "missing", "variation": "treatment"}]}`).Code)
+
+	w := send(r, "PATCH", "/flags/1/toggle", `{"is_enabled": true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"is_enabled":true`)
+	assert.Equal(t, http.StatusNotFound, send(r, "PATCH", "/flags/9/toggle", `{"is_enabled": true}`).Code)
+
+	// new_checkout is a prerequisite of express_pay, so it cannot be deleted first
+	assert.Equal(t, http.StatusConflict, send(r, "DELETE", "/flags/1", "").Code)
+	assert.Equal(t, http.StatusOK, send(r, "DELETE", "/flags/2", "").Code)
+	assert.Equal(t, http.StatusOK, send(r, "DELETE", "/flags/1", "").Code)
+
+	var listed []models.FeatureFlag
+	assert.NoError(t, json.Unmarshal(send(r, "GET", "/flags", "").Body.Bytes(), &listed))
+	assert.Empty(t, listed)
+}
+
+func TestUserHandlerKeepsLastOwner(t *testing.T) {
+	users := store.NewMemory()
+	root := models.User{Username: "root", Role: models.RoleOwner, Email: "root@example.com"}
+	assert.NoError(t, users.CreateUser(&root))
+	assert.NoError(t, users.CreateUser(&models.User{Username: "bob", Role: models.RoleViewer, DisplayName: "Bob Example"}))
+
+	h := handlers.NewUserHandler(users)
+	r := newRouter("alice", models.RoleOwner)
+	r.GET("/users", h.GetUsers)
+	r.POST("/users/:id/disable", h.DisableUser)
+	r.PUT("/users/:id/role", h.UpdateUserRole)
+
+	var found []models.User
+	assert.NoError(t, json.Unmarshal(send(r, "GET", "/users?q=EXAMPLE&role=viewer", "").Body.Bytes(), &found))
+	assert.Len(t, found, 1)
+	assert.Equal(t, "bob", found[0].Username)
+	assert.Equal(t, http.StatusBadRequest, send(r, "GET", "/users?limit=501", "").Code)
+
+	assert.Equal(t, http.StatusBadRequest, send(r, "POST", "/users/1/disable", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send(r, "PUT", "/users/1/role", `{"role": "admin"}`).Code)
+
+	// With a second owner the first may be demoted
+	assert.Equal(t, http.StatusOK, send(r, "PUT", "/users/2/role", `{"role": "owner"}`).Code)
+	assert.Equal(t, http.StatusOK, send(r, "PUT", "/users/1/role", `{"role": "admin"}`).Code)
+	demoted, err := users.GetUser(root.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.RoleAdmin, demoted.Role)
+}
@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/handlers"
+	"feature-flag-service/internal/health"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthReport(t *testing.T) {
+	var redisErr error
+	checks := health.NewChecker()
+	checks.Timeout = 50 * time.Millisecond
+	checks.Add("database", true, func(context.Context) error { return nil })
+	checks.Add("redis", true, func(context.Context) error { return redisErr })
+	checks.Add("schema", true, func(context.Context) error { return health.Degraded(errors.New("schema is newer")) })
+	checks.Add("slow", false, func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() })
+
+	// Degraded and non-critical failures keep the service ready
+	report := checks.Run(context.Background())
+	assert.True(t, report.Ready)
+	assert.Equal(t, health.StatusDegraded, report.Status)
+	assert.Equal(t, health.StatusDegraded, report.Checks[2].Status)
+	assert.Equal(t, health.StatusFailing, report.Checks[3].Status)
+
+	redisErr = errors.New("connection refused")
+	report = checks.Run(context.Background())
+	assert.False(t, report.Ready)
+	assert.Equal(t, health.StatusFailing, report.Status)
+
+	// The last error is still reported after recovery
+	redisErr = nil
+	report = checks.Run(context.Background())
+	assert.Equal(t, health.StatusOK, report.Checks[1].Status)
+	assert.Equal(t, "connection refused", report.Checks[1].LastError)
+	assert.NotNil(t, report.Checks[1].LastErrorAt)
+}
+
+func TestReadinessFailsWhileShuttingDown(t *testing.T) {
+	running := true
+	probes := handlers.NewHealthHandler(health.NewChecker(), func() bool { return running })
+	r := newRouter("", "")
+	r.GET("/livez", probes.Livez)
+	r.GET("/readyz", probes.Readyz)
+
+	assert.Equal(t, http.StatusOK, send(r, http.MethodGet, "/readyz", "").Code)
+	running = false
+	assert.Equal(t, http.StatusServiceUnavailable, send(r, http.MethodGet, "/readyz", "").Code)
+	assert.Equal(t, http.StatusOK, send(r, http.MethodGet, "/livez", "").Code)
+}
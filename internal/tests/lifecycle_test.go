@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/handlers"
+	"feature-flag-service/internal/lifecycle"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/store"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaleCandidates(t *testing.T) {
+	now := time.Now()
+	old, recent := now.AddDate(0, 0, -60), now.AddDate(0, 0, -1)
+	flags := []models.FeatureFlag{
+		{Name: "never", State: models.FlagStateActive, CreatedAt: old},
+		{Name: "quiet", State: models.FlagStateActive, CreatedAt: old, LastEvaluatedAt: &old, LastControlAt: &old},
+		{Name: "all_on", State: models.FlagStateActive, CreatedAt: old, LastEvaluatedAt: &recent, LastTreatmentAt: &recent},
+		{Name: "mixed", State: models.FlagStateActive, CreatedAt: old, LastEvaluatedAt: &recent, LastControlAt: &recent, LastTreatmentAt: &recent},
+		{Name: "young", State: models.FlagStateActive, CreatedAt: recent},
+		{Name: "draft", State: models.FlagStateDraft, CreatedAt: old},
+	}
+
+	candidates := lifecycle.StaleCandidates(flags, 30, now)
+	reasons := map[string]string{}
+	for _, c := range candidates {
+		reasons[c.Flag.Name] = c.Reason
+	}
+	assert.Equal(t, map[string]string{
+		"never":  lifecycle.ReasonNeverEvaluated,
+		"quiet":  lifecycle.ReasonNotEvaluated,
+		"all_on": lifecycle.ReasonSingleVariation,
+	}, reasons)
+}
+
+func TestRecordEvaluation(t *testing.T) {
+	db := useSQLite(t)
+	flag := models.FeatureFlag{Name: "new_checkout", State: models.FlagStateActive}
+	assert.NoError(t, db.Create(&flag).Error)
+	now := time.Now()
+
+	assert.NoError(t, lifecycle.RecordEvaluation(&flag, models.VariationTreatment, now))
+	var stored models.FeatureFlag
+	assert.NoError(t, db.First(&stored, flag.ID).Error)
+	assert.NotNil(t, stored.LastEvaluatedAt)
+	assert.NotNil(t, stored.LastTreatmentAt)
+	assert.Nil(t, stored.LastControlAt)
+	assert.Equal(t, flag.UpdatedAt.Unix(), stored.UpdatedAt.Unix(), "evaluations must not look like edits")
+}
+
+func TestUpdateCannotSetServiceFields(t *testing.T) {
+	flags := handlers.NewFlagHandler(store.NewMemory())
+	r := newRouter("alice", models.RoleEditor)
+	r.POST("/flags", flags.CreateFeatureFlag)
+	r.PUT("/flags/:id", flags.UpdateFeatureFlag)
+
+	assert.Equal(t, http.StatusCreated, send(r, "POST", "/flags", `{"name": "new_checkout", "description": "v1", "last_evaluated_at": "2020-01-01T00:00:00Z"}`).Code)
+	w := send(r, "PUT", "/flags/1", `{"id": 7, "description": "v2", "last_treatment_at": "2020-01-01T00:00:00Z"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":1`)
+	assert.Contains(t, w.Body.String(), `"name":"new_checkout"`)
+	assert.Contains(t, w.Body.String(), `"description":"v2"`)
+	assert.NotContains(t, w.Body.String(), "last_")
+}
+
+func TestStoresMoveDeletedFlagsToTheTrash(t *testing.T) {
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			flags := open(t)
+			flag := models.FeatureFlag{Name: "checkout", State: models.FlagStateActive}
+			kept := models.FeatureFlag{Name: "banner", State: models.FlagStateActive}
+			assert.NoError(t, flags.CreateFlag(&flag))
+			assert.NoError(t, flags.CreateFlag(&kept))
+			assert.NoError(t, flags.DeleteFlag(flag.ID))
+
+			_, err := flags.GetFlag(flag.ID)
+			assert.ErrorIs(t, err, store.ErrNotFound)
+			assert.ErrorIs(t, flags.SetFlagEnabled(&flag, true), store.ErrNotFound)
+			listed, err := flags.ListFlags(store.FlagFilter{})
+			assert.NoError(t, err)
+			assert.Len(t, listed, 1)
+
+			// The name is free again while the deleted flag waits in the trash
+			again := models.FeatureFlag{Name: "checkout", State: models.FlagStateActive}
+			assert.NoError(t, flags.CreateFlag(&again))
+			assert.NotEqual(t, flag.ID, again.ID)
+		})
+	}
+}
+
+func TestStoresKeepFlagNamesUniquePerOrganization(t *testing.T) {
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			flags := open(t)
+			acme, globex := uint(1), uint(2)
+			assert.NoError(t, flags.CreateFlag(&models.FeatureFlag{Name: "checkout", State: models.FlagStateActive, OrganizationID: &acme}))
+			assert.NoError(t, flags.CreateFlag(&models.FeatureFlag{Name: "checkout", State: models.FlagStateActive, OrganizationID: &globex}))
+			assert.NoError(t, flags.CreateFlag(&models.FeatureFlag{Name: "checkout", State: models.FlagStateActive}))
+			assert.ErrorIs(t, flags.CreateFlag(&models.FeatureFlag{Name: "checkout", State: models.FlagStateActive, OrganizationID: &acme}), store.ErrNameTaken)
+			assert.ErrorIs(t, flags.CreateFlag(&models.FeatureFlag{Name: "checkout", State: models.FlagStateActive}), store.ErrNameTaken)
+
+			banner := models.FeatureFlag{Name: "banner", State: models.FlagStateActive, OrganizationID: &acme}
+			assert.NoError(t, flags.CreateFlag(&banner))
+			banner.Name = "checkout"
+			assert.ErrorIs(t, flags.SaveFlag(&banner), store.ErrNameTaken)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/handlers"
+	"feature-flag-service/internal/loginguard"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// withEachCache runs a test against the in-memory cache and, when TEST_REDIS_ADDR names a Redis
+// server the tests may flush, against Redis
+func withEachCache(t *testing.T, test func(t *testing.T)) {
+	t.Run("memory", func(t *testing.T) {
+		useMemoryCache(t)
+		test(t)
+	})
+	t.Run("redis", func(t *testing.T) {
+		addr := os.Getenv("TEST_REDIS_ADDR")
+		if addr == "" {
+			t.Skip("TEST_REDIS_ADDR is not set")
+		}
+		client := redis.NewClient(&redis.Options{Addr: addr, DB: 15})
+		if err := client.FlushDB(config.Ctx).Err(); err != nil {
+			t.Fatal(err)
+		}
+		previous := config.Cache
+		config.Cache = config.InstrumentedCache{CacheStore: config.RedisCache{Client: client}, Redis: true}
+		t.Cleanup(func() {
+			config.Cache = previous
+			client.FlushDB(config.Ctx)
+			client.Close()
+		})
+		test(t)
+	})
+}
+
+func TestLoginGuardLocksAndUnlocks(t *testing.T) {
+	withEachCache(t, func(t *testing.T) {
+		for i := 0; i < loginguard.UserThreshold-1; i++ {
+			status, err := loginguard.Fail("Alice", "192.0.2.1")
+			assert.NoError(t, err)
+			assert.False(t, status.Locked)
+		}
+		status, err := loginguard.Fail("alice", "192.0.2.1")
+		assert.NoError(t, err)
+		assert.True(t, status.Locked, "case variations share one counter")
+		assert.Equal(t, loginguard.BaseLockout, status.RetryAfter)
+
+		status, err = loginguard.Check("alice", "198.51.100.7")
+		assert.NoError(t, err)
+		assert.True(t, status.Locked)
+		assert.InDelta(t, loginguard.BaseLockout.Seconds(), status.RetryAfter.Seconds(), 1)
+
+		assert.NoError(t, loginguard.Unlock("alice"))
+		status, err = loginguard.Check("alice", "192.0.2.1")
+		assert.NoError(t, err)
+		assert.False(t, status.Locked)
+
+		// Spraying many usernames from one address locks the address, and a success does not reset it
+		for i := 0; i < loginguard.IPThreshold; i++ {
+			status, err = loginguard.Fail(fmt.Sprintf("user%d", i), "203.0.113.9")
+			assert.NoError(t, err)
+		}
+		assert.True(t, status.Locked)
+		assert.NoError(t, loginguard.Succeed("user0"))
+		status, err = loginguard.Check("bob", "203.0.113.9")
+		assert.NoError(t, err)
+		assert.True(t, status.Locked)
+	})
+}
+
+func TestLoginLockoutResetsOnlyAfterMFA(t *testing.T) {
+	withEachCache(t, func(t *testing.T) {
+		db := useSQLite(t)
+		hash, err := utils.HashPassword("plum-tractor-violet-19")
+		assert.NoError(t, err)
+		user := models.User{Username: "alice", Password: hash, Role: models.RoleAdmin}
+		db.Create(&user)
+		auth := newAuthHandler(t, false)
+		secret, _, err := auth.MFA.BeginEnrollment(&user)
+		assert.NoError(t, err)
+		now := time.Now()
+		code, _ := utils.TOTPCode(secret, now.Add(-30*time.Second))
+		_, err = auth.MFA.Activate(&user, code, now.Add(-30*time.Second))
+		assert.NoError(t, err)
+
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.POST("/login", auth.Login)
+		r.POST("/login/mfa", auth.LoginMFA)
+		challenge := func() string {
+			w := send(r, http.MethodPost, "/login", `{"username": "alice", "password": "plum-tractor-violet-19"}`)
+			assert.Equal(t, http.StatusOK, w.Code)
+			var response handlers.MFAChallengeResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.True(t, response.MFARequired)
+			return response.MFAToken
+		}
+		submit := func(token, code string) int {
+			return send(r, http.MethodPost, "/login/mfa", `{"mfa_token": "`+token+`", "code": "`+code+`"}`).Code
+		}
+
+		token := challenge()
+		for i := 0; i < loginguard.UserThreshold-1; i++ {
+			assert.Equal(t, http.StatusUnauthorized, submit(token, "000000"))
+		}
+
+		// The correct password alone must not clear the wrong codes counted so far
+		token = challenge()
+		assert.Equal(t, http.StatusUnauthorized, submit(token, "000000"))
+		assert.Equal(t, http.StatusTooManyRequests, submit(token, "000000"))
+
+		assert.NoError(t, loginguard.Unlock("alice"))
+		for i := 0; i < loginguard.UserThreshold-1; i++ {
+			assert.Equal(t, http.StatusUnauthorized, submit(token, "000000"))
+		}
+		code, _ = utils.TOTPCode(secret, time.Now())
+		assert.Equal(t, http.StatusOK, submit(token, code))
+
+		// A completed login forgets the earlier failures
+		for i := 0; i < loginguard.UserThreshold-1; i++ {
+			assert.Equal(t, http.StatusUnauthorized, submit(token, "000000"))
+		}
+		status, err := loginguard.Check("alice", "192.0.2.1")
+		assert.NoError(t, err)
+		assert.False(t, status.Locked)
+	})
+}
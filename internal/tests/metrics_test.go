@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"feature-flag-service/internal/handlers"
+	"feature-flag-service/internal/metrics"
+	"feature-flag-service/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Metrics())
+	r.GET("/widgets/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/metrics", handlers.NewMetricsHandler("").GetMetrics)
+
+	send(r, http.MethodGet, "/widgets/1", "")
+	send(r, http.MethodGet, "/widgets/2", "")
+	send(r, http.MethodGet, "/no-such-path", "")
+
+	w := send(r, http.MethodGet, "/metrics", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, handlers.MetricsContentType, w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.Contains(t, body, "# TYPE http_requests_total counter\n")
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/widgets/:id",status="200"} 2`)
+	assert.Contains(t, body, `http_requests_total{method="unmatched",route="unmatched",status="404"}`)
+	assert.Contains(t, body, `http_request_duration_seconds_bucket{method="GET",route="/widgets/:id",le="+Inf"} 2`)
+	assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="/widgets/:id"} 2`)
+}
+
+func TestMetricsTokenIsRequiredWhenSet(t *testing.T) {
+	r := newRouter("", "")
+	r.GET("/metrics", handlers.NewMetricsHandler("scrape-secret").GetMetrics)
+
+	assert.Equal(t, http.StatusUnauthorized, send(r, http.MethodGet, "/metrics", "").Code)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape-secret")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestEvaluationMetricFlagsAreBounded(t *testing.T) {
+	defer metrics.SetMaxFlags(metrics.DefaultMaxFlags)
+
+	metrics.RecordEvaluation("metrics_known_flag", "treatment", "ON")
+	metrics.SetMaxFlags(0)
+	metrics.RecordEvaluation("metrics_known_flag", "treatment", "ON")
+	metrics.RecordEvaluation("metrics_late_flag", "control", "OFF")
+
+	// Flags seen before the limit keep their series; later ones share the overflow series
+	assert.Equal(t, 2.0, metrics.Evaluations.Value("metrics_known_flag", "treatment", "ON"))
+	assert.Equal(t, 0.0, metrics.Evaluations.Value("metrics_late_flag", "control", "OFF"))
+	assert.GreaterOrEqual(t, metrics.Evaluations.Value(metrics.OtherFlags, "control", "OFF"), 1.0)
+
+	// Unknown variations share one series too
+	metrics.RecordEvaluation("metrics_known_flag", "checkout-v2-blue", "ON")
+	assert.Equal(t, 0.0, metrics.Evaluations.Value("metrics_known_flag", "checkout-v2-blue", "ON"))
+	assert.Equal(t, 1.0, metrics.Evaluations.Value("metrics_known_flag", metrics.OtherVariations, "ON"))
+}
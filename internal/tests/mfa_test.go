@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/handlers"
+	"feature-flag-service/internal/mfa"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOTPSecretsAreEncryptedAtRest(t *testing.T) {
+	db := useSQLite(t)
+	alice := models.User{Username: "alice", Password: "x", Role: models.RoleAdmin}
+	bob := models.User{Username: "bob", Password: "x", Role: models.RoleAdmin}
+	db.Create(&alice)
+	db.Create(&bob)
+
+	authenticator := newAuthHandler(t, false).MFA
+	secret, _, err := authenticator.BeginEnrollment(&alice)
+	assert.NoError(t, err)
+	var stored models.User
+	db.First(&stored, alice.ID)
+	assert.True(t, strings.HasPrefix(stored.TOTPSecret, "enc:v1:"))
+	assert.NotContains(t, stored.TOTPSecret, secret)
+
+	now := time.Unix(1700000000, 0)
+	code, _ := utils.TOTPCode(secret, now)
+	_, err = authenticator.Activate(&stored, code, now)
+	assert.NoError(t, err)
+	later, _ := utils.TOTPCode(secret, now.Add(time.Minute))
+	assert.NoError(t, authenticator.Verify(&stored, later, now.Add(time.Minute)))
+
+	// The ciphertext is bound to its user, so copying it to another row does not work
+	db.Model(&bob).UpdateColumns(map[string]interface{}{"mfa_enabled": true, "totp_secret": stored.TOTPSecret})
+	db.First(&bob, bob.ID)
+	assert.ErrorIs(t, authenticator.Verify(&bob, later, now.Add(time.Minute)), mfa.ErrInvalidCode)
+}
+
+func TestLegacyPlaintextSecretsAreEncryptedOnUse(t *testing.T) {
+	db := useSQLite(t)
+	secret, err := utils.GenerateTOTPSecret()
+	assert.NoError(t, err)
+	user := models.User{Username: "alice", Password: "x", Role: models.RoleAdmin, MFAEnabled: true, TOTPSecret: secret}
+	db.Create(&user)
+
+	now := time.Unix(1700000000, 0)
+	code, _ := utils.TOTPCode(secret, now)
+	authenticator := newAuthHandler(t, false).MFA
+	assert.NoError(t, authenticator.Verify(&user, code, now))
+
+	var stored models.User
+	db.First(&stored, user.ID)
+	assert.True(t, strings.HasPrefix(stored.TOTPSecret, "enc:v1:"))
+	later, _ := utils.TOTPCode(secret, now.Add(time.Minute))
+	assert.NoError(t, authenticator.Verify(&stored, later, now.Add(time.Minute)))
+}
+
+func TestOrganizationCanRequireMFA(t *testing.T) {
+	db := useSQLite(t)
+	acme := models.Organization{Name: "acme"}
+	db.Create(&acme)
+	member := models.User{Username: "erin", Password: "x", Role: models.RoleEditor, OrganizationID: &acme.ID}
+	outsider := models.User{Username: "olga", Password: "x", Role: models.RoleEditor}
+	owner := models.User{Username: "root", Password: "x", Role: models.RoleOwner, OrganizationID: &acme.ID}
+	db.Create(&member)
+	db.Create(&outsider)
+
+	required, err := mfa.Required(&member)
+	assert.NoError(t, err)
+	assert.False(t, required)
+
+	path := fmt.Sprintf("/organizations/%d/mfa", acme.ID)
+	r := newRouter("admin", models.RoleAdmin)
+	r.PUT("/organizations/:id/mfa", handlers.SetOrganizationMFA)
+	w := send(r, http.MethodPut, path, `{"required": true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"require_mfa":true`)
+
+	required, err = mfa.Required(&member)
+	assert.NoError(t, err)
+	assert.True(t, required)
+	required, err = mfa.Required(&outsider)
+	assert.NoError(t, err)
+	assert.False(t, required)
+
+	// Once an owner belongs to the organization, an admin can no longer lift the requirement
+	db.Create(&owner)
+	w = send(r, http.MethodPut, path, `{"required": false}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = send(r, http.MethodPut, "/organizations/999/mfa", `{"required": true}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
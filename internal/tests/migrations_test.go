@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"feature-flag-service/internal/migrations"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMigrationListIsOrdered(t *testing.T) {
+	assert.NoError(t, migrations.Validate(migrations.All))
+
+	noop := func(tx *gorm.DB) error { return nil }
+	err := migrations.Validate([]migrations.Migration{{Version: 2, Name: "b", Up: noop}, {Version: 2, Name: "c", Up: noop}})
+	assert.ErrorIs(t, err, migrations.ErrInvalidMigration)
+}
+
+func TestMigrationsUpDownAndNewerSchema(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, migrations.Check(db), migrations.ErrSchemaBehind)
+	assert.NoError(t, migrations.Startup(db, true))
+	assert.True(t, db.Migrator().HasTable("feature_flags"))
+
+	// Running again is a no-op
+	applied, err := migrations.Up(db)
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+
+	assert.True(t, db.Migrator().HasIndex("feature_flags", "idx_feature_flags_organization_name"))
+	assert.False(t, db.Migrator().HasIndex("custom_roles", "idx_custom_roles_name"))
+
+	reverted, err := migrations.Down(db, 5)
+	assert.NoError(t, err)
+	assert.Len(t, reverted, 5)
+	assert.Equal(t, "organization_names", reverted[0].Name)
+	assert.Equal(t, "organization_scope", reverted[1].Name)
+	assert.Equal(t, "live_user_names", reverted[2].Name)
+	assert.True(t, db.Migrator().HasIndex("custom_roles", "idx_custom_roles_name"))
+	assert.True(t, db.Migrator().HasIndex("service_accounts", "idx_service_accounts_name"))
+	assert.False(t, db.Migrator().HasColumn("sdk_keys", "organization_id"))
+	assert.True(t, db.Migrator().HasIndex("sdk_keys", "idx_sdk_keys_key_hash"))
+	assert.True(t, db.Migrator().HasIndex("organizations", "idx_organizations_name"))
+	assert.True(t, db.Migrator().HasIndex("users", "idx_users_username"))
+	assert.False(t, db.Migrator().HasColumn("organizations", "require_mfa"))
+	assert.True(t, db.Migrator().HasTable("feature_flags"))
+	assert.False(t, db.Migrator().HasColumn("feature_flags", "project"))
+	assert.True(t, db.Migrator().HasIndex("feature_flags", "idx_feature_flags_name"), "dropping columns keeps the other indexes")
+
+	// The baseline cannot be reverted, and a refused revert changes nothing
+	_, err = migrations.Down(db, 1)
+	assert.ErrorIs(t, err, migrations.ErrIrreversible)
+	_, err = migrations.Up(db)
+	assert.NoError(t, err)
+	_, err = migrations.To(db, 0)
+	assert.ErrorIs(t, err, migrations.ErrIrreversible)
+	assert.True(t, db.Migrator().HasColumn("sdk_keys", "organization_id"))
+	assert.True(t, db.Migrator().HasTable("feature_flags"))
+
+	// A version recorded by a newer build blocks startup
+	db.Create(&migrations.AppliedMigration{Version: migrations.Latest() + 1, Name: "future"})
+	assert.ErrorIs(t, migrations.Startup(db, true), migrations.ErrSchemaTooNew)
+}
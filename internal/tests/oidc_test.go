@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/handlers"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/oidc"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockProvider is a minimal in-process OpenID provider that issues one code per login
+type mockProvider struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+	groups    []string
+	audience  string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	m := &mockProvider{key: key, audience: "flag-service"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "mock-1", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "good-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss": m.server.URL, "aud": m.audience, "sub": "user-123",
+			"iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix(),
+			"nonce": m.nonce, "email": "alice@example.com", "preferred_username": "alice",
+			"groups": m.groups,
+		})
+		token.Header["kid"] = "mock-1"
+		idToken, _ := token.SignedString(m.key)
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "opaque", "token_type": "Bearer", "id_token": idToken})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// authorize plays the user logging in at the provider, which remembers the PKCE challenge and nonce
+func (m *mockProvider) authorize(t *testing.T, authURL string) {
+	u, err := url.Parse(authURL)
+	assert.NoError(t, err)
+	assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	m.challenge = u.Query().Get("code_challenge")
+	m.nonce = u.Query().Get("nonce")
+}
+
+func TestOIDCLoginWithPKCEAgainstMockProvider(t *testing.T) {
+	m := newMockProvider(t)
+	m.groups = []string{"everyone", "flag-editors"}
+
+	cfg := oidc.Config{
+		Issuer: m.server.URL, ClientID: "flag-service", RedirectURL: "http://localhost:8080/auth/oidc/callback",
+		Scopes: []string{"openid"}, GroupsClaim: "groups", DefaultRole: models.RoleViewer,
+		RoleMapping: map[string]string{"flag-editors": models.RoleEditor, "flag-admins": models.RoleAdmin},
+	}
+	provider, err := oidc.Discover(context.Background(), cfg, m.server.Client())
+	assert.NoError(t, err)
+
+	req, err := oidc.NewAuthRequest()
+	assert.NoError(t, err)
+	m.authorize(t, provider.AuthCodeURL(req))
+
+	identity, err := provider.Exchange(context.Background(), "good-code", req)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-123", identity.Subject)
+	assert.Equal(t, "alice", identity.Username())
+	assert.Equal(t, models.RoleEditor, cfg.RoleFor(identity.Groups))
+	assert.Equal(t, models.RoleAdmin, cfg.RoleFor([]string{"flag-editors", "flag-admins"}))
+	assert.Equal(t, models.RoleViewer, cfg.RoleFor(nil))
+
+	// A stolen code is useless without the verifier
+	stolen := *req
+	stolen.Verifier = "attacker-verifier"
+	_, err = provider.Exchange(context.Background(), "good-code", &stolen)
+	assert.ErrorIs(t, err, oidc.ErrExchange)
+}
+
+func TestOIDCRejectsTokensForAnotherClient(t *testing.T) {
+	m := newMockProvider(t)
+	m.audience = "some-other-app"
+
+	cfg := oidc.Config{Issuer: m.server.URL, ClientID: "flag-service", RedirectURL: "http://localhost/cb"}
+	provider, err := oidc.Discover(context.Background(), cfg, m.server.Client())
+	assert.NoError(t, err)
+
+	req, _ := oidc.NewAuthRequest()
+	m.authorize(t, provider.AuthCodeURL(req))
+
+	_, err = provider.Exchange(context.Background(), "good-code", req)
+	assert.ErrorIs(t, err, oidc.ErrInvalidToken)
+}
+
+func TestOIDCCallbackRequiresTheStateCookie(t *testing.T) {
+	db := useSQLite(t)
+	useMemoryCache(t)
+	m := newMockProvider(t)
+	oidc.Configure(oidc.Config{
+		Issuer: m.server.URL, ClientID: "flag-service", RedirectURL: "https://flags.example.com/auth/oidc/callback",
+		Scopes: []string{"openid"}, DefaultRole: models.RoleViewer,
+	})
+	t.Cleanup(func() { oidc.Configure(oidc.Config{}) })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/auth/oidc/login", handlers.OIDCLogin)
+	r.GET("/auth/oidc/callback", newAuthHandler(t, false).OIDCCallback)
+
+	// login starts a request at the provider and returns the browser's state cookie
+	login := func() (string, *http.Cookie) {
+		w := send(r, "GET", "/auth/oidc/login", "")
+		assert.Equal(t, http.StatusFound, w.Code)
+		location := w.Header().Get("Location")
+		m.authorize(t, location)
+		u, _ := url.Parse(location)
+		cookies := w.Result().Cookies()
+		if !assert.Len(t, cookies, 1) {
+			t.FailNow()
+		}
+		return u.Query().Get("state"), cookies[0]
+	}
+	callback := func(state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?code=good-code&state="+url.QueryEscape(state), nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	state, cookie := login()
+	assert.Equal(t, state, cookie.Value)
+	assert.True(t, cookie.HttpOnly)
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, "/auth/oidc/callback", cookie.Path)
+
+	// A callback link replayed in another browser is refused without using up the state
+	assert.Equal(t, http.StatusBadRequest, callback(state, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, callback(state, &http.Cookie{Name: cookie.Name, Value: "other"}).Code)
+	assert.Equal(t, http.StatusOK, callback(state, cookie).Code)
+
+	// A deleted SSO user is refused rather than failing on the taken external ID
+	var user models.User
+	assert.NoError(t, db.Where("username = ?", "alice").First(&user).Error)
+	assert.NoError(t, db.Delete(&user).Error)
+	state, cookie = login()
+	w := callback(state, cookie)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "deleted")
+}
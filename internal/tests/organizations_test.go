@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/handlers"
+	"feature-flag-service/internal/invites"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/policy"
+	"feature-flag-service/internal/sdkkeys"
+	"feature-flag-service/internal/store"
+	"feature-flag-service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func organizationRouter(db *gorm.DB, claims *utils.Claims) *gin.Engine {
+	flags := handlers.NewFlagHandler(store.NewSQL(db))
+	users := handlers.NewUserHandler(store.NewSQL(db))
+	r := policyRouter(claims)
+	r.GET("/flags", middleware.AuthorizeAny(policy.FlagRead), flags.GetFeatureFlags)
+	r.POST("/flags", middleware.Authorize(policy.FlagCreate), flags.CreateFeatureFlag)
+	r.GET("/flags/:id", middleware.Authorize(policy.FlagRead), flags.GetFeatureFlag)
+	r.GET("/flags/:id/evaluate", middleware.Authorize(policy.FlagRead), handlers.EvaluateFeatureFlag)
+	r.GET("/users", middleware.Authorize(policy.UserRead), users.GetUsers)
+	r.GET("/users/:id", middleware.Authorize(policy.UserRead), users.GetUser)
+	r.GET("/roles", middleware.Authorize(policy.RoleRead), handlers.GetCustomRoles)
+	r.POST("/service-accounts", middleware.Authorize(policy.ServiceAccountManage), handlers.CreateServiceAccount)
+	r.GET("/sdk-keys", middleware.AuthorizeAny(policy.SDKKeyRead), handlers.GetSDKKeys)
+	r.GET("/organizations", middleware.Authorize(policy.UserRead), handlers.GetOrganizations)
+	r.POST("/organizations", middleware.Authorize(policy.UserManage), handlers.CreateOrganization)
+	r.POST("/invites", middleware.Authorize(policy.UserManage), handlers.CreateInvite)
+	r.DELETE("/invites/:id", middleware.Authorize(policy.UserManage), handlers.RevokeInvite)
+	r.GET("/audit", middleware.Authorize(policy.UserManage), handlers.GetAuditLog)
+	return r
+}
+
+func TestOrganizationsSeeOnlyTheirOwnRecords(t *testing.T) {
+	db := useSQLite(t)
+	acme, globex := models.Organization{Name: "acme"}, models.Organization{Name: "globex"}
+	db.Create(&acme)
+	db.Create(&globex)
+
+	ours := models.FeatureFlag{Name: "acme_checkout", State: models.FlagStateActive, OrganizationID: &acme.ID}
+	theirs := models.FeatureFlag{Name: "globex_checkout", State: models.FlagStateActive, OrganizationID: &globex.ID}
+	db.Create(&ours)
+	db.Create(&theirs)
+	colleague := models.User{Username: "carol", Password: "x", Role: models.RoleEditor, OrganizationID: &acme.ID}
+	stranger := models.User{Username: "sam", Password: "x", Role: models.RoleEditor, OrganizationID: &globex.ID}
+	db.Create(&colleague)
+	db.Create(&stranger)
+	db.Create(&models.CustomRole{Name: "acme-reader", OrganizationID: &acme.ID, Statements: []models.PolicyStatement{
+		{Effect: models.EffectAllow, Actions: []string{"flag:read"}, Resources: []string{"*"}},
+	}})
+	db.Create(&models.CustomRole{Name: "globex-reader", OrganizationID: &globex.ID, Statements: []models.PolicyStatement{
+		{Effect: models.EffectAllow, Actions: []string{"flag:read"}, Resources: []string{"*"}},
+	}})
+	_, _, err := sdkkeys.Create("production", models.SDKKeyServer, &acme.ID)
+	assert.NoError(t, err)
+	_, _, err = sdkkeys.Create("production", models.SDKKeyServer, &globex.ID)
+	assert.NoError(t, err)
+
+	r := organizationRouter(db, &utils.Claims{Username: "ann", Role: models.RoleAdmin, Organization: acme.ID})
+	db.Create(&models.AuditEntry{Action: models.AuditLoginFailed, Actor: "carol", Target: "carol"})
+	db.Create(&models.AuditEntry{Action: models.AuditLoginFailed, Actor: "sam", Target: "sam"})
+	lists := map[string]string{"/flags": "globex_checkout", "/users": "sam", "/roles": "globex-reader", "/organizations": "globex", "/audit": "sam"}
+	for path, hidden := range lists {
+		w := send(r, http.MethodGet, path, "")
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.NotContains(t, w.Body.String(), hidden, path)
+	}
+	assert.Contains(t, send(r, http.MethodGet, "/audit", "").Body.String(), `"actor":"carol"`)
+	var keys []models.SDKKey
+	assert.NoError(t, json.Unmarshal(send(r, http.MethodGet, "/sdk-keys", "").Body.Bytes(), &keys))
+	assert.Len(t, keys, 1)
+
+	assert.Equal(t, http.StatusOK, send(r, http.MethodGet, fmt.Sprintf("/flags/%d", ours.ID), "").Code)
+	assert.Equal(t, http.StatusNotFound, send(r, http.MethodGet, fmt.Sprintf("/flags/%d", theirs.ID), "").Code)
+	assert.Equal(t, http.StatusOK, send(r, http.MethodGet, fmt.Sprintf("/users/%d", colleague.ID), "").Code)
+	assert.Equal(t, http.StatusNotFound, send(r, http.MethodGet, fmt.Sprintf("/users/%d", stranger.ID), "").Code)
+
+	// New records belong to the creator's organization, and only owners add organizations
+	w := send(r, http.MethodPost, "/flags", `{"name": "acme_banner"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), fmt.Sprintf(`"organization_id":%d`, acme.ID))
+	assert.Equal(t, http.StatusBadRequest, send(r, http.MethodPost, "/flags", `{"name": "acme_pay", "prerequisites": [{"flag": "globex_checkout", "variation": "treatment"}]}`).Code)
+	assert.Equal(t, http.StatusForbidden, send(r, http.MethodPost, "/organizations", `{"name": "initech"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(r, http.MethodPost, "/invites", fmt.Sprintf(`{"organization_id": %d, "role": "viewer"}`, globex.ID)).Code)
+	assert.Equal(t, http.StatusBadRequest, send(r, http.MethodPost, "/invites", fmt.Sprintf(`{"organization_id": %d, "role": "globex-reader"}`, acme.ID)).Code)
+	assert.Equal(t, http.StatusCreated, send(r, http.MethodPost, "/invites", fmt.Sprintf(`{"organization_id": %d, "role": "acme-reader"}`, acme.ID)).Code)
+
+	// Owners administer every organization
+	owner := organizationRouter(db, &utils.Claims{Username: "root", Role: models.RoleOwner})
+	w = send(owner, http.MethodGet, "/flags", "")
+	assert.Contains(t, w.Body.String(), "acme_checkout")
+	assert.Contains(t, w.Body.String(), "globex_checkout")
+	assert.Equal(t, http.StatusCreated, send(owner, http.MethodPost, "/organizations", `{"name": "initech"}`).Code)
+}
+
+func TestNamesAreUniqueWithinAnOrganization(t *testing.T) {
+	db := useSQLite(t)
+	acme, globex := models.Organization{Name: "acme"}, models.Organization{Name: "globex"}
+	db.Create(&acme)
+	db.Create(&globex)
+	db.Create(&models.FeatureFlag{Name: "checkout", State: models.FlagStateActive, IsEnabled: true, OrganizationID: &globex.ID})
+	db.Create(&models.ServiceAccount{Name: "ci", Role: models.RoleViewer, OrganizationID: &globex.ID})
+	db.Create(&models.CustomRole{Name: "release", OrganizationID: &globex.ID, Statements: []models.PolicyStatement{
+		{Effect: models.EffectAllow, Actions: []string{"flag:*"}, Resources: []string{"*"}},
+	}})
+	db.Create(&models.CustomRole{Name: "release", OrganizationID: &acme.ID, Statements: []models.PolicyStatement{
+		{Effect: models.EffectAllow, Actions: []string{"flag:read"}, Resources: []string{"*"}},
+	}})
+
+	// Another organization's names are free to use, but not one's own twice
+	r := organizationRouter(db, &utils.Claims{Username: "ann", Role: models.RoleAdmin, Organization: acme.ID})
+	assert.Equal(t, http.StatusCreated, send(r, http.MethodPost, "/flags", `{"name": "checkout"}`).Code)
+	assert.Equal(t, http.StatusConflict, send(r, http.MethodPost, "/flags", `{"name": "checkout"}`).Code)
+	assert.Equal(t, http.StatusCreated, send(r, http.MethodPost, "/service-accounts", `{"name": "ci", "role": "viewer"}`).Code)
+	assert.Equal(t, http.StatusConflict, send(r, http.MethodPost, "/service-accounts", `{"name": "ci", "role": "viewer"}`).Code)
+
+	// Prerequisites resolve among the flag's own organization: acme's checkout is off
+	pay := models.FeatureFlag{Name: "pay", State: models.FlagStateActive, IsEnabled: true, OrganizationID: &acme.ID,
+		Prerequisites: []models.Prerequisite{{Flag: "checkout", Variation: "treatment"}}}
+	db.Create(&pay)
+	w := send(r, http.MethodGet, fmt.Sprintf("/flags/%d/evaluate", pay.ID), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"reason":"PREREQUISITE_FAILED"`)
+
+	// A custom role grants what its organization's role of that name allows
+	release := &utils.Claims{Username: "rita", Role: "release", Organization: acme.ID}
+	resource := policy.Resource{Organization: &acme.ID}
+	assert.True(t, middleware.Decide(release, policy.FlagRead, resource).Allowed)
+	assert.False(t, middleware.Decide(release, policy.FlagToggle, resource).Allowed)
+}
+
+func TestSDKKeysServeOnlyTheirOrganizationsFlags(t *testing.T) {
+	db := useSQLite(t)
+	acme, globex := models.Organization{Name: "acme"}, models.Organization{Name: "globex"}
+	db.Create(&acme)
+	db.Create(&globex)
+	db.Create(&models.FeatureFlag{Name: "acme_checkout", State: models.FlagStateActive, OrganizationID: &acme.ID})
+	db.Create(&models.FeatureFlag{Name: "globex_checkout", State: models.FlagStateActive, OrganizationID: &globex.ID})
+
+	_, plaintext, err := sdkkeys.Create("production", models.SDKKeyServer, &acme.ID)
+	assert.NoError(t, err)
+	w := sendSDK(sdkRouter(), "/sdk/flags", plaintext)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "acme_checkout")
+	assert.NotContains(t, w.Body.String(), "globex_checkout")
+}
+
+func TestInvitesAcceptRevokeAndExpire(t *testing.T) {
+	db := useSQLite(t)
+	acme := models.Organization{Name: "acme"}
+	db.Create(&acme)
+	now := time.Now()
+
+	invite := models.Invite{OrganizationID: acme.ID, Role: models.RoleEditor, Email: "erin@example.com"}
+	token, err := invites.Create(&invite, invites.DefaultTTL, now)
+	assert.NoError(t, err)
+
+	user, err := invites.Accept(token, "erin", "plum-tractor-violet-19", now)
+	assert.NoError(t, err)
+	assert.Equal(t, models.RoleEditor, user.Role)
+	assert.Equal(t, acme.ID, *user.OrganizationID)
+	_, err = invites.Accept(token, "erin2", "plum-tractor-violet-19", now)
+	assert.ErrorIs(t, err, invites.ErrInvalidInvite, "invites are single-use")
+
+	// A revoked invite cannot be accepted, and revoking goes through the API
+	revoked := models.Invite{OrganizationID: acme.ID, Role: models.RoleViewer}
+	token, err = invites.Create(&revoked, invites.DefaultTTL, now)
+	assert.NoError(t, err)
+	r := organizationRouter(db, &utils.Claims{Username: "ann", Role: models.RoleAdmin, Organization: acme.ID})
+	assert.Equal(t, http.StatusOK, send(r, http.MethodDelete, fmt.Sprintf("/invites/%d", revoked.ID), "").Code)
+	assert.Equal(t, http.StatusNotFound, send(r, http.MethodDelete, fmt.Sprintf("/invites/%d", revoked.ID), "").Code)
+	_, err = invites.Accept(token, "victor", "plum-tractor-violet-19", now)
+	assert.ErrorIs(t, err, invites.ErrInvalidInvite)
+
+	// Admins of another organization do not see the invite at all
+	other := models.Invite{OrganizationID: acme.ID, Role: models.RoleViewer}
+	_, err = invites.Create(&other, invites.DefaultTTL, now)
+	assert.NoError(t, err)
+	outsider := organizationRouter(db, &utils.Claims{Username: "sam", Role: models.RoleAdmin, Organization: acme.ID + 1})
+	assert.Equal(t, http.StatusNotFound, send(outsider, http.MethodDelete, fmt.Sprintf("/invites/%d", other.ID), "").Code)
+
+	expiring := models.Invite{OrganizationID: acme.ID, Role: models.RoleViewer}
+	token, err = invites.Create(&expiring, time.Hour, now)
+	assert.NoError(t, err)
+	_, err = invites.Accept(token, "xavier", "plum-tractor-violet-19", now.Add(2*time.Hour))
+	assert.ErrorIs(t, err, invites.ErrInvalidInvite)
+
+	_, err = invites.Create(&models.Invite{OrganizationID: acme.ID, Role: models.RoleViewer}, invites.MaxTTL+time.Hour, now)
+	assert.ErrorIs(t, err, invites.ErrInvalidTTL)
+}
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/handlers"
+	"feature-flag-service/internal/loginguard"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/notify"
+	"feature-flag-service/internal/passwords"
+	"feature-flag-service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingNotifier keeps sent messages so tests can read the tokens in them
+type recordingNotifier struct {
+	mu       sync.Mutex
+	messages []notify.Message
+}
+
+func (n *recordingNotifier) Send(msg notify.Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+var resetToken = regexp.MustCompile(`ffr_[A-Za-z0-9_-]+`)
+
+// lastResetToken returns the token in the most recent message
+func (n *recordingNotifier) lastResetToken(t *testing.T) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.messages) == 0 {
+		t.Fatal("no message was sent")
+	}
+	return resetToken.FindString(n.messages[len(n.messages)-1].Body)
+}
+
+func useRecordingNotifier(t *testing.T) *recordingNotifier {
+	recorder := &recordingNotifier{}
+	notify.Use(recorder)
+	t.Cleanup(func() { notify.Use(nil) })
+	return recorder
+}
+
+func TestPasswordResetTokensAreSingleUseAndExpire(t *testing.T) {
+	db := useSQLite(t)
+	recorder := useRecordingNotifier(t)
+	now := time.Now()
+	hash, _ := passwords.Hash("alice", "plum-tractor-violet-19")
+	alice := models.User{Username: "alice", Password: hash, Role: models.RoleViewer, Email: "alice@example.com"}
+	db.Create(&alice)
+
+	user, err := passwords.RequestReset("ALICE@example.com", "", now)
+	assert.NoError(t, err)
+	assert.Equal(t, alice.ID, user.ID)
+	first := recorder.lastResetToken(t)
+	assert.NotEmpty(t, first)
+
+	// Asking again invalidates the earlier link
+	_, err = passwords.RequestReset("alice", "", now)
+	assert.NoError(t, err)
+	second := recorder.lastResetToken(t)
+	_, err = passwords.Reset(first, "copper-lantern-meadow-42", now)
+	assert.ErrorIs(t, err, passwords.ErrInvalidResetToken)
+
+	_, err = passwords.Reset(second, "copper-lantern-meadow-42", now)
+	assert.NoError(t, err)
+	var stored models.User
+	db.First(&stored, alice.ID)
+	assert.NoError(t, utils.CheckPassword(stored.Password, "copper-lantern-meadow-42"))
+	_, err = passwords.Reset(second, "granite-willow-harbor-77", now)
+	assert.ErrorIs(t, err, passwords.ErrInvalidResetToken, "tokens are single-use")
+
+	_, err = passwords.RequestReset("alice", "", now)
+	assert.NoError(t, err)
+	_, err = passwords.Reset(recorder.lastResetToken(t), "granite-willow-harbor-77", now.Add(passwords.ResetTTL+time.Minute))
+	assert.ErrorIs(t, err, passwords.ErrInvalidResetToken)
+
+	// Unknown accounts get no message and no error
+	sent := len(recorder.messages)
+	user, err = passwords.RequestReset("nobody", "", now)
+	assert.NoError(t, err)
+	assert.Nil(t, user)
+	assert.Len(t, recorder.messages, sent)
+}
+
+func TestPasswordHistoryRejectsRecentPasswords(t *testing.T) {
+	db := useSQLite(t)
+	now := time.Now()
+	hash, _ := passwords.Hash("alice", "plum-tractor-violet-19")
+	alice := models.User{Username: "alice", Password: hash, Role: models.RoleViewer}
+	db.Create(&alice)
+	assert.NoError(t, passwords.Remember(db, alice.ID, hash, now))
+
+	assert.ErrorIs(t, passwords.Change(&alice, "plum-tractor-violet-19", now), passwords.ErrReused)
+	assert.NoError(t, passwords.Change(&alice, "copper-lantern-meadow-42", now.Add(time.Minute)))
+	assert.ErrorIs(t, passwords.Change(&alice, "plum-tractor-violet-19", now.Add(2*time.Minute)), passwords.ErrReused)
+
+	// Only the newest HistorySize passwords are kept and checked
+	for i, password := range []string{"granite-willow-harbor-1", "granite-willow-harbor-2", "granite-willow-harbor-3", "granite-willow-harbor-4", "granite-willow-harbor-5"} {
+		assert.NoError(t, passwords.Change(&alice, password, now.Add(time.Duration(i+3)*time.Minute)))
+	}
+	var kept int64
+	db.Model(&models.PasswordHistory{}).Where("user_id = ?", alice.ID).Count(&kept)
+	assert.Equal(t, int64(passwords.Current().HistorySize), kept)
+	assert.NoError(t, passwords.Change(&alice, "plum-tractor-violet-19", now.Add(time.Hour)))
+}
+
+func TestForgotPasswordIsRateLimited(t *testing.T) {
+	useSQLite(t)
+	useRecordingNotifier(t)
+	withEachCache(t, func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.POST("/password/forgot", handlers.NewPasswordHandler("").ForgotPassword)
+
+		for i := 0; i < loginguard.ResetUserLimit; i++ {
+			assert.Equal(t, http.StatusAccepted, send(r, http.MethodPost, "/password/forgot", `{"login": "alice"}`).Code)
+		}
+		w := send(r, http.MethodPost, "/password/forgot", `{"login": "Alice"}`)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+		// Other accounts can still be reset until the address sends too many requests
+		for i := loginguard.ResetUserLimit + 1; i < loginguard.ResetIPLimit; i++ {
+			assert.Equal(t, http.StatusAccepted, send(r, http.MethodPost, "/password/forgot", `{"login": "user`+string(rune('a'+i))+`"}`).Code)
+		}
+		assert.Equal(t, http.StatusTooManyRequests, send(r, http.MethodPost, "/password/forgot", `{"login": "bob"}`).Code)
+	})
+}
@@ -0,0 +1,254 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"feature-flag-service/internal/handlers"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/policy"
+	"feature-flag-service/internal/store"
+	"feature-flag-service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomRoleTogglesTaggedFlagsOnly(t *testing.T) {
+	// "may toggle flags tagged mobile in production but not edit rules"
+	roles := map[string][]models.PolicyStatement{
+		"mobile-toggler": {
+			{Effect: models.EffectAllow, Actions: []string{"flag:read", "flag:toggle"}, Resources: []string{"env:production/tag:mobile"}},
+			{Effect: models.EffectDeny, Actions: []string{"flag:updateRules"}, Resources: []string{"*"}},
+		},
+	}
+	engine := &policy.Engine{Resolve: func(name string) ([]models.PolicyStatement, bool) {
+		stmts, ok := roles[name]
+		return stmts, ok
+	}}
+	grants := []policy.Grant{{Role: "mobile-toggler"}}
+
+	mobileProd := policy.Resource{Environment: "production", Flag: "dark_mode", Tags: []string{"mobile", "ui"}}
+	webProd := policy.Resource{Environment: "production", Flag: "web_banner", Tags: []string{"web"}}
+	mobileStaging := policy.Resource{Environment: "staging", Flag: "dark_mode", Tags: []string{"mobile"}}
+
+	assert.True(t, engine.Authorize(grants, policy.FlagToggle, mobileProd).Allowed)
+	assert.False(t, engine.Authorize(grants, policy.FlagToggle, webProd).Allowed)
+	assert.False(t, engine.Authorize(grants, policy.FlagToggle, mobileStaging).Allowed)
+
+	denied := engine.Authorize(grants, policy.FlagUpdateRules, mobileProd)
+	assert.False(t, denied.Allowed)
+	assert.Equal(t, models.EffectDeny, denied.Statement.Effect)
+}
+
+func TestDenyOverridesBuiltinAllow(t *testing.T) {
+	engine := &policy.Engine{Resolve: func(name string) ([]models.PolicyStatement, bool) {
+		return []models.PolicyStatement{
+			{Effect: models.EffectDeny, Actions: []string{"flag:*"}, Resources: []string{"flag:billing_*"}},
+		}, name == "no-billing"
+	}}
+	grants := []policy.Grant{{Role: models.RoleEditor}, {Role: "no-billing"}}
+
+	assert.True(t, engine.Authorize(grants, policy.FlagUpdate, policy.Resource{Flag: "search_v2"}).Allowed)
+	assert.False(t, engine.Authorize(grants, policy.FlagUpdate, policy.Resource{Flag: "billing_retry"}).Allowed)
+}
+
+func TestScopedGrantsOnlyApplyInTheirEnvironment(t *testing.T) {
+	engine := &policy.Engine{}
+	grants := []policy.Grant{{Role: models.RoleViewer}, {Role: models.RoleEditor, Environment: "staging"}}
+
+	assert.True(t, engine.Authorize(grants, policy.FlagUpdate, policy.Resource{Environment: "staging"}).Allowed)
+	assert.False(t, engine.Authorize(grants, policy.FlagUpdate, policy.Resource{Environment: "production"}).Allowed)
+	assert.True(t, engine.Authorize(grants, policy.FlagRead, policy.Resource{Environment: "production"}).Allowed)
+	assert.False(t, engine.Authorize(grants, policy.FlagDelete, policy.Resource{Environment: "staging"}).Allowed)
+}
+
+func TestValidateStatements(t *testing.T) {
+	assert.NoError(t, policy.Validate([]models.PolicyStatement{
+		{Effect: models.EffectAllow, Actions: []string{"flag:*"}, Resources: []string{"project:checkout/env:prod*"}},
+	}))
+	assert.ErrorIs(t, policy.Validate([]models.PolicyStatement{
+		{Effect: "maybe", Actions: []string{"flag:read"}, Resources: []string{"*"}},
+	}), policy.ErrInvalidStatement)
+	assert.ErrorIs(t, policy.Validate([]models.PolicyStatement{
+		{Effect: models.EffectAllow, Actions: []string{"flag:read"}, Resources: []string{"team:payments"}},
+	}), policy.ErrInvalidStatement)
+}
+
+func TestTokenScopesNarrowOwnerPermissions(t *testing.T) {
+	engine := &policy.Engine{}
+	grants := []policy.Grant{{Role: models.RoleAdmin}}
+	scopes := []string{policy.FlagRead, policy.FlagToggle}
+
+	toggle := policy.Restrict(engine.Authorize(grants, policy.FlagToggle, policy.Resource{}), scopes)
+	assert.True(t, toggle.Allowed)
+
+	del := policy.Restrict(engine.Authorize(grants, policy.FlagDelete, policy.Resource{}), scopes)
+	assert.False(t, del.Allowed)
+	assert.Contains(t, del.Reason, "not scoped")
+
+	// Scopes never widen what the owner may do
+	viewer := []policy.Grant{{Role: models.RoleViewer}}
+	assert.False(t, policy.Restrict(engine.Authorize(viewer, policy.FlagToggle, policy.Resource{}), scopes).Allowed)
+
+	assert.NoError(t, policy.ValidateScopes([]string{"flag:*", policy.SignalWrite}))
+	assert.Error(t, policy.ValidateScopes([]string{"flags:toggle"}))
+}
+
+func TestHealthReportIsAdminOnly(t *testing.T) {
+	engine := &policy.Engine{}
+	for role, allowed := range map[string]bool{models.RoleViewer: false, models.RoleEditor: false, models.RoleAdmin: true, models.RoleOwner: true} {
+		assert.Equal(t, allowed, engine.Authorize([]policy.Grant{{Role: role}}, policy.SystemHealth, policy.Resource{}).Allowed, role)
+	}
+}
+
+func TestFlagScopeComesFromTheStoredFlag(t *testing.T) {
+	db := useSQLite(t)
+	staging := models.FeatureFlag{Name: "search_v2", Environment: "staging", State: models.FlagStateActive}
+	production := models.FeatureFlag{Name: "billing_retry", Environment: "production", State: models.FlagStateActive}
+	db.Create(&staging)
+	db.Create(&production)
+
+	r := policyRouter(&utils.Claims{
+		Username: "alice", Role: models.RoleViewer,
+		Scopes: []policy.Grant{{Role: models.RoleEditor, Environment: "staging"}},
+	})
+	flags := handlers.NewFlagHandler(store.NewSQL(db))
+	r.GET("/flags", middleware.Authorize(policy.FlagRead), flags.GetFeatureFlags)
+	r.POST("/flags", middleware.Authorize(policy.FlagCreate), flags.CreateFeatureFlag)
+	r.PATCH("/flags/:id/toggle", middleware.Authorize(policy.FlagToggle), flags.ToggleFeatureFlag)
+
+	toggle := func(id uint, environment string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/flags/%d/toggle", id), strings.NewReader(`{"is_enabled": true}`))
+		if environment != "" {
+			req.Header.Set("X-Environment", environment)
+		}
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, toggle(staging.ID, ""))
+	assert.Equal(t, http.StatusOK, toggle(staging.ID, "staging"))
+	// Claiming the staging environment does not unlock a production flag
+	assert.Equal(t, http.StatusBadRequest, toggle(production.ID, "staging"))
+	assert.Equal(t, http.StatusForbidden, toggle(production.ID, ""))
+
+	// New flags take the request's environment unless the body names one, and the two must agree
+	assert.Equal(t, http.StatusCreated, send(r, "POST", "/flags?environment=staging", `{"name": "dark_mode"}`).Code)
+	assert.Equal(t, http.StatusForbidden, send(r, "POST", "/flags", `{"name": "prod_banner", "environment": "production"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(r, "POST", "/flags?environment=staging", `{"name": "prod_banner", "environment": "production"}`).Code)
+
+	var created models.FeatureFlag
+	assert.NoError(t, db.Where("name = ?", "dark_mode").First(&created).Error)
+	assert.Equal(t, "staging", created.Environment)
+
+	w := send(r, "GET", "/flags?environment=staging", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var listed []models.FeatureFlag
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	assert.Len(t, listed, 2)
+}
+
+// policyRouter authenticates every request as the given subject and authorizes routes with the policy engine
+func policyRouter(claims *utils.Claims) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(middleware.ClaimsKey, claims)
+		c.Next()
+	})
+	return r
+}
+
+func TestTagScopedRoleListsOnlyItsFlags(t *testing.T) {
+	db := useSQLite(t)
+	db.Create(&models.CustomRole{Name: "mobile-reader", Statements: []models.PolicyStatement{
+		{Effect: models.EffectAllow, Actions: []string{"flag:read"}, Resources: []string{"tag:mobile"}},
+	}})
+	db.Create(&models.FeatureFlag{Name: "dark_mode", Tags: []string{"mobile"}, State: models.FlagStateActive})
+	db.Create(&models.FeatureFlag{Name: "web_banner", Tags: []string{"web"}, State: models.FlagStateActive})
+
+	r := policyRouter(&utils.Claims{Username: "alice", Role: "mobile-reader"})
+	r.GET("/flags", middleware.AuthorizeAny(policy.FlagRead), handlers.NewFlagHandler(store.NewSQL(db)).GetFeatureFlags)
+	r.GET("/users", middleware.AuthorizeAny(policy.UserRead), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := send(r, "GET", "/flags", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var listed []models.FeatureFlag
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	if assert.Len(t, listed, 1) {
+		assert.Equal(t, "dark_mode", listed[0].Name)
+	}
+
+	assert.Equal(t, http.StatusForbidden, send(r, "GET", "/users", "").Code)
+}
+
+func TestDenyAppliesWithoutScopeHeaders(t *testing.T) {
+	db := useSQLite(t)
+	db.Create(&models.CustomRole{Name: "no-production", Statements: []models.PolicyStatement{
+		{Effect: models.EffectDeny, Actions: []string{"flag:*"}, Resources: []string{"env:production"}},
+	}})
+	flag := models.FeatureFlag{Name: "billing_retry", Environment: "production", State: models.FlagStateActive}
+	db.Create(&flag)
+
+	r := policyRouter(&utils.Claims{Username: "alice", Role: models.RoleEditor, Scopes: []policy.Grant{{Role: "no-production"}}})
+	r.PATCH("/flags/:id/toggle", middleware.Authorize(policy.FlagToggle), handlers.NewFlagHandler(store.NewSQL(db)).ToggleFeatureFlag)
+
+	w := send(r, "PATCH", fmt.Sprintf("/flags/%d/toggle", flag.ID), `{"is_enabled": true}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestRoleManagersCannotWidenTheirOwnAccess(t *testing.T) {
+	db := useSQLite(t)
+	manager := models.CustomRole{Name: "role-manager", Statements: []models.PolicyStatement{
+		{Effect: models.EffectAllow, Actions: []string{"role:*", "flag:read"}, Resources: []string{"*"}},
+	}}
+	db.Create(&manager)
+
+	r := policyRouter(&utils.Claims{Username: "alice", Role: "role-manager"})
+	r.POST("/roles", middleware.Authorize(policy.RoleManage), handlers.CreateCustomRole)
+	r.PUT("/roles/:id", middleware.Authorize(policy.RoleManage), handlers.UpdateCustomRole)
+
+	everything := `[{"effect": "allow", "actions": ["*"], "resources": ["*"]}]`
+	w := send(r, "PUT", fmt.Sprintf("/roles/%d", manager.ID), `{"name": "role-manager", "statements": `+everything+`}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, http.StatusForbidden, send(r, "POST", "/roles", `{"name": "superuser", "statements": `+everything+`}`).Code)
+
+	readOnly := `[{"effect": "allow", "actions": ["flag:read"], "resources": ["tag:mobile"]}]`
+	assert.Equal(t, http.StatusCreated, send(r, "POST", "/roles", `{"name": "mobile-reader", "statements": `+readOnly+`}`).Code)
+
+	var stored models.CustomRole
+	assert.NoError(t, db.First(&stored, manager.ID).Error)
+	assert.Len(t, stored.Statements, 1)
+	assert.Equal(t, []string{"role:*", "flag:read"}, stored.Statements[0].Actions)
+}
+
+func TestAdminsCannotGrantOwnerDefinedRoles(t *testing.T) {
+	db := useSQLite(t)
+	admin := models.User{Username: "ann", Password: "x", Role: models.RoleAdmin}
+	db.Create(&admin)
+	db.Create(&models.CustomRole{Name: "superuser", Statements: []models.PolicyStatement{
+		{Effect: models.EffectAllow, Actions: []string{"*"}, Resources: []string{"*"}},
+	}})
+	db.Create(&models.CustomRole{Name: "flag-toggler", Statements: []models.PolicyStatement{
+		{Effect: models.EffectAllow, Actions: []string{"flag:read", "flag:toggle"}, Resources: []string{"*"}},
+	}})
+
+	r := policyRouter(&utils.Claims{Username: "ann", Role: models.RoleAdmin})
+	r.POST("/users/:id/bindings", middleware.Authorize(policy.UserManage), handlers.CreateRoleBinding)
+	path := fmt.Sprintf("/users/%d/bindings", admin.ID)
+
+	// A role allowing everything reaches as far as the owner role, so only owners grant it
+	assert.Equal(t, http.StatusForbidden, send(r, "POST", path, `{"role": "superuser", "environment": "production"}`).Code)
+	assert.Equal(t, http.StatusCreated, send(r, "POST", path, `{"role": "flag-toggler", "environment": "production"}`).Code)
+
+	owner := policyRouter(&utils.Claims{Username: "root", Role: models.RoleOwner})
+	owner.POST("/users/:id/bindings", middleware.Authorize(policy.UserManage), handlers.CreateRoleBinding)
+	assert.Equal(t, http.StatusCreated, send(owner, "POST", path, `{"role": "superuser", "environment": "production"}`).Code)
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/rollouts"
+	"feature-flag-service/internal/store"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var threeSteps = []models.RolloutStep{
+	{Percentage: 10, Duration: "1h"},
+	{Percentage: 50, Duration: "1h"},
+	{Percentage: 100, Duration: "1h"},
+}
+
+func reloadFlag(t *testing.T, id uint) models.FeatureFlag {
+	var flag models.FeatureFlag
+	assert.NoError(t, config.DB.Unscoped().First(&flag, id).Error)
+	return flag
+}
+
+func TestRolloutAdvancesPausesAndAborts(t *testing.T) {
+	db := useSQLite(t)
+	flag := models.FeatureFlag{Name: "new_checkout", State: models.FlagStateActive}
+	assert.NoError(t, db.Create(&flag).Error)
+	started := time.Now()
+
+	_, err := rollouts.Start(&flag, threeSteps, started)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, *reloadFlag(t, flag.ID).RolloutPercentage)
+	_, err = rollouts.Start(&flag, threeSteps, started)
+	assert.ErrorIs(t, err, rollouts.ErrPlanActive)
+
+	// Nothing moves before the step has elapsed
+	assert.NoError(t, rollouts.AdvanceDue(started.Add(30*time.Minute)))
+	assert.Equal(t, 10, *reloadFlag(t, flag.ID).RolloutPercentage)
+	assert.NoError(t, rollouts.AdvanceDue(started.Add(time.Hour)))
+	assert.Equal(t, 50, *reloadFlag(t, flag.ID).RolloutPercentage)
+
+	// A paused plan stays on its step
+	_, err = rollouts.Pause(flag.ID, started.Add(90*time.Minute))
+	assert.NoError(t, err)
+	_, err = rollouts.Pause(flag.ID, started.Add(90*time.Minute))
+	assert.ErrorIs(t, err, rollouts.ErrInvalidState)
+	assert.NoError(t, rollouts.AdvanceDue(started.Add(5*time.Hour)))
+	assert.Equal(t, 50, *reloadFlag(t, flag.ID).RolloutPercentage)
+
+	plan, err := rollouts.Resume(flag.ID, started.Add(5*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, models.RolloutStatusRunning, plan.Status)
+
+	// Aborting restores the flag to its state before the plan
+	plan, err = rollouts.Abort(flag.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.RolloutStatusAborted, plan.Status)
+	restored := reloadFlag(t, flag.ID)
+	assert.False(t, restored.IsEnabled)
+	assert.Nil(t, restored.RolloutPercentage)
+	_, err = rollouts.Pause(flag.ID, time.Now())
+	assert.ErrorIs(t, err, rollouts.ErrNoActivePlan)
+}
+
+func TestRolloutCompletes(t *testing.T) {
+	db := useSQLite(t)
+	flag := models.FeatureFlag{Name: "new_checkout", State: models.FlagStateActive}
+	assert.NoError(t, db.Create(&flag).Error)
+	started := time.Now()
+	_, err := rollouts.Start(&flag, threeSteps, started)
+	assert.NoError(t, err)
+
+	for hours := 1; hours <= 3; hours++ {
+		assert.NoError(t, rollouts.AdvanceDue(started.Add(time.Duration(hours)*time.Hour)))
+	}
+	var plan models.RolloutPlan
+	assert.NoError(t, db.First(&plan).Error)
+	assert.Equal(t, models.RolloutStatusCompleted, plan.Status)
+	final := reloadFlag(t, flag.ID)
+	assert.True(t, final.IsEnabled)
+	assert.Nil(t, final.RolloutPercentage)
+}
+
+func TestRolloutFailureDoesNotBlockOtherPlans(t *testing.T) {
+	db := useSQLite(t)
+	started := time.Now()
+	orphan := models.RolloutPlan{FeatureFlagID: 999, Steps: threeSteps, Status: models.RolloutStatusRunning, StepStartedAt: started}
+	assert.NoError(t, db.Create(&orphan).Error)
+	flag := models.FeatureFlag{Name: "new_checkout", State: models.FlagStateActive}
+	assert.NoError(t, db.Create(&flag).Error)
+	_, err := rollouts.Start(&flag, threeSteps, started)
+	assert.NoError(t, err)
+
+	err = rollouts.AdvanceDue(started.Add(time.Hour))
+	assert.ErrorContains(t, err, "rollout plan")
+	assert.Equal(t, 50, *reloadFlag(t, flag.ID).RolloutPercentage)
+}
+
+func TestDeletingAFlagAbortsItsRollout(t *testing.T) {
+	db := useSQLite(t)
+	flag := models.FeatureFlag{Name: "new_checkout", State: models.FlagStateActive}
+	assert.NoError(t, db.Create(&flag).Error)
+	started := time.Now()
+	_, err := rollouts.Start(&flag, threeSteps, started)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.NewSQL(db).DeleteFlag(flag.ID))
+	_, err = rollouts.ActivePlan(db, flag.ID)
+	assert.ErrorIs(t, err, rollouts.ErrNoActivePlan)
+	assert.NoError(t, rollouts.AdvanceDue(started.Add(time.Hour)))
+	assert.Nil(t, reloadFlag(t, flag.ID).RolloutPercentage)
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/handlers"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/sdkkeys"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func sdkRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/sdk/flags", middleware.SDKKeyMiddleware(false), handlers.GetSDKFlags)
+	r.GET("/sdk/evaluate", middleware.SDKKeyMiddleware(true), handlers.EvaluateSDKFlags)
+	return r
+}
+
+func sendSDK(r *gin.Engine, path, key string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestSDKKeyCreateAndRotate(t *testing.T) {
+	db := useSQLite(t)
+	now := time.Now()
+
+	key, plaintext, err := sdkkeys.Create("production", models.SDKKeyServer, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, plaintext, "ffs-srv-")
+	assert.NotContains(t, key.KeyHash, plaintext, "only the hash is stored")
+	_, _, err = sdkkeys.Create("production", "mobile", nil)
+	assert.ErrorIs(t, err, sdkkeys.ErrInvalidKind)
+
+	authenticated, err := sdkkeys.Authenticate(plaintext, now)
+	assert.NoError(t, err)
+	assert.Equal(t, key.ID, authenticated.ID)
+
+	// The old key keeps working until the grace period ends
+	replacement, newPlaintext, err := sdkkeys.Rotate(key.ID, time.Hour, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "production", replacement.Environment)
+	_, err = sdkkeys.Authenticate(plaintext, now.Add(30*time.Minute))
+	assert.NoError(t, err)
+	_, err = sdkkeys.Authenticate(plaintext, now.Add(time.Hour))
+	assert.ErrorIs(t, err, sdkkeys.ErrInvalidKey)
+	_, err = sdkkeys.Authenticate(newPlaintext, now.Add(time.Hour))
+	assert.NoError(t, err)
+
+	// Rotating again never extends the old key's grace period
+	_, _, err = sdkkeys.Rotate(key.ID, 48*time.Hour, now)
+	assert.NoError(t, err)
+	var old models.SDKKey
+	assert.NoError(t, db.First(&old, key.ID).Error)
+	assert.WithinDuration(t, now.Add(time.Hour), *old.ExpiresAt, time.Second)
+
+	_, _, err = sdkkeys.Rotate(key.ID, time.Hour, now.Add(2*time.Hour))
+	assert.ErrorIs(t, err, sdkkeys.ErrInvalidKey)
+	_, _, err = sdkkeys.Rotate(9999, time.Hour, now)
+	assert.ErrorIs(t, err, sdkkeys.ErrKeyNotFound)
+}
+
+func TestSDKKeyMiddleware(t *testing.T) {
+	db := useSQLite(t)
+	_, server, _ := sdkkeys.Create("production", models.SDKKeyServer, nil)
+	_, client, _ := sdkkeys.Create("production", models.SDKKeyClient, nil)
+	expired, expiredPlaintext, _ := sdkkeys.Create("production", models.SDKKeyServer, nil)
+	db.Model(expired).Update("expires_at", time.Now().Add(-time.Minute))
+	r := sdkRouter()
+
+	assert.Equal(t, http.StatusUnauthorized, sendSDK(r, "/sdk/flags", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, sendSDK(r, "/sdk/flags", "ffs-srv-unknown").Code)
+	assert.Equal(t, http.StatusUnauthorized, sendSDK(r, "/sdk/flags", expiredPlaintext).Code)
+	assert.Equal(t, http.StatusForbidden, sendSDK(r, "/sdk/flags", client).Code)
+	assert.Equal(t, http.StatusOK, sendSDK(r, "/sdk/flags", server).Code)
+	assert.Equal(t, http.StatusOK, sendSDK(r, "/sdk/evaluate", client).Code)
+}
+
+func TestSDKServesOnlyTheKeyEnvironment(t *testing.T) {
+	db := useSQLite(t)
+	db.Create(&models.FeatureFlag{Name: "prod_only", Environment: "production", IsEnabled: true, ClientVisible: true, State: models.FlagStateActive})
+	db.Create(&models.FeatureFlag{Name: "staging_only", Environment: "staging", IsEnabled: true, ClientVisible: true, State: models.FlagStateActive})
+	db.Create(&models.FeatureFlag{Name: "everywhere", IsEnabled: true, ClientVisible: true, State: models.FlagStateActive})
+	_, server, _ := sdkkeys.Create("production", models.SDKKeyServer, nil)
+	r := sdkRouter()
+
+	w := sendSDK(r, "/sdk/flags", server)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "prod_only")
+	assert.Contains(t, w.Body.String(), "everywhere")
+	assert.NotContains(t, w.Body.String(), "staging_only")
+
+	w = sendSDK(r, "/sdk/evaluate?key=user-1", server)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "staging_only")
+
+	// Every evaluated flag has its usage recorded
+	var flags []models.FeatureFlag
+	db.Where("last_evaluated_at IS NOT NULL").Order("name").Find(&flags)
+	if assert.Len(t, flags, 2) {
+		assert.Equal(t, "everywhere", flags[0].Name)
+		assert.NotNil(t, flags[0].LastTreatmentAt)
+		assert.Equal(t, "prod_only", flags[1].Name)
+	}
+}
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/sessions"
+	"feature-flag-service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshRotatesTheToken(t *testing.T) {
+	db := useSQLite(t)
+	useMemoryCache(t)
+	now := time.Now()
+
+	session, first, err := sessions.Start(1, "test", "192.0.2.1", now)
+	assert.NoError(t, err)
+
+	refreshed, second, err := sessions.Refresh(first, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, session.ID, refreshed.ID)
+	assert.NotEqual(t, first, second)
+	assert.WithinDuration(t, now.Add(time.Minute+sessions.RefreshTokenTTL), refreshed.ExpiresAt, time.Second)
+
+	var used models.RefreshToken
+	assert.NoError(t, db.Where("session_id = ? AND used_at IS NOT NULL", session.ID).First(&used).Error)
+
+	_, third, err := sessions.Refresh(second, now.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.NotEqual(t, second, third)
+}
+
+func TestRefreshTokenReuseRevokesTheSession(t *testing.T) {
+	db := useSQLite(t)
+	useMemoryCache(t)
+	now := time.Now()
+
+	session, first, err := sessions.Start(1, "test", "192.0.2.1", now)
+	assert.NoError(t, err)
+	_, second, err := sessions.Refresh(first, now)
+	assert.NoError(t, err)
+
+	// Replaying the rotated token revokes the whole family, including the token it was exchanged for
+	_, _, err = sessions.Refresh(first, now)
+	assert.ErrorIs(t, err, sessions.ErrRefreshTokenReused)
+	_, _, err = sessions.Refresh(second, now)
+	assert.ErrorIs(t, err, sessions.ErrInvalidRefreshToken)
+
+	var stored models.Session
+	assert.NoError(t, db.First(&stored, session.ID).Error)
+	assert.NotNil(t, stored.RevokedAt)
+
+	revoked, err := sessions.IsRevoked(&utils.Claims{SessionID: session.ID})
+	assert.NoError(t, err)
+	assert.True(t, revoked, "access tokens of the session must stop working too")
+}
+
+func TestAuthMiddlewareRejectsRevokedAccessTokens(t *testing.T) {
+	useSQLite(t)
+	useMemoryCache(t)
+	now := time.Now()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/me", middleware.AuthMiddleware(testTokens), func(c *gin.Context) { c.Status(http.StatusOK) })
+	call := func(token string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	session, _, err := sessions.Start(1, "test", "192.0.2.1", now)
+	assert.NoError(t, err)
+	single, err := testTokens.GenerateJWT("alice", models.RoleEditor, nil, nil, session.ID)
+	assert.NoError(t, err)
+	sibling, err := testTokens.GenerateJWT("alice", models.RoleEditor, nil, nil, session.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, call(single))
+
+	// Revoking one access token leaves others from the same session working
+	claims, err := testTokens.ValidateJWT(single)
+	assert.NoError(t, err)
+	assert.NoError(t, sessions.RevokeAccessToken(claims, now))
+	assert.Equal(t, http.StatusUnauthorized, call(single))
+	assert.Equal(t, http.StatusOK, call(sibling))
+
+	// Ending the session rejects every access token issued for it
+	assert.NoError(t, sessions.Revoke(session, now))
+	assert.Equal(t, http.StatusUnauthorized, call(sibling))
+}
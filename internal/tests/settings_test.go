@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"feature-flag-service/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettingsPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte("server:\n  port: 9000\n  trash_retention_days: 7\ndatabase:\n  store: sqlite\n"), 0o600))
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("PORT", "9100")
+	t.Setenv("DATABASE_URL", "postgres://flags:hunter2@db:5432/flags")
+
+	settings, err := config.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 9100, settings.Server.Port)            // Environment beats the file
+	assert.Equal(t, 7, settings.Server.TrashRetentionDays) // File beats the default
+	assert.Equal(t, 12, settings.Passwords.MinLength)      // Default
+	assert.Equal(t, config.StoreSQLite, settings.Database.Store)
+
+	printed, err := settings.Redacted()
+	assert.NoError(t, err)
+	assert.NotContains(t, string(printed), "hunter2")
+	assert.Contains(t, string(printed), "flags:")
+}
+
+func TestSettingsReportEveryProblem(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("STORE", "postgres")
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("REDIS_URL", "localhost:6379")
+	t.Setenv("PORT", "eighty")
+	t.Setenv("NOTIFIER", "pigeon")
+	t.Setenv("BOOTSTRAP_ADMIN_USERNAME", "admin")
+	t.Setenv("MFA_ENCRYPTION_KEY", "dG9vIHNob3J0")
+
+	_, err := config.Load()
+	assert.Error(t, err)
+	for _, name := range []string{"PORT", "DATABASE_URL", "NOTIFIER", "BOOTSTRAP_ADMIN_PASSWORD", "MFA_ENCRYPTION_KEY"} {
+		assert.True(t, strings.Contains(err.Error(), name), "expected a problem with %s in %q", name, err)
+	}
+}
+
+func TestSettingsRejectUnknownSSORoles(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("OIDC_ISSUER", "https://idp.example.com")
+	t.Setenv("OIDC_CLIENT_ID", "flag-service")
+	t.Setenv("OIDC_REDIRECT_URL", "https://flags.example.com/auth/oidc/callback")
+	t.Setenv("OIDC_ROLE_MAPPING", "flag-editors=editor,flag-admins=superuser")
+	t.Setenv("OIDC_DEFAULT_ROLE", "nobody")
+
+	_, err := config.Load()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown role "superuser"`)
+	assert.Contains(t, err.Error(), `OIDC_DEFAULT_ROLE: unknown role "nobody"`)
+	assert.NotContains(t, err.Error(), "flag-editors")
+}
+
+func TestProductionRequiresMetricsToken(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("METRICS_TOKEN", "")
+
+	_, err := config.Load()
+	if err == nil {
+		t.Fatal("production settings without METRICS_TOKEN were accepted")
+	}
+	assert.Contains(t, err.Error(), "METRICS_TOKEN: required in production")
+
+	// Other production requirements may still fail, but not this one
+	t.Setenv("METRICS_TOKEN", "scrape-secret")
+	if _, err = config.Load(); err != nil {
+		assert.NotContains(t, err.Error(), "METRICS_TOKEN")
+	}
+}
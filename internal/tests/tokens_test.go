@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/handlers"
+	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/policy"
+	"feature-flag-service/internal/tokens"
+	"feature-flag-service/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScopedAccessTokenCannotManageCredentials(t *testing.T) {
+	db := useSQLite(t)
+	user := models.User{Username: "alice", Password: "x", Role: models.RoleOwner}
+	db.Create(&user)
+	plaintext, err := tokens.Create(&models.AccessToken{Name: "ci", UserID: &user.ID, Scopes: []string{policy.FlagRead}}, time.Now())
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	api := r.Group("/api", middleware.AuthMiddleware(testTokens))
+	api.GET("/flags", middleware.AuthorizeAny(policy.FlagRead), func(c *gin.Context) { c.Status(http.StatusOK) })
+	api.DELETE("/flags/:id", middleware.Authorize(policy.FlagDelete), func(c *gin.Context) { c.Status(http.StatusOK) })
+	self := api.Group("", middleware.RequireSession())
+	self.POST("/tokens", handlers.CreateAccessToken)
+	self.GET("/sessions", handlers.GetSessions)
+	self.POST("/logout", handlers.Logout)
+	self.DELETE("/mfa", newAuthHandler(t, false).DisableMFA)
+	self.GET("/auth/explain", handlers.ExplainPermissions)
+
+	call := func(method, path string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+plaintext)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, call(http.MethodGet, "/api/flags"))
+	assert.Equal(t, http.StatusForbidden, call(http.MethodDelete, "/api/flags/1"))
+	for _, route := range [][2]string{
+		{http.MethodPost, "/api/tokens"},
+		{http.MethodGet, "/api/sessions"},
+		{http.MethodPost, "/api/logout"},
+		{http.MethodDelete, "/api/mfa"},
+		{http.MethodGet, "/api/auth/explain"},
+	} {
+		assert.Equal(t, http.StatusForbidden, call(route[0], route[1]), route[1])
+	}
+
+	var count int64
+	db.Model(&models.AccessToken{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestRevokingServiceAccountTokensRequiresItsRole(t *testing.T) {
+	db := useSQLite(t)
+	account := models.ServiceAccount{Name: "deployer", Role: models.RoleOwner}
+	db.Create(&account)
+	token := models.AccessToken{Name: "deploy", ServiceAccountID: &account.ID}
+	_, err := tokens.Create(&token, time.Now())
+	assert.NoError(t, err)
+
+	path := fmt.Sprintf("/service-accounts/%d/tokens/%d", account.ID, token.ID)
+	for role, want := range map[string]int{models.RoleAdmin: http.StatusForbidden, models.RoleOwner: http.StatusOK} {
+		r := policyRouter(&utils.Claims{Username: "alice", Role: role})
+		r.DELETE("/service-accounts/:id/tokens/:tokenId", handlers.RevokeServiceAccountToken)
+		assert.Equal(t, want, send(r, "DELETE", path, "").Code, role)
+	}
+
+	assert.NoError(t, db.First(&token, token.ID).Error)
+	assert.NotNil(t, token.RevokedAt)
+}
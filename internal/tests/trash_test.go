@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/handlers"
+	"feature-flag-service/internal/lifecycle"
+	"feature-flag-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestoreAndPurgeFromTrash(t *testing.T) {
+	db := useSQLite(t)
+	r := newRouter("root", models.RoleOwner)
+	r.POST("/trash/flags/:id/restore", handlers.RestoreFeatureFlag)
+	r.DELETE("/trash/flags/:id", handlers.PurgeFeatureFlag)
+
+	flag := models.FeatureFlag{Name: "new_checkout", State: models.FlagStateActive}
+	assert.NoError(t, db.Create(&flag).Error)
+	assert.NoError(t, db.Create(&models.FlagHistory{FeatureFlagID: flag.ID}).Error)
+
+	// Only flags in the trash can be restored or purged
+	assert.Equal(t, http.StatusNotFound, send(r, "POST", "/trash/flags/1/restore", "").Code)
+	assert.NoError(t, db.Delete(&flag).Error)
+	assert.Equal(t, http.StatusOK, send(r, "POST", "/trash/flags/1/restore", "").Code)
+	assert.NoError(t, db.First(&models.FeatureFlag{}, flag.ID).Error)
+
+	// A restored flag must not collide with a flag created under its name meanwhile
+	assert.NoError(t, db.Delete(&flag).Error)
+	assert.NoError(t, db.Create(&models.FeatureFlag{Name: "new_checkout", State: models.FlagStateActive}).Error)
+	assert.Equal(t, http.StatusConflict, send(r, "POST", "/trash/flags/1/restore", "").Code)
+
+	assert.Equal(t, http.StatusOK, send(r, "DELETE", "/trash/flags/1", "").Code)
+	var history int64
+	db.Model(&models.FlagHistory{}).Where("feature_flag_id = ?", flag.ID).Count(&history)
+	assert.Zero(t, history)
+	assert.Equal(t, http.StatusNotFound, send(r, "DELETE", "/trash/flags/1", "").Code)
+}
+
+func TestRestoreChecksPrerequisites(t *testing.T) {
+	db := useSQLite(t)
+	r := newRouter("root", models.RoleOwner)
+	r.POST("/trash/flags/:id/restore", handlers.RestoreFeatureFlag)
+
+	parent := models.FeatureFlag{Name: "new_checkout", State: models.FlagStateActive}
+	assert.NoError(t, db.Create(&parent).Error)
+	child := models.FeatureFlag{Name: "express_pay", State: models.FlagStateActive,
+		Prerequisites: []models.Prerequisite{{Flag: "new_checkout", Variation: models.VariationTreatment}}}
+	assert.NoError(t, db.Create(&child).Error)
+
+	// The prerequisite is purged while its dependent sits in the trash
+	assert.NoError(t, db.Delete(&child).Error)
+	assert.NoError(t, db.Delete(&parent).Error)
+	assert.NoError(t, lifecycle.Purge(parent.ID))
+
+	w := send(r, "POST", "/trash/flags/2/restore", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "new_checkout")
+}
+
+func TestPurgeExpiredKeepsRecentTrash(t *testing.T) {
+	db := useSQLite(t)
+	now := time.Now()
+	expired := models.FeatureFlag{Name: "expired", State: models.FlagStateActive}
+	recent := models.FeatureFlag{Name: "recent", State: models.FlagStateActive}
+	assert.NoError(t, db.Create(&expired).Error)
+	assert.NoError(t, db.Create(&recent).Error)
+	assert.NoError(t, db.Unscoped().Model(&expired).Update("deleted_at", now.Add(-40*24*time.Hour)).Error)
+	assert.NoError(t, db.Unscoped().Model(&recent).Update("deleted_at", now.Add(-time.Hour)).Error)
+
+	assert.NoError(t, lifecycle.PurgeExpired(30*24*time.Hour, now))
+	var remaining []string
+	db.Unscoped().Model(&models.FeatureFlag{}).Pluck("name", &remaining)
+	assert.Equal(t, []string{"recent"}, remaining)
+}
@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/store"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stores opens each Store implementation so tests can hold them to the same behaviour
+var stores = map[string]func(t *testing.T) store.Store{
+	"sql":    func(t *testing.T) store.Store { return store.NewSQL(useSQLite(t)) },
+	"memory": func(t *testing.T) store.Store { return store.NewMemory() },
+}
+
+func TestStoresKeepTheLastOwner(t *testing.T) {
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			users := open(t)
+			root := models.User{Username: "root", Password: "x", Role: models.RoleOwner}
+			assert.NoError(t, users.CreateUser(&root))
+
+			demoted := root
+			demoted.Role = models.RoleAdmin
+			assert.ErrorIs(t, users.UpdateUser(&demoted, "role"), store.ErrLastOwner)
+			disabled := root
+			disabled.Disabled = true
+			assert.ErrorIs(t, users.UpdateUser(&disabled, "disabled"), store.ErrLastOwner)
+			assert.ErrorIs(t, users.DeleteUser(&root, time.Now()), store.ErrLastOwner)
+
+			// Other changes to the last owner still go through
+			root.DisplayName = "Root"
+			assert.NoError(t, users.UpdateUser(&root, "display_name"))
+
+			second := models.User{Username: "second", Password: "x", Role: models.RoleOwner}
+			assert.NoError(t, users.CreateUser(&second))
+			assert.NoError(t, users.UpdateUser(&demoted, "role"))
+			assert.ErrorIs(t, users.DeleteUser(&second, time.Now()), store.ErrLastOwner)
+		})
+	}
+}
+
+func TestConcurrentDemotionsKeepAnOwner(t *testing.T) {
+	users := store.NewMemory()
+	owners := make([]models.User, 5)
+	for i := range owners {
+		owners[i] = models.User{Username: string(rune('a' + i)), Role: models.RoleOwner}
+		assert.NoError(t, users.CreateUser(&owners[i]))
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(owners))
+	for i := range owners {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			demoted := owners[i]
+			demoted.Role = models.RoleAdmin
+			errs[i] = users.UpdateUser(&demoted, "role")
+		}(i)
+	}
+	wg.Wait()
+
+	refused := 0
+	for _, err := range errs {
+		if err != nil {
+			assert.ErrorIs(t, err, store.ErrLastOwner)
+			refused++
+		}
+	}
+	assert.Equal(t, 1, refused)
+	disabled := false
+	remaining, err := users.CountUsers(store.UserFilter{Role: models.RoleOwner, Disabled: &disabled})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), remaining)
+}
+
+func TestDeletedUsernamesCanBeReused(t *testing.T) {
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			users := open(t)
+			alice := models.User{Username: "alice", Password: "x", Role: models.RoleViewer, Email: "alice@example.com"}
+			assert.NoError(t, users.CreateUser(&alice))
+			assert.ErrorIs(t, users.CreateUser(&models.User{Username: "alice", Password: "x", Role: models.RoleViewer}), store.ErrUserTaken)
+			assert.ErrorIs(t, users.CreateUser(&models.User{Username: "alicia", Password: "x", Role: models.RoleViewer, Email: "alice@example.com"}), store.ErrUserTaken)
+
+			assert.NoError(t, users.DeleteUser(&alice, time.Now()))
+			again := models.User{Username: "alice", Password: "x", Role: models.RoleViewer, Email: "alice@example.com"}
+			assert.NoError(t, users.CreateUser(&again))
+			assert.NotEqual(t, alice.ID, again.ID)
+		})
+	}
+
+	// Rebuilding the table to drop the old constraint kept the other indexes
+	db := useSQLite(t)
+	for _, index := range []string{"idx_users_disabled", "idx_users_organization_id", "idx_users_external_id", "idx_users_deleted_at"} {
+		assert.True(t, db.Migrator().HasIndex("users", index), index)
+	}
+}
@@ -0,0 +1,124 @@
+package tokens
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/policy"
+	"feature-flag-service/internal/utils"
+)
+
+// Prefix marks access tokens so the API can tell them apart from JWTs
+const Prefix = "ffp_"
+
+// lastUsedResolution limits how often last_used_at is written for a busy token
+const lastUsedResolution = time.Minute
+
+var (
+	ErrInvalidToken  = errors.New("invalid, expired or revoked access token")
+	ErrTokenNotFound = errors.New("access token not found")
+	ErrInvalidExpiry = errors.New("expires_at must be in the future")
+)
+
+// IsAccessToken reports whether a bearer credential looks like an access token
+func IsAccessToken(credential string) bool {
+	return strings.HasPrefix(credential, Prefix)
+}
+
+// Create generates the secret for a token owned by a user or service account, stores its hash,
+// and returns the plaintext, which is never retrievable again
+func Create(token *models.AccessToken, now time.Time) (string, error) {
+	if token.ExpiresAt != nil && !token.ExpiresAt.After(now) {
+		return "", ErrInvalidExpiry
+	}
+
+	plaintext, err := utils.NewSecret(Prefix)
+	if err != nil {
+		return "", err
+	}
+
+	token.Prefix = plaintext[:len(Prefix)+6]
+	token.TokenHash = utils.HashSecret(plaintext)
+	if err := config.DB.Create(token).Error; err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+// Revoke stops a token from authenticating; revoked tokens are kept for auditing
+func Revoke(token *models.AccessToken, now time.Time) error {
+	if token.RevokedAt != nil {
+		return nil
+	}
+	token.RevokedAt = &now
+	return config.DB.Model(token).UpdateColumn("revoked_at", now).Error
+}
+
+// Authenticate resolves a plaintext token to claims carrying its owner's current roles,
+// limited to the token's scopes, and records when the token was last used
+func Authenticate(plaintext string, now time.Time) (*utils.Claims, error) {
+	var token models.AccessToken
+	if err := config.DB.Where("token_hash = ?", utils.HashSecret(plaintext)).First(&token).Error; err != nil {
+		return nil, ErrInvalidToken
+	}
+	if token.RevokedAt != nil || (token.ExpiresAt != nil && !token.ExpiresAt.After(now)) {
+		return nil, ErrInvalidToken
+	}
+
+	claims, err := ownerClaims(&token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	claims.Actions = token.Scopes
+	claims.TokenID = token.ID
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastUsedResolution {
+		config.DB.Model(&token).UpdateColumn("last_used_at", now)
+	}
+	return claims, nil
+}
+
+// ServiceAccountName is the username reported for requests made by a service account
+func ServiceAccountName(account *models.ServiceAccount) string {
+	return "service-account:" + account.Name
+}
+
+// ownerClaims loads the roles the token's owner holds right now, so role changes and deleted
+// owners take effect without reissuing tokens
+func ownerClaims(token *models.AccessToken) (*utils.Claims, error) {
+	if token.ServiceAccountID != nil {
+		var account models.ServiceAccount
+		if err := config.DB.First(&account, *token.ServiceAccountID).Error; err != nil {
+			return nil, err
+		}
+		claims := &utils.Claims{Username: ServiceAccountName(&account), Organization: utils.OrganizationOf(account.OrganizationID)}
+		if account.Project == "" && account.Environment == "" {
+			claims.Role = account.Role
+		} else {
+			claims.Scopes = []policy.Grant{{Role: account.Role, Project: account.Project, Environment: account.Environment}}
+		}
+		return claims, nil
+	}
+
+	if token.UserID == nil {
+		return nil, ErrInvalidToken
+	}
+	var user models.User
+	if err := config.DB.First(&user, *token.UserID).Error; err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, ErrInvalidToken
+	}
+	var bindings []models.RoleBinding
+	if err := config.DB.Where("user_id = ?", user.ID).Find(&bindings).Error; err != nil {
+		return nil, err
+	}
+	return &utils.Claims{
+		Username: user.Username, Role: user.Role, Organization: utils.OrganizationOf(user.OrganizationID),
+		Scopes: utils.ScopesFromBindings(bindings),
+	}, nil
+}
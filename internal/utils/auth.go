@@ -1,44 +1,140 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
-	"os"
 	"time"
 
+	"feature-flag-service/internal/models"
+	"feature-flag-service/internal/policy"
+
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// AccessTokenTTL is the lifetime of an access JWT; sessions are kept alive with refresh tokens
+const AccessTokenTTL = 15 * time.Minute
+
 // Claims defines the JWT claims
 type Claims struct {
-	Username string `json:"username"`
+	Username     string         `json:"username"`
+	Role         string         `json:"role"`
+	Scopes       []policy.Grant `json:"scopes,omitempty"` // Roles limited to a project and/or environment
+	Organization uint           `json:"org,omitempty"`    // Organization the subject belongs to, 0 for none
+	SessionID    uint           `json:"sid,omitempty"`    // Session the token was issued for
+	Actions      []string       `json:"-"`                // Action patterns an access token is limited to
+	TokenID      uint           `json:"-"`                // Set when authenticated with an access token rather than a JWT
 	jwt.RegisteredClaims
 }
 
-// GenerateJWT creates a JWT token for authentication
-func GenerateJWT(username string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour) // Token expires in 24 hours
+// Grants returns the global role and scoped roles carried by the token
+func (c *Claims) Grants() []policy.Grant {
+	return append([]policy.Grant{{Role: c.Role}}, c.Scopes...)
+}
+
+// InOrganization reports whether the subject may see records of an organization (0 for records
+// belonging to none). Owners administer the whole deployment; everyone else sees only their own.
+func (c *Claims) InOrganization(org uint) bool {
+	return c.Role == models.RoleOwner || c.Organization == org
+}
+
+// OrganizationFilter restricts listings to the subject's organization: nil for owners, who see
+// every record, otherwise the organization, where 0 means records belonging to none
+func (c *Claims) OrganizationFilter() *uint {
+	if c.Role == models.RoleOwner {
+		return nil
+	}
+	org := c.Organization
+	return &org
+}
+
+// OrganizationID is the organization of records the subject creates, nil for none
+func (c *Claims) OrganizationID() *uint {
+	if c.Organization == 0 {
+		return nil
+	}
+	org := c.Organization
+	return &org
+}
+
+// OrganizationOf converts a record's organization ID to the value carried in claims
+func OrganizationOf(id *uint) uint {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+// ScopesFromBindings converts a user's role bindings into token scopes
+func ScopesFromBindings(bindings []models.RoleBinding) []policy.Grant {
+	scopes := make([]policy.Grant, len(bindings))
+	for i, b := range bindings {
+		scopes[i] = policy.Grant{Role: b.Role, Project: b.Project, Environment: b.Environment}
+	}
+	return scopes
+}
+
+// TokenIssuer signs and validates this service's access tokens
+type TokenIssuer struct {
+	issuer   string
+	audience string
+}
+
+// NewTokenIssuer creates a TokenIssuer for the iss claim of tokens issued by this service
+// (JWT_ISSUER) and the aud claim of access tokens for this API (JWT_AUDIENCE)
+func NewTokenIssuer(issuer, audience string) *TokenIssuer {
+	return &TokenIssuer{issuer: issuer, audience: audience}
+}
+
+// GenerateJWT creates a short-lived access token for a session
+func (t *TokenIssuer) GenerateJWT(username, role string, organization *uint, scopes []policy.Grant, sessionID uint) (string, error) {
+	keys, err := Keys()
+	if err != nil {
+		return "", err
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	now := time.Now()
 
 	claims := &Claims{
-		Username: username,
+		Username:     username,
+		Role:         role,
+		Scopes:       scopes,
+		Organization: OrganizationOf(organization),
+		SessionID:    sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			Issuer:    t.issuer,
+			Subject:   username,
+			Audience:  jwt.ClaimStrings{t.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+			ID:        hex.EncodeToString(jti), // Lets a single token be revoked
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	return keys.Sign(claims)
 }
 
-// ValidateJWT verifies the token
-func ValidateJWT(tokenString string) (*Claims, error) {
-	claims := &Claims{}
-
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+// ValidateJWT verifies the token's signature, algorithm and standard claims
+func (t *TokenIssuer) ValidateJWT(tokenString string) (*Claims, error) {
+	keys, err := Keys()
+	if err != nil {
+		return nil, err
+	}
 
-	if err != nil || !token.Valid {
+	claims := &Claims{}
+	token, err := keys.Parse(tokenString, claims,
+		jwt.WithIssuer(t.issuer),
+		jwt.WithAudience(t.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil || !token.Valid || claims.Subject != claims.Username || claims.ID == "" {
 		return nil, errors.New("invalid token")
 	}
 
@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms accepted when validating tokens; anything else, including HS256 and
+// "none", is rejected before a key is looked up
+var allowedAlgorithms = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+
+var (
+	ErrNoSigningKey = errors.New("no private key for the active signing key ID")
+	ErrUnknownKey   = errors.New("unknown signing key")
+)
+
+// SigningKey is an RSA or Ed25519 key identified by its kid
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer // Nil for keys that are only kept to verify older tokens
+	Public  crypto.PublicKey
+}
+
+// KeySet signs tokens with one active key and verifies them with any key in the set, so keys
+// can be rotated by adding a new key, making it active, and removing the old one once its
+// tokens have expired
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+var (
+	keysMu sync.Mutex
+	keySet *KeySet
+)
+
+// UseKeys loads the process-wide key set from dir (JWT_KEYS_DIR) with activeKID (JWT_ACTIVE_KID)
+// as the signing key. Without a key directory an ephemeral Ed25519 key is generated for development.
+func UseKeys(dir, activeKID string) error {
+	var ks *KeySet
+	var err error
+	if dir == "" {
+		log.Println("⚠️ JWT_KEYS_DIR is not set; signing with an ephemeral key, so tokens will not survive a restart")
+		ks, err = EphemeralKeySet()
+	} else {
+		ks, err = LoadKeySet(dir, activeKID)
+	}
+	if err != nil {
+		return err
+	}
+
+	keysMu.Lock()
+	keySet = ks
+	keysMu.Unlock()
+	return nil
+}
+
+// Keys returns the process-wide key set, generating an ephemeral one if UseKeys was never called
+func Keys() (*KeySet, error) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	if keySet == nil {
+		ks, err := EphemeralKeySet()
+		if err != nil {
+			return nil, err
+		}
+		keySet = ks
+	}
+	return keySet, nil
+}
+
+// LoadKeySet reads every <kid>.pem file in dir. Private keys (PKCS#1 or PKCS#8) can sign and
+// verify; public keys (PKIX) only verify. activeKID selects the signing key and defaults to the
+// only private key when there is exactly one.
+func LoadKeySet(dir, activeKID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	ks := &KeySet{keys: map[string]*SigningKey{}}
+	var private []string
+	for _, p := range paths {
+		kid := strings.TrimSuffix(filepath.Base(p), ".pem")
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		key, err := parseKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		ks.keys[kid] = key
+		if key.Private != nil {
+			private = append(private, kid)
+		}
+	}
+
+	if activeKID == "" && len(private) == 1 {
+		activeKID = private[0]
+	}
+	active, ok := ks.keys[activeKID]
+	if !ok || active.Private == nil {
+		return nil, fmt.Errorf("%w %q in %s", ErrNoSigningKey, activeKID, dir)
+	}
+	ks.active = active
+	return ks, nil
+}
+
+// EphemeralKeySet generates a single in-memory Ed25519 key
+func EphemeralKeySet() (*KeySet, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	kid := "ephemeral-" + base64.RawURLEncoding.EncodeToString(public[:6])
+	key := &SigningKey{ID: kid, Method: jwt.SigningMethodEdDSA, Private: private, Public: public}
+	return &KeySet{active: key, keys: map[string]*SigningKey{kid: key}}, nil
+}
+
+// Sign signs claims with the active key and records its kid in the token header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	token.Header["kid"] = ks.active.ID
+	return token.SignedString(ks.active.Private)
+}
+
+// Parse verifies a token's signature with the key named by its kid, only accepting the
+// allowed algorithms, and then validates the claims with the given options
+func (ks *KeySet) Parse(tokenString string, claims jwt.Claims, options ...jwt.ParserOption) (*jwt.Token, error) {
+	options = append(options, jwt.WithValidMethods(allowedAlgorithms))
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ks.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+		}
+		// A key is only valid with its own algorithm, so an RSA key cannot verify an EdDSA token
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("key %q does not use %s", kid, token.Method.Alg())
+		}
+		return key.Public, nil
+	}, options...)
+}
+
+// JWKS returns the public half of every key in the set
+func (ks *KeySet) JWKS() JWKS {
+	ids := make([]string, 0, len(ks.keys))
+	for kid := range ks.keys {
+		ids = append(ids, kid)
+	}
+	sort.Strings(ids)
+
+	doc := JWKS{Keys: make([]JWK, 0, len(ids))}
+	for _, kid := range ids {
+		key := ks.keys[kid]
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.Method.Alg()}
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		doc.Keys = append(doc.Keys, jwk)
+	}
+	return doc
+}
+
+func parseKey(kid string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{ID: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T; use RSA or Ed25519", parsed)
+	}
+	return key, nil
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// NewSecret generates a bearer secret such as an API token, SDK key, refresh token, invite or
+// password reset token: the prefix followed by 256 random bits, base64url-encoded
+func NewSecret(prefix string) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// HashSecret returns the stored form of a secret from NewSecret. The secrets carry 256 bits of
+// randomness, so a fast hash is sufficient and allows direct lookup.
+func HashSecret(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
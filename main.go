@@ -1,18 +1,43 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	_ "feature-flag-service/docs"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"feature-flag-service/internal/app"
+	"feature-flag-service/internal/bootstrap"
 	"feature-flag-service/internal/config"
+	"feature-flag-service/internal/degraded"
+	"feature-flag-service/internal/guardrails"
 	"feature-flag-service/internal/handlers"
+	"feature-flag-service/internal/health"
+	"feature-flag-service/internal/lifecycle"
+	"feature-flag-service/internal/metrics"
+	"feature-flag-service/internal/mfa"
 	"feature-flag-service/internal/middleware"
+	"feature-flag-service/internal/migrations"
+	"feature-flag-service/internal/notify"
+	"feature-flag-service/internal/oidc"
+	"feature-flag-service/internal/passwords"
+	"feature-flag-service/internal/policy"
+	"feature-flag-service/internal/rollouts"
+	"feature-flag-service/internal/scheduler"
+	"feature-flag-service/internal/sessions"
+	"feature-flag-service/internal/snapshot"
+	"feature-flag-service/internal/store"
+	"feature-flag-service/internal/utils"
 
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -28,21 +53,196 @@ import (
 // @host localhost:8080
 // @BasePath /
 func main() {
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("⚠️ No .env file found, using system environment variables")
+	// Load and validate the configuration from defaults, CONFIG_FILE, .env and the environment
+	settings, err := config.Load()
+	if err != nil {
+		log.Fatalf("❌ Invalid configuration:\n%v", err)
 	}
 
-	// Initialize database and Redis
-	config.Init()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			// "migrate up|down|status|to" manages the schema and exits without starting the server
+			if err := config.ConnectDB(settings.Database); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			if err := migrations.Run(config.DB, os.Args[2:], os.Stdout); err != nil {
+				log.Fatalf("❌ Migration failed: %v", err)
+			}
+			return
+		case "config":
+			// "config print" shows the effective configuration with secrets redacted
+			if len(os.Args) < 3 || os.Args[2] != "print" {
+				log.Fatal("❌ usage: config print")
+			}
+			out, err := settings.Redacted()
+			if err != nil {
+				log.Fatalf("❌ Failed to print configuration: %v", err)
+			}
+			os.Stdout.Write(out)
+			return
+		}
+	}
+
+	// Hand each component its settings; the JWT keys are loaded here so a bad key fails at startup rather than at login
+	if err := configure(settings); err != nil {
+		log.Fatalf("❌ Invalid configuration: %v", err)
+	}
+	authenticator, err := mfa.NewAuthenticator(settings.Auth.MFAIssuer, settings.Auth.MFAEncryptionKey)
+	if err != nil {
+		log.Fatalf("❌ Invalid configuration: MFA encryption key: %v", err)
+	}
+
+	// The last flag snapshot lets evaluations continue if the database is unavailable
+	snapshot.UseFile(settings.Snapshot.File)
+	if err := snapshot.Load(); err != nil {
+		log.Printf("⚠️ Ignoring unreadable flag snapshot: %v", err)
+	}
+
+	// Subsystems start in registration order and stop in reverse, so storage closes last
+	application := app.New(settings.Server.ShutdownDelay, settings.Server.ShutdownTimeout)
+	application.Register(app.Component{Name: "storage", Stop: func(context.Context) error { return config.Close() }})
+
+	// Initialize database and Redis, retrying for their connect timeouts. If either is still
+	// unreachable, start in degraded mode as long as there is a snapshot to evaluate from.
+	var finishOnce sync.Once
+	finishStartup := func() error {
+		if os.Getenv("TEST_MODE") != "true" {
+			if err := config.RunMigrations(settings.Database.MigrateOnStart); err != nil {
+				return err
+			}
+		}
+		// Create the first organization and owner on an empty database
+		if err := bootstrap.Run(settings.Bootstrap); err != nil {
+			return fmt.Errorf("failed to bootstrap: %w", err)
+		}
+		return snapshot.Refresh(config.DB, time.Now())
+	}
+	err = config.Init(settings)
+	var outages []error
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		outages = joined.Unwrap()
+	}
+	for _, outage := range outages {
+		switch {
+		case errors.Is(outage, config.ErrRedisUnavailable):
+			degraded.MarkDown(degraded.Redis, outage, time.Now())
+		case errors.Is(outage, config.ErrDatabaseUnavailable) && snapshot.Current() != nil:
+			degraded.MarkDown(degraded.Database, outage, time.Now())
+		case errors.Is(outage, config.ErrDatabaseUnavailable):
+			log.Fatalf("❌ %v; no flag snapshot to serve from (set SNAPSHOT_FILE to keep one across restarts)", outage)
+		default:
+			log.Fatalf("❌ %v", outage)
+		}
+	}
+	if !degraded.Down(degraded.Database) {
+		finishOnce.Do(func() { err = finishStartup() })
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+	}
+
+	// Background jobs; stopping waits for in-flight runs
+	jobs := scheduler.New()
+	jobs.Every(time.Minute, "rollouts", rollouts.AdvanceDue)
+	jobs.Every(time.Hour, "prune-health-signals", guardrails.PruneSignals)
+	jobs.Every(time.Hour, "purge-trash", func(now time.Time) error {
+		return lifecycle.PurgeExpired(time.Duration(settings.Server.TrashRetentionDays)*24*time.Hour, now)
+	})
+	jobs.Every(time.Hour, "prune-sessions", sessions.PruneExpired)
+	jobs.Every(settings.Snapshot.Interval, "snapshot", func(now time.Time) error {
+		if degraded.Down(degraded.Database) {
+			return nil // Keep the last good snapshot
+		}
+		return snapshot.Refresh(config.DB, now)
+	})
+	pings := map[string]func(context.Context) error{degraded.Database: config.PingDB}
+	if config.RDB != nil {
+		pings[degraded.Redis] = func(context.Context) error { return config.Cache.Ping() }
+	}
+	jobs.Every(5*time.Second, "dependency-watch", degraded.Watch(pings, func(dependency string) {
+		if dependency != degraded.Database {
+			return
+		}
+		// Finish a startup deferred by the outage, or catch the snapshot up with the database
+		var err error
+		finishOnce.Do(func() { err = finishStartup() })
+		if err == nil {
+			err = snapshot.Refresh(config.DB, time.Now())
+		}
+		if err != nil {
+			application.Fail(err)
+		}
+	}))
+	application.Register(app.Component{
+		Name:  "scheduler",
+		Start: func(context.Context) error { jobs.Start(); return nil },
+		Stop:  func(context.Context) error { jobs.Stop(); return nil },
+	})
+
+	// Flag and user handlers persist through a store; the other handlers still use config.DB
+	st := store.NewSQL(config.DB)
+	flags := handlers.NewFlagHandler(st)
+	users := handlers.NewUserHandler(st)
+
+	// Sign-in and password resets get their token, MFA and link settings through their handlers
+	tokenIssuer := utils.NewTokenIssuer(settings.JWT.Issuer, settings.JWT.Audience)
+	auth := handlers.NewAuthHandler(tokenIssuer, authenticator, settings.Auth.AllowOpenRegistration)
+	resets := handlers.NewPasswordHandler(settings.Passwords.ResetURL)
+
+	// Dependency checks behind /readyz and the detailed report at /api/health. Outages covered
+	// by degraded mode are reported as degraded so instances keep serving evaluations.
+	checks := health.NewChecker()
+	checks.Add("database", true, func(ctx context.Context) error {
+		err := config.PingDB(ctx)
+		if err != nil && snapshot.Current() != nil {
+			return health.Degraded(fmt.Errorf("%w; serving evaluations from the flag snapshot, writes are refused", err))
+		}
+		return err
+	})
+	if config.RDB != nil {
+		checks.Add("redis", true, func(context.Context) error {
+			if err := config.Cache.Ping(); err != nil {
+				return health.Degraded(err)
+			}
+			return nil
+		})
+	}
+	checks.Add("migrations", true, func(ctx context.Context) error {
+		err := migrations.Check(config.DB.WithContext(ctx))
+		if errors.Is(err, migrations.ErrSchemaTooNew) || (err != nil && degraded.Down(degraded.Database)) {
+			// A newer release has migrated during a rolling deploy, or the database is down and
+			// already reported; keep serving until replaced or recovered
+			return health.Degraded(err)
+		}
+		return err
+	})
+	checks.Add("snapshot", false, func(context.Context) error {
+		snap := snapshot.Current()
+		if snap == nil {
+			return errors.New("no flag snapshot has been taken")
+		}
+		age := time.Since(snap.TakenAt).Round(time.Second)
+		if degraded.Active() {
+			return health.Degraded(fmt.Errorf("degraded mode (%s); evaluations use the snapshot taken %s ago", degraded.Summary(), age))
+		}
+		if age > 3*settings.Snapshot.Interval {
+			return fmt.Errorf("flag snapshot is %s old", age)
+		}
+		return nil
+	})
+	probes := handlers.NewHealthHandler(checks, application.Ready)
+	config.RegisterPoolMetrics()
+	scrape := handlers.NewMetricsHandler(settings.Metrics.Token)
 
 	// Create a new Gin router
 	r := gin.Default()
+	r.Use(middleware.Metrics())
 
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"}, // Allow all origins (Change this to specific domains in production)
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Content-Type", "Authorization"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Content-Type", "Authorization", "X-Project", "X-Environment"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 	}))
@@ -50,30 +250,173 @@ func main() {
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	// Public routes
-	r.POST("/register", handlers.Register)
-	r.POST("/login", handlers.Login)
+	r.POST("/register", auth.Register)
+	r.POST("/invites/accept", handlers.AcceptInvite)
+	r.POST("/password/forgot", resets.ForgotPassword)
+	r.POST("/password/reset", handlers.ResetPassword)
+	r.POST("/login", auth.Login)
+	r.POST("/login/mfa", auth.LoginMFA)
+	r.POST("/login/mfa/enroll", auth.EnrollMFAAtLogin)
+	r.POST("/refresh", auth.Refresh)
+	r.GET("/auth/oidc/login", handlers.OIDCLogin)
+	r.GET("/auth/oidc/callback", auth.OIDCCallback)
+	r.GET("/.well-known/jwks.json", handlers.GetJWKS)
 
-	// Health check endpoint
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	})
+	// Probes: liveness never checks dependencies; readiness fails while shutting down or when
+	// a critical dependency fails, so load balancers stop routing here first
+	r.GET("/livez", probes.Livez)
+	r.GET("/readyz", probes.Readyz)
+	r.GET("/health", probes.Readyz) // Kept for existing health checks
+	r.GET("/metrics", scrape.GetMetrics)
 
 	api := r.Group("/api")
-	api.Use(middleware.AuthMiddleware())
+	api.Use(middleware.AuthMiddleware(tokenIssuer))
+	// Logging out only adds the token to the revocation list, so it is registered before writes
+	// are guarded and still works while the database is down
+	api.POST("/logout", middleware.RequireSession(), handlers.Logout)
+	// While the database is down, API requests that would change state get a 503 instead of failing midway
+	api.Use(middleware.RejectWritesWhenDegraded())
+
+	// Every route is authorized by the policy engine for a specific action. Listings only require
+	// the action on some resource and filter out the items the caller may not see.
+	allow, allowAny := middleware.Authorize, middleware.AuthorizeAny
 	{
-		api.POST("/flags", handlers.CreateFeatureFlag)
-		api.GET("/flags", handlers.GetFeatureFlags)
-		api.GET("/flags/:id", handlers.GetFeatureFlag)
-		api.PUT("/flags/:id", handlers.UpdateFeatureFlag)
-		api.DELETE("/flags/:id", handlers.DeleteFeatureFlag)
+		api.GET("/flags", allowAny(policy.FlagRead), flags.GetFeatureFlags)
+		api.POST("/flags", allow(policy.FlagCreate), flags.CreateFeatureFlag)
+		api.GET("/flags/:id", allow(policy.FlagRead), flags.GetFeatureFlag)
+		api.PUT("/flags/:id", allow(policy.FlagUpdate), flags.UpdateFeatureFlag)
+		api.PATCH("/flags/:id/toggle", allow(policy.FlagToggle), flags.ToggleFeatureFlag)
+		api.DELETE("/flags/:id", allow(policy.FlagDelete), flags.DeleteFeatureFlag)
+		api.GET("/flags/:id/evaluate", allow(policy.FlagRead), handlers.EvaluateFeatureFlag)
+		api.GET("/flags/:id/history", allow(policy.FlagRead), handlers.GetFlagHistory)
+		api.GET("/dependency-graph", allowAny(policy.FlagRead), handlers.GetDependencyGraph)
+		api.GET("/reports/stale-flags", allowAny(policy.FlagRead), handlers.GetStaleFlags)
+
+		api.GET("/trash/flags", allowAny(policy.FlagRead), handlers.GetDeletedFeatureFlags)
+		api.POST("/trash/flags/:id/restore", allow(policy.FlagRestore), handlers.RestoreFeatureFlag)
+		api.DELETE("/trash/flags/:id", allow(policy.FlagPurge), handlers.PurgeFeatureFlag)
+
+		api.POST("/flags/:id/rollout", allow(policy.FlagUpdateRules), handlers.StartRollout)
+		api.POST("/flags/:id/rollout/pause", allow(policy.FlagUpdateRules), handlers.PauseRollout)
+		api.POST("/flags/:id/rollout/resume", allow(policy.FlagUpdateRules), handlers.ResumeRollout)
+		api.POST("/flags/:id/rollout/abort", allow(policy.FlagUpdateRules), handlers.AbortRollout)
+
+		api.GET("/flags/:id/guardrails", allow(policy.FlagRead), handlers.GetGuardrails)
+		api.PUT("/flags/:id/guardrails", allow(policy.FlagUpdateRules), handlers.SetGuardrails)
+		api.POST("/flags/:id/signals", allow(policy.SignalWrite), handlers.IngestSignals)
+
+		api.GET("/users", allow(policy.UserRead), users.GetUsers)
+		api.GET("/users/:id", allow(policy.UserRead), users.GetUser)
+		api.DELETE("/users/:id", allow(policy.UserManage), users.DeleteUser)
+		api.POST("/users/:id/disable", allow(policy.UserManage), users.DisableUser)
+		api.POST("/users/:id/enable", allow(policy.UserManage), users.EnableUser)
+		api.PUT("/users/:id/role", allow(policy.UserManage), users.UpdateUserRole)
+		api.GET("/organizations", allow(policy.UserRead), handlers.GetOrganizations)
+		api.POST("/organizations", allow(policy.UserManage), handlers.CreateOrganization)
+		api.GET("/invites", allow(policy.UserManage), handlers.GetInvites)
+		api.POST("/invites", allow(policy.UserManage), handlers.CreateInvite)
+		api.DELETE("/invites/:id", allow(policy.UserManage), handlers.RevokeInvite)
+		api.GET("/users/:id/bindings", allow(policy.UserRead), handlers.GetRoleBindings)
+		api.POST("/users/:id/bindings", allow(policy.UserManage), handlers.CreateRoleBinding)
+		api.DELETE("/users/:id/bindings/:bindingId", allow(policy.UserManage), handlers.DeleteRoleBinding)
+
+		api.DELETE("/users/:id/mfa", allow(policy.UserManage), handlers.ResetUserMFA)
+		api.POST("/users/:id/unlock", allow(policy.UserManage), handlers.UnlockUser)
+		api.GET("/audit", allow(policy.UserManage), handlers.GetAuditLog)
+		api.GET("/mfa/requirements", allow(policy.UserRead), handlers.GetMFARequirements)
+		api.PUT("/mfa/requirements", allow(policy.UserManage), handlers.SetMFARequirements)
+		api.PUT("/organizations/:id/mfa", allow(policy.UserManage), handlers.SetOrganizationMFA)
+
+		api.GET("/roles", allow(policy.RoleRead), handlers.GetCustomRoles)
+		api.POST("/roles", allow(policy.RoleManage), handlers.CreateCustomRole)
+		api.PUT("/roles/:id", allow(policy.RoleManage), handlers.UpdateCustomRole)
+		api.DELETE("/roles/:id", allow(policy.RoleManage), handlers.DeleteCustomRole)
+
+		api.GET("/sdk-keys", allowAny(policy.SDKKeyRead), handlers.GetSDKKeys)
+		api.POST("/sdk-keys", allow(policy.SDKKeyManage), handlers.CreateSDKKey)
+		api.POST("/sdk-keys/:id/rotate", allow(policy.SDKKeyManage), handlers.RotateSDKKey)
+		api.DELETE("/sdk-keys/:id", allow(policy.SDKKeyManage), handlers.RevokeSDKKey)
+
+		api.GET("/service-accounts", allow(policy.ServiceAccountRead), handlers.GetServiceAccounts)
+		api.POST("/service-accounts", allow(policy.ServiceAccountManage), handlers.CreateServiceAccount)
+		api.DELETE("/service-accounts/:id", allow(policy.ServiceAccountManage), handlers.DeleteServiceAccount)
+		api.GET("/service-accounts/:id/tokens", allow(policy.ServiceAccountRead), handlers.GetServiceAccountTokens)
+		api.POST("/service-accounts/:id/tokens", allow(policy.ServiceAccountManage), middleware.RequireSession(), handlers.CreateServiceAccountToken)
+		api.DELETE("/service-accounts/:id/tokens/:tokenId", allow(policy.ServiceAccountManage), handlers.RevokeServiceAccountToken)
+
+		// Profiles, sessions, second factors and personal access tokens belong to the caller, so any
+		// signed-in user may manage their own. No action scope covers them, so access tokens are refused.
+		self := api.Group("", middleware.RequireSession())
+		self.GET("/me", handlers.GetProfile)
+		self.PUT("/me", handlers.UpdateProfile)
+		self.PUT("/me/password", handlers.ChangePassword)
+		self.GET("/sessions", handlers.GetSessions)
+		self.DELETE("/sessions/:id", handlers.RevokeSession)
+		self.POST("/mfa/enroll", auth.EnrollMFA)
+		self.POST("/mfa/activate", auth.ActivateMFA)
+		self.POST("/mfa/recovery-codes", auth.RegenerateRecoveryCodes)
+		self.DELETE("/mfa", auth.DisableMFA)
+		self.GET("/tokens", handlers.GetAccessTokens)
+		self.POST("/tokens", handlers.CreateAccessToken)
+		self.DELETE("/tokens/:id", handlers.RevokeAccessToken)
+
+		self.GET("/auth/explain", handlers.ExplainPermissions)
+		api.GET("/health", allow(policy.SystemHealth), probes.GetHealthReport)
 	}
 
-	// Get port from environment
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// SDK routes authenticate with per-environment SDK keys rather than user tokens
+	sdk := r.Group("/sdk")
+	{
+		sdk.GET("/flags", middleware.SDKKeyMiddleware(false), handlers.GetSDKFlags)
+		sdk.GET("/evaluate", middleware.SDKKeyMiddleware(true), handlers.EvaluateSDKFlags)
 	}
 
+	port := strconv.Itoa(settings.Server.Port)
+	application.Serve("http", &http.Server{Addr: ":" + port, Handler: r, ReadHeaderTimeout: 10 * time.Second})
+
+	// SIGINT or SIGTERM starts a graceful shutdown; a second signal exits immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	fmt.Printf("🚀 Server running on port %s\n", port)
-	r.Run(":" + port)
+	if err := application.Run(ctx); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	fmt.Println("👋 Shutdown complete")
+}
+
+// configure passes the settings to the components that read them at runtime
+func configure(settings *config.Settings) error {
+	if err := utils.UseKeys(settings.JWT.KeysDir, settings.JWT.ActiveKID); err != nil {
+		return fmt.Errorf("JWT signing keys: %w", err)
+	}
+
+	policy, err := passwords.NewPolicy(settings.Passwords.MinLength, settings.Passwords.History, settings.Passwords.BreachedList)
+	if err != nil {
+		return err
+	}
+	passwords.Use(policy)
+
+	notifier, err := notify.New(settings.Notifier.Kind, settings.Notifier.File)
+	if err != nil {
+		return err
+	}
+	notify.Use(notifier)
+
+	oidc.Configure(oidc.Config{
+		Issuer:       settings.OIDC.Issuer,
+		ClientID:     settings.OIDC.ClientID,
+		ClientSecret: settings.OIDC.ClientSecret,
+		RedirectURL:  settings.OIDC.RedirectURL,
+		Scopes:       oidc.ParseScopes(settings.OIDC.Scopes),
+		GroupsClaim:  settings.OIDC.GroupsClaim,
+		RoleMapping:  oidc.ParseRoleMapping(settings.OIDC.RoleMapping),
+		DefaultRole:  settings.OIDC.DefaultRole,
+	})
+	metrics.SetMaxFlags(settings.Metrics.MaxFlags)
+	return nil
 }